@@ -5,14 +5,19 @@
 package wm
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
 )
 
 func caller(s string, va ...interface{}) {
@@ -51,6 +56,23 @@ func init() {
 	use(caller, dbg, TODO) //TODOOK
 }
 
+// screenText returns the runes of area of s, one line per row.
+func screenText(s tcell.Screen, area Rectangle) string {
+	var a []string
+	for y := area.Y; y < area.Y+area.Height; y++ {
+		var b []rune
+		for x := area.X; x < area.X+area.Width; x++ {
+			r, _, _, w := s.GetContent(x, y)
+			b = append(b, r)
+			if w == 2 {
+				x++
+			}
+		}
+		a = append(a, string(b))
+	}
+	return strings.Join(a, "\n")
+}
+
 // ============================================================================
 
 func TestJoin(t *testing.T) {
@@ -106,6 +128,7 @@ func TestDesktopPaintContext(t *testing.T) {
 	if g, e := cc, (PaintContext{
 		Rectangle: Rectangle{Position{}, Size{80, 25}},
 		origin:    Position{},
+		size:      Size{80, 25},
 		view:      Position{},
 	}); g != e {
 		t.Fatalf("\n%+v\n%+v", g, e)
@@ -113,6 +136,7 @@ func TestDesktopPaintContext(t *testing.T) {
 	if g, e := c, (PaintContext{
 		Rectangle: Rectangle{Position{}, Size{80, 25}},
 		origin:    Position{},
+		size:      Size{80, 25},
 		view:      Position{},
 	}); g != e {
 		t.Fatalf("\n%+v\n%+v", g, e)
@@ -126,6 +150,7 @@ func TestDesktopPaintContext(t *testing.T) {
 	if g, e := cc, (PaintContext{
 		Rectangle: Rectangle{Position{}, Size{80, 25}},
 		origin:    Position{},
+		size:      Size{80, 25},
 		view:      Position{},
 	}); g != e {
 		t.Fatalf("\n%+v\n%+v", g, e)
@@ -133,8 +158,4139 @@ func TestDesktopPaintContext(t *testing.T) {
 	if g, e := c, (PaintContext{
 		Rectangle: Rectangle{Position{2, 1}, Size{80, 25}},
 		origin:    Position{},
+		size:      Size{80, 25},
 		view:      Position{2, 1},
 	}); g != e {
 		t.Fatalf("\n%+v\n%+v", g, e)
 	}
 }
+
+func TestFreezePaint(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var r *Window
+	n := 0
+	armed := false
+	ch := make(chan PaintContext, 2)
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		r = d.Root()
+		app.SetDesktop(d)
+		r.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			n++
+			if armed && app.frozen == 0 {
+				ch <- ctx
+			}
+		}, nil)
+		d.Show()
+	})
+
+	var frozen int
+	app.PostWait(func() {
+		n = 0
+		armed = true
+		app.FreezePaint()
+		app.FreezePaint()
+	})
+	app.PostWait(func() { r.InvalidateClientArea(Rectangle{Position{1, 1}, Size{2, 2}}) })
+	app.PostWait(func() { r.InvalidateClientArea(Rectangle{Position{5, 3}, Size{2, 2}}) })
+	app.PostWait(func() { app.ThawPaint() })
+	app.PostWait(func() {
+		frozen = n
+		app.ThawPaint()
+	})
+	c, c2 := <-ch, <-ch
+	if frozen != 0 {
+		t.Fatal(frozen)
+	}
+
+	if g, e := c.Rectangle, (Rectangle{Position{1, 1}, Size{2, 2}}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := c2.Rectangle, (Rectangle{Position{5, 3}, Size{2, 2}}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		d.Root().SetTitle("foo")
+	})
+	if g, e := app.Query(func() interface{} { return app.Desktop().Root().Title() }), "foo"; g != e {
+		t.Fatal(g, e)
+	}
+
+	if g, e := app.Query(func() interface{} { return app.Size() }), (Size{80, 25}); g != e {
+		t.Fatal(g, e)
+	}
+}
+
+func TestQueryAfterExit(t *testing.T) {
+	app, err := newApplication(tcell.NewSimulationScreen(""), &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enqueued before Exit but executed after it.
+	app.Post(func() { app.Exit(nil) })
+	if g := app.Query(func() interface{} { return 42 }); g != nil {
+		t.Fatal(g)
+	}
+
+	if err := app.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g := app.Query(func() interface{} { return 42 }); g != nil {
+		t.Fatal(g)
+	}
+}
+
+func benchmarkFocus(b *testing.B, repaintOnFocus bool) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	var d *Desktop
+	var w, w2 *Window
+	app.PostWait(func() {
+		d = app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		w = r.NewChild(Rectangle{Position{0, 0}, Size{80, 25}})
+		w.SetRepaintOnFocus(repaintOnFocus)
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			for y := ctx.Y; y < ctx.Y+ctx.Height; y++ {
+				w.Printf(ctx.X, y, w.ClientAreaStyle(), "%80d", y)
+			}
+		}, nil)
+		w2 = r.NewChild(Rectangle{Position{0, 0}, Size{1, 1}})
+		d.Show()
+	})
+	app.Query(func() interface{} { return nil })
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		u := w
+		if i&1 != 0 {
+			u = w2
+		}
+		app.Query(func() interface{} { d.SetFocusedWindow(u); return nil })
+	}
+}
+
+func BenchmarkFocus(b *testing.B) { benchmarkFocus(b, false) }
+
+func BenchmarkFocusRepaintAll(b *testing.B) { benchmarkFocus(b, true) }
+
+func BenchmarkClear(b *testing.B) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	var w *Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w = d.Root().NewChild(Rectangle{Position{0, 0}, Size{80, 25}})
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		app.Query(func() interface{} {
+			w.InvalidateClientArea(Rectangle{w.Origin(), w.ClientSize()})
+			return nil
+		})
+	}
+}
+
+// newPaintBenchmark returns a function rendering a desktop having a child
+// window, which paints its client area using Print.
+func newPaintBenchmark(app *Application) func() {
+	return app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		w := r.NewChild(Rectangle{Position{0, 0}, Size{80, 25}})
+		w.SetTitle("title")
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			for y := ctx.Y; y < ctx.Y+ctx.Height; y++ {
+				w.Print(0, y, w.ClientAreaStyle(), "Lorem ipsum dolor sit amet, 世界, á.")
+			}
+		}, nil)
+		return func() { r.render(r.Area()) }
+	}).(func())
+}
+
+func BenchmarkPaint(b *testing.B) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	paint := newPaintBenchmark(app)
+	b.ReportAllocs()
+	b.ResetTimer()
+	app.PostWait(func() {
+		for i := 0; i < b.N; i++ {
+			paint()
+		}
+	})
+}
+
+func benchmarkBuffered(b *testing.B, buffered bool) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	w := app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w := d.Root().NewChild(Rectangle{Position{0, 0}, Size{80, 25}})
+		w.SetBuffered(buffered)
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			for y := ctx.Y; y < ctx.Y+ctx.Height; y++ {
+				w.Print(0, y, w.ClientAreaStyle(), "Lorem ipsum dolor sit amet, 世界, á.")
+			}
+		}, nil)
+		return w
+	}).(*Window)
+	cells := 0
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cells += app.Query(func() interface{} {
+			n := app.metrics.Cells
+			w.InvalidateClientArea(Rectangle{Size: w.ClientSize()})
+			return n
+		}).(int)
+	}
+	b.ReportMetric(float64(cells)/float64(b.N), "cells/op")
+}
+
+func BenchmarkBuffered(b *testing.B) {
+	b.Run("plain", func(b *testing.B) { benchmarkBuffered(b, false) })
+	b.Run("buffered", func(b *testing.B) { benchmarkBuffered(b, true) })
+}
+
+func benchmarkResize(b *testing.B, repaintOnResize bool) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	w := app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w := d.Root().NewChild(Rectangle{Position{0, 0}, Size{40, 12}})
+		w.SetRepaintOnResize(repaintOnResize)
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			for y := ctx.Y; y < ctx.Y+ctx.Height; y++ {
+				w.Print(0, y, w.ClientAreaStyle(), "Lorem ipsum dolor sit amet, consectetur adipiscing elit.")
+			}
+		}, nil)
+		return w
+	}).(*Window)
+	cells := 0
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Drag the bottom right corner by one cell per step.
+		sz := Size{40 + i%40, 12 + i%12}
+		cells += app.Query(func() interface{} {
+			n := app.metrics.Cells
+			w.SetSize(sz)
+			return n
+		}).(int)
+	}
+	b.ReportMetric(float64(cells)/float64(b.N), "cells/op")
+}
+
+func BenchmarkResize(b *testing.B) {
+	b.Run("diff", func(b *testing.B) { benchmarkResize(b, false) })
+	b.Run("repaint", func(b *testing.B) { benchmarkResize(b, true) })
+}
+
+func BenchmarkInvalidateNested(b *testing.B) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	var w *Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w = d.Root()
+		for i := 0; i < 8; i++ {
+			w = w.NewChild(Rectangle{Position{1, 1}, Size{60 - 2*i, 20 - 2*i}})
+		}
+	})
+	b.ResetTimer()
+	app.PostWait(func() {
+		w.BeginUpdate()
+		for i := 0; i < b.N; i++ {
+			w.Invalidate(Rectangle{Position{i % 3, 0}, Size{1, 1}})
+		}
+		w.EndUpdate()
+	})
+}
+
+func TestRegion(t *testing.T) {
+	var g region
+	g.add(Rectangle{})
+	if len(g) != 0 {
+		t.Fatal(g)
+	}
+
+	g.add(Rectangle{Position{0, 0}, Size{10, 1}})
+	g.add(Rectangle{Position{0, 0}, Size{1, 10}})
+	g.add(Rectangle{Position{2, 0}, Size{3, 1}})
+	if g, e := fmt.Sprint(g), "[{{0 0} {10 1}} {{0 0} {1 10}}]"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	g.add(Rectangle{Position{0, 0}, Size{10, 10}})
+	if g, e := fmt.Sprint(g), "[{{0 0} {10 10}}]"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	g = nil
+	for i := 0; i <= maxRegion; i++ {
+		g.add(Rectangle{Position{2 * i, i}, Size{1, 1}})
+	}
+	if g, e := fmt.Sprint(g), "[{{0 0} {17 9}}]"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestEnsureChildVisible(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+	})
+	for i, v := range []struct {
+		area   Rectangle
+		origin Position
+	}{
+		{Rectangle{Position{10, 5}, Size{10, 5}}, Position{0, 0}},
+		{Rectangle{Position{100, 50}, Size{10, 5}}, Position{30, 30}},
+		{Rectangle{Position{90, 20}, Size{10, 5}}, Position{30, 20}},
+		{Rectangle{Position{5, 3}, Size{10, 5}}, Position{5, 3}},
+		{Rectangle{Position{10, 10}, Size{100, 50}}, Position{10, 10}},
+		{Rectangle{Position{-5, -5}, Size{10, 5}}, Position{0, 0}},
+	} {
+		g := app.Query(func() interface{} {
+			r := app.Desktop().Root()
+			c := r.NewChild(v.area)
+			r.EnsureChildVisible(c)
+			c.Close()
+			return r.Origin()
+		})
+		if e := v.origin; g != e {
+			t.Errorf("%v: %v %v", i, g, e)
+		}
+	}
+}
+
+func TestCloseQuery(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c *Window
+	allow := false
+	queries, closes := 0, 0
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c = d.Root().NewChild(Rectangle{Position{1, 1}, Size{10, 5}})
+		c.OnCloseQuery(func(w *Window, prev OnCloseQueryHandler) bool {
+			queries++
+			return allow
+		}, nil)
+		c.OnClose(func(w *Window, prev OnCloseHandler) { closes++ }, nil)
+	})
+	f := func(close func()) (int, int, int) {
+		r := app.Query(func() interface{} {
+			close()
+			return [3]int{queries, closes, app.Desktop().Root().Children()}
+		}).([3]int)
+		return r[0], r[1], r[2]
+	}
+	if q, c, n := f(func() { c.Close() }); q != 1 || c != 0 || n != 1 {
+		t.Fatal(q, c, n)
+	}
+
+	if q, c, n := f(func() { allow = true; c.Close() }); q != 2 || c != 1 || n != 0 {
+		t.Fatal(q, c, n)
+	}
+
+	app.PostWait(func() {
+		c = app.Desktop().Root().NewChild(Rectangle{Position{1, 1}, Size{10, 5}})
+		c.OnCloseQuery(func(w *Window, prev OnCloseQueryHandler) bool {
+			queries++
+			return false
+		}, nil)
+	})
+	if q, c, n := f(func() { c.ForceClose() }); q != 2 || c != 1 || n != 0 {
+		t.Fatal(q, c, n)
+	}
+
+	// Closing a parent asks its descendants.
+	var p *Window
+	app.PostWait(func() {
+		p = app.Desktop().Root().NewChild(Rectangle{Position{1, 1}, Size{10, 5}})
+		p.OnClose(func(w *Window, prev OnCloseHandler) { closes++ }, nil)
+		c = p.NewChild(Rectangle{Position{1, 1}, Size{5, 3}})
+		c.NewChild(Rectangle{Size: Size{2, 2}}).OnCloseQuery(func(w *Window, prev OnCloseQueryHandler) bool {
+			queries++
+			return allow
+		}, nil)
+	})
+	allow = false
+	if q, c, n := f(func() { p.Close() }); q != 3 || c != 1 || n != 1 {
+		t.Fatal(q, c, n)
+	}
+
+	if q, c, n := f(func() { allow = true; p.Close() }); q != 4 || c != 2 || n != 0 {
+		t.Fatal(q, c, n)
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c *Window
+	var events []bool
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c = d.Root().NewChild(Rectangle{Position{2, 3}, Size{20, 10}})
+		c.OnSetMinimized(func(w *Window, prev OnSetBoolHandler, dst *bool, src bool) {
+			prev(w, nil, dst, src)
+			events = append(events, src)
+		}, nil)
+	})
+	type state struct {
+		Rectangle
+		minimized bool
+		dragState int
+	}
+	get := func(f func()) state {
+		return app.Query(func() interface{} {
+			f()
+			return state{Rectangle{c.Position(), c.Size()}, c.Minimized(), c.dragState}
+		}).(state)
+	}
+
+	if g, e := get(func() { c.dragState = dragPos; c.Minimize() }), (state{Rectangle{Position{2, 3}, Size{20, 1}}, true, 0}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { c.Minimize() }), (state{Rectangle{Position{2, 3}, Size{20, 1}}, true, 0}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { c.SetPosition(Position{5, 6}); c.Restore() }), (state{Rectangle{Position{2, 3}, Size{20, 10}}, false, 0}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	r := Position{2 + 5, 3}
+	if g, e := get(func() { app.Desktop().Root().doubleClick(tcell.Button1, r, 0) }), (state{Rectangle{Position{0, 0}, Size{80, 25}}, false, 0}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { app.Desktop().Root().doubleClick(tcell.Button1, Position{5, 0}, 0) }), (state{Rectangle{Position{2, 3}, Size{20, 10}}, false, 0}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { c.Minimize(); app.Desktop().Root().doubleClick(tcell.Button1, r, 0) }), (state{Rectangle{Position{2, 3}, Size{20, 10}}, false, 0}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { c.SetDoubleClickMaximize(false); app.Desktop().Root().doubleClick(tcell.Button1, r, 0) }), (state{Rectangle{Position{2, 3}, Size{20, 10}}, false, 0}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := fmt.Sprint(events), "[true false true false]"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestMaximize(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var p, c *Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		p = d.Root().NewChild(Rectangle{Position{1, 1}, Size{40, 20}})
+		c = p.NewChild(Rectangle{Position{2, 3}, Size{10, 5}})
+	})
+	type state struct {
+		Rectangle
+		maximized bool
+	}
+	get := func(f func()) state {
+		return app.Query(func() interface{} {
+			f()
+			return state{Rectangle{c.Position(), c.Size()}, c.Maximized()}
+		}).(state)
+	}
+
+	if g, e := get(func() { app.Desktop().Root().Maximize() }), (state{Rectangle{Position{2, 3}, Size{10, 5}}, false}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { c.Maximize() }), (state{Rectangle{Position{0, 0}, Size{38, 18}}, true}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { p.SetSize(Size{30, 15}) }), (state{Rectangle{Position{0, 0}, Size{28, 13}}, true}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { c.Minimize(); c.Restore() }), (state{Rectangle{Position{0, 0}, Size{28, 13}}, true}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { c.Restore() }), (state{Rectangle{Position{2, 3}, Size{10, 5}}, false}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { p.SetSize(Size{40, 20}) }), (state{Rectangle{Position{2, 3}, Size{10, 5}}, false}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+}
+
+func TestSetClickDurations(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var click, doubleClick []time.Duration
+	app.PostWait(func() {
+		app.OnSetClickDuration(func(w *Window, prev OnSetDurationHandler, dst *time.Duration, src time.Duration) {
+			if prev != nil {
+				prev(w, nil, dst, src)
+			} else {
+				*dst = src
+			}
+			click = append(click, src)
+		}, nil)
+		app.OnSetDoubleClickDuration(func(w *Window, prev OnSetDurationHandler, dst *time.Duration, src time.Duration) {
+			if prev != nil {
+				prev(w, nil, dst, src)
+			} else {
+				*dst = src
+			}
+			doubleClick = append(doubleClick, src)
+		}, nil)
+	})
+	g := app.Query(func() interface{} {
+		app.SetClickDuration(time.Second)
+		app.SetDoubleClickDuration(2 * time.Second)
+		app.SetDoubleClickDuration(3 * time.Second)
+		return fmt.Sprint(app.ClickDuration(), app.DoubleClickDuration(), click, doubleClick)
+	})
+	if e := "1s 3s [1s] [2s 3s]"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestZOrder(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var r *Window
+	var c []*Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r = d.Root()
+		for i := 0; i < 4; i++ {
+			w := r.NewChild(Rectangle{Position{i, i}, Size{10, 5}})
+			w.SetTitle(fmt.Sprint(i))
+			c = append(c, w)
+		}
+	})
+	order := func(f func()) string {
+		return app.Query(func() interface{} {
+			f()
+			var a []string
+			for i := 0; i < r.Children(); i++ {
+				a = append(a, r.Child(i).Title())
+			}
+			return strings.Join(a, "")
+		}).(string)
+	}
+	for i, v := range []struct {
+		f func()
+		e string
+	}{
+		{func() {}, "0123"},
+		{func() { r.SendToBack() }, "0123"},
+		{func() { c[2].SendToBack() }, "2013"},
+		{func() { c[2].SendToBack() }, "2013"},
+		{func() { c[3].BringToFront() }, "2013"},
+		{func() { c[2].Raise() }, "0213"},
+		{func() { c[3].Raise() }, "0213"},
+		{func() { c[1].Lower() }, "0123"},
+		{func() { c[0].Lower() }, "0123"},
+		{func() { r.Lower(); r.Raise() }, "0123"},
+	} {
+		if g := order(v.f); g != v.e {
+			t.Errorf("%v: %s %s", i, g, v.e)
+		}
+	}
+}
+
+func TestMinMaxSize(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c *Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c = d.Root().NewChild(Rectangle{Position{10, 2}, Size{20, 10}})
+	})
+	get := func(f func()) Rectangle {
+		return app.Query(func() interface{} {
+			f()
+			return Rectangle{c.Position(), c.Size()}
+		}).(Rectangle)
+	}
+
+	if g, e := get(func() { c.SetMinSize(Size{10, 5}); c.SetSize(Size{2, 2}) }), (Rectangle{Position{10, 2}, Size{10, 5}}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { c.SetMaxSize(Size{20, 0}); c.SetSize(Size{30, 30}) }), (Rectangle{Position{10, 2}, Size{20, 30}}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() { c.SetMaxSize(Size{15, 8}) }), (Rectangle{Position{10, 2}, Size{15, 8}}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() {
+		c.SetMaxSize(Size{})
+		c.SetSize(Size{20, 10})
+		c.SetFocus(true)
+		c.dragState = dragULC
+		c.dragScreenPos0 = Position{10, 2}
+		c.dragWinPos0 = c.Position()
+		c.dragWinSize0 = c.Size()
+		app.Desktop().Root().mouseMove(0, Position{25, 12}, 0)
+	}), (Rectangle{Position{20, 7}, Size{10, 5}}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+
+	if g, e := get(func() {
+		app.Desktop().Root().drop(tcell.Button1, Position{14, 4}, 0)
+	}), (Rectangle{Position{14, 4}, Size{16, 8}}); g != e {
+		t.Fatalf("\n%+v\n%+v", g, e)
+	}
+}
+
+func TestBorderLineStyle(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c *Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c = d.Root().NewChild(Rectangle{Position{1, 1}, Size{4, 3}})
+	})
+	area := Rectangle{Position{1, 1}, Size{4, 3}}
+	for i, v := range []struct {
+		style BorderLineStyle
+		e     string
+	}{
+		{BorderLineASCII, "+--+\n|  |\n+--+"},
+		{BorderLineDouble, "╔══╗\n║  ║\n╚══╝"},
+		{BorderLineThick, "┏━━┓\n┃  ┃\n┗━━┛"},
+		{BorderLineNone, "    \n    \n    "},
+		{BorderLineSingle, "┌──┐\n│  │\n└──┘"},
+	} {
+		g := app.Query(func() interface{} {
+			c.SetBorderLineStyle(v.style)
+			return c.BorderLineStyle()
+		})
+		if g != v.style {
+			t.Fatal(i, g, v.style)
+		}
+
+		if g := app.Query(func() interface{} { return screenText(s, area) }); g != v.e {
+			t.Errorf("%v:\n%s\n%s", i, g, v.e)
+		}
+	}
+}
+
+func TestTitleAlignment(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c *Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c = d.Root().NewChild(Rectangle{Position{0, 0}, Size{16, 3}})
+		c.SetTitle("foo")
+	})
+	area := Rectangle{Size: Size{16, 1}}
+	for i, v := range []struct {
+		a           TitleAlignment
+		closeButton bool
+		e           string
+	}{
+		{TitleLeft, false, "┌ foo ─────────┐"},
+		{TitleCenter, false, "┌──── foo ─────┐"},
+		{TitleRight, false, "┌───────── foo ┐"},
+		{TitleLeft, true, "┌ foo ──────[X]┐"},
+		{TitleCenter, true, "┌─── foo ───[X]┐"},
+		{TitleRight, true, "┌────── foo [X]┐"},
+	} {
+		g := app.Query(func() interface{} {
+			c.SetTitleAlignment(v.a)
+			c.SetCloseButton(v.closeButton)
+			return c.TitleAlignment()
+		})
+		if g != v.a {
+			t.Fatal(i, g, v.a)
+		}
+
+		if g := app.Query(func() interface{} { return screenText(s, area) }); g != v.e {
+			t.Errorf("%v:\n%s\n%s", i, g, v.e)
+		}
+	}
+}
+
+func TestTitleInvalidate(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c *Window
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		c = r.NewChild(Rectangle{Position{2, 1}, Size{20, 3}})
+		c.SetTitle("foo")
+		r.BeginUpdate()
+		for _, f := range []func(){
+			func() { c.SetTitle("foobar") },
+			func() { c.SetTitle("") },
+			func() { c.SetTitle("") },
+			func() {
+				c.SetTitleAlignment(TitleCenter)
+				d.invalidated = nil
+				c.SetTitle("ab")
+			},
+			func() { c.SetTitle(strings.Repeat("x", 30)) },
+			func() {
+				c.SetTitleEllipsis(false)
+				d.invalidated = nil
+				c.SetTitle(strings.Repeat("y", 30))
+			},
+		} {
+			d.invalidated = nil
+			f()
+			a = append(a, fmt.Sprint(d.invalidated))
+		}
+		r.EndUpdate()
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "[{{3 1} {8 1}}] [{{3 1} {8 1}}] [] [{{10 1} {4 1}}] [{{3 1} {18 1}}] [{{2 1} {20 1}}]"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.PostWait(func() {
+		c.SetTitleEllipsis(true)
+		c.SetTitle(strings.Repeat("x", 30))
+	})
+	area := Rectangle{Position{2, 1}, Size{20, 1}}
+	if g, e := app.Query(func() interface{} { return screenText(s, area) }), "┌ xxxxxxxxxxxxxxx… ┐"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	app.PostWait(func() { c.SetTitle("ab") })
+	if g, e := app.Query(func() interface{} { return screenText(s, area) }), "┌─────── ab ───────┐"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	for i, v := range []struct {
+		s     string
+		width int
+		e     string
+	}{
+		{"", 0, ""},
+		{"abc", 0, ""},
+		{"abc", 1, "…"},
+		{"abc", 2, "a…"},
+		{"abc", 3, "abc"},
+		{"ab日本", 6, "ab日本"},
+		{"ab日本", 5, "ab日…"},
+		{"ab日本", 4, "ab…"},
+		{"ab日本", 3, "ab…"},
+		{"日本語x", 7, "日本語x"},
+		{"日本語x", 6, "日本…"},
+		{"日本語x", 2, "…"},
+		{"a日b", 3, "a…"},
+	} {
+		if g, e := ellipsis(v.s, v.width), v.e; g != e {
+			t.Errorf("%v: %q %v: %q %q", i, v.s, v.width, g, e)
+		}
+	}
+
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c *Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c = d.Root().NewChild(Rectangle{Position{0, 0}, Size{10, 3}})
+		c.SetTitle("ab日本語")
+	})
+	area := Rectangle{Size: Size{10, 1}}
+	if g, e := app.Query(func() interface{} { return screenText(s, area) }), "┌ ab日… ─┐"; g != e {
+		t.Errorf("\n%q\n%q", g, e)
+	}
+
+	app.PostWait(func() { c.SetTitleEllipsis(false) })
+	if g, e := app.Query(func() interface{} { return screenText(s, area) }), "┌ ab日本語"; g != e {
+		t.Errorf("\n%q\n%q", g, e)
+	}
+}
+
+func TestContextClick(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var a []string
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c := d.Root().NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		c.OnContextClick(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+			a = append(a, fmt.Sprintf("context %v", winPos))
+			return true
+		}, nil)
+		c.OnClick(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+			a = append(a, fmt.Sprintf("click %v", winPos))
+			return true
+		}, nil)
+	})
+	g := app.Query(func() interface{} {
+		r := app.Desktop().Root()
+		r.click(tcell.Button3, Position{12, 7}, 0)
+		r.click(tcell.Button1, Position{12, 7}, 0)
+		r.click(tcell.Button3, Position{12, 7}, tcell.ModShift)
+		r.click(tcell.Button2, Position{12, 7}, 0)
+		r.click(tcell.Button3, Position{10, 5}, 0)
+		r.click(tcell.Button3, Position{40, 20}, 0)
+		return strings.Join(a, "|")
+	})
+	if e := "context {1 1}|click {1 1}|click {1 1}|click {1 1}|context {0 0}"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestKeyboardMoveResize(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c *Window
+	keys := 0
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c = d.Root().NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		c.OnKey(func(w *Window, prev OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+			keys++
+			return true
+		}, nil)
+		c.SetMinSize(Size{5, 5})
+		c.SetFocus(true)
+	})
+	get := func(f func(), k ...tcell.Key) string {
+		return app.Query(func() interface{} {
+			f()
+			for _, k := range k {
+				mod := tcell.ModNone
+				if k < 0 {
+					k = -k
+					mod = tcell.ModShift
+				}
+				app.onKey.handle(nil, k, mod, 0)
+			}
+			return fmt.Sprint(c.Position(), c.Size(), keys)
+		}).(string)
+	}
+
+	for i, v := range []struct {
+		f    func()
+		keys []tcell.Key
+		e    string
+	}{
+		{func() {}, []tcell.Key{tcell.KeyRight}, "{10 5} {20 10} 1"},
+		{func() { c.BeginKeyboardMove() }, []tcell.Key{tcell.KeyRight, tcell.KeyRight, tcell.KeyDown, tcell.KeyRune}, "{12 6} {20 10} 1"},
+		{func() { c.BeginKeyboardResize() }, []tcell.Key{tcell.KeyLeft}, "{11 6} {20 10} 1"},
+		{func() {}, []tcell.Key{tcell.KeyEnter}, "{11 6} {20 10} 1"},
+		{func() {}, []tcell.Key{tcell.KeyRight}, "{11 6} {20 10} 2"},
+		{func() { c.BeginKeyboardMove() }, []tcell.Key{tcell.KeyLeft, tcell.KeyUp, tcell.KeyEscape}, "{11 6} {20 10} 2"},
+		{func() { c.BeginKeyboardResize() }, []tcell.Key{tcell.KeyLeft, tcell.KeyDown}, "{11 6} {19 11} 2"},
+		{func() {}, []tcell.Key{-tcell.KeyLeft, -tcell.KeyUp}, "{10 5} {20 12} 2"},
+		{func() {}, []tcell.Key{tcell.KeyEscape}, "{11 6} {20 10} 2"},
+		{func() { c.BeginKeyboardResize() }, nil, "{11 6} {20 10} 2"},
+	} {
+		if g := get(v.f, v.keys...); g != v.e {
+			t.Fatalf("%v: %s %s", i, g, v.e)
+		}
+	}
+	k := []tcell.Key{}
+	for i := 0; i < 20; i++ {
+		k = append(k, -tcell.KeyRight)
+	}
+	if g, e := get(func() {}, append(k, tcell.KeyEnter, tcell.KeyLeft)...), "{26 6} {5 10} 3"; g != e {
+		t.Fatalf("%s %s", g, e)
+	}
+
+	// The operation focuses the window and ending it removes only its own
+	// handler, even if other handlers were added meanwhile.
+	extra := 0
+	begin := func() {
+		c.Parent().NewChild(Rectangle{Size: Size{5, 5}}).SetFocus(true)
+		c.BeginKeyboardMove()
+		c.OnKey(func(w *Window, prev OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+			extra++
+			return prev(w, nil, key, mod, r)
+		}, nil)
+	}
+	if g, e := get(begin, tcell.KeyRight, tcell.KeyEnter, tcell.KeyLeft), "{27 6} {5 10} 4"; g != e {
+		t.Fatalf("%s %s", g, e)
+	}
+
+	if g, e := app.Query(func() interface{} { return fmt.Sprint(extra, c.Focus(), c.keyboardMode) }), "3 true false"; g != e {
+		t.Fatalf("%s %s", g, e)
+	}
+}
+
+func TestFocusNextPrev(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var d *Desktop
+	state := func(f func()) string {
+		return app.Query(func() interface{} {
+			f()
+			var a []string
+			r := d.Root()
+			for i := 0; i < r.Children(); i++ {
+				a = append(a, r.Child(i).Title())
+			}
+			fw := "-"
+			if w := d.FocusedWindow(); w != nil {
+				fw = w.Title()
+			}
+			return strings.Join(a, "") + " " + fw
+		}).(string)
+	}
+
+	app.PostWait(func() {
+		d = app.NewDesktop()
+		app.SetDesktop(d)
+	})
+	if g, e := state(func() { d.FocusNext(); d.FocusPrev() }), " -"; g != e {
+		t.Fatalf("%q %q", g, e)
+	}
+
+	app.PostWait(func() {
+		for i := 0; i < 3; i++ {
+			d.Root().NewChild(Rectangle{Position{i, i}, Size{10, 5}}).SetTitle(fmt.Sprint(i))
+		}
+	})
+	for i, v := range []struct {
+		f func()
+		e string
+	}{
+		{func() {}, "012 -"},
+		{d.FocusNext, "120 0"},
+		{d.FocusNext, "201 1"},
+		{d.FocusNext, "012 2"},
+		{d.FocusPrev, "201 1"},
+		{d.FocusPrev, "120 0"},
+		{d.FocusPrev, "012 2"},
+	} {
+		if g := state(v.f); g != v.e {
+			t.Fatalf("%v: %q %q", i, g, v.e)
+		}
+	}
+}
+
+func TestTabIndex(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var d *Desktop
+	var c []*Window
+	app.PostWait(func() {
+		d = app.NewDesktop()
+		app.SetDesktop(d)
+		for i, v := range []int{2, 0, -1, 1, 0} {
+			w := d.Root().NewChild(Rectangle{Position{i, i}, Size{10, 5}})
+			w.SetTitle(fmt.Sprint(i))
+			w.SetTabIndex(v)
+			c = append(c, w)
+		}
+	})
+	focus := func(f func(), n int) string {
+		return app.Query(func() interface{} {
+			var a []string
+			for i := 0; i < n; i++ {
+				f()
+				a = append(a, d.FocusedWindow().Title())
+			}
+			return strings.Join(a, "")
+		}).(string)
+	}
+	if g, e := focus(func() { d.FocusNext() }, 8), "14301430"; g != e {
+		t.Fatalf("%q %q", g, e)
+	}
+
+	if g, e := focus(func() { d.FocusPrev() }, 8), "34103410"; g != e {
+		t.Fatalf("%q %q", g, e)
+	}
+
+	if g, e := app.Query(func() interface{} {
+		c[2].SetFocus(true)
+		d.FocusNext()
+		a := d.FocusedWindow().Title()
+		c[3].SetVisible(false)
+		d.FocusNext()
+		return a + d.FocusedWindow().Title() + fmt.Sprint(c[2].TabIndex())
+	}), "10-1"; g != e {
+		t.Fatalf("%q %q", g, e)
+	}
+}
+
+func TestVisible(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var d *Desktop
+	var c []*Window
+	var events []string
+	clicks := 0
+	app.PostWait(func() {
+		d = app.NewDesktop()
+		app.SetDesktop(d)
+		for i := 0; i < 3; i++ {
+			w := d.Root().NewChild(Rectangle{Position{4 * i, 0}, Size{3, 3}})
+			w.SetTitle(fmt.Sprint(i))
+			w.OnSetVisible(func(w *Window, prev OnSetBoolHandler, dst *bool, src bool) {
+				prev(w, nil, dst, src)
+				events = append(events, fmt.Sprint(w.Title(), " ", src))
+			}, nil)
+			c = append(c, w)
+		}
+		c[1].OnClick(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+			clicks++
+			return true
+		}, nil)
+	})
+	area := Rectangle{Size: Size{11, 3}}
+	if g, e := app.Query(func() interface{} { return screenText(s, area) }), "┌─┐ ┌─┐ ┌─┐\n│ │ │ │ │ │\n└─┘ └─┘ └─┘"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	if g, e := app.Query(func() interface{} {
+		c[1].SetFocus(true)
+		c[1].SetVisible(false)
+		c[1].SetVisible(false)
+		d.Root().click(tcell.Button1, Position{5, 1}, 0)
+		return fmt.Sprint(c[1].Visible(), c[1].Focus(), clicks)
+	}), "false false 0"; g != e {
+		t.Fatalf("%q %q", g, e)
+	}
+
+	if g, e := app.Query(func() interface{} { return screenText(s, area) }), "┌─┐     ┌─┐\n│ │     │ │\n└─┘     └─┘"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	if g, e := app.Query(func() interface{} {
+		var a []string
+		for i := 0; i < 4; i++ {
+			d.FocusNext()
+			a = append(a, d.FocusedWindow().Title())
+		}
+		for i := 0; i < 4; i++ {
+			d.FocusPrev()
+			a = append(a, d.FocusedWindow().Title())
+		}
+		return strings.Join(a, "")
+	}), "02020202"; g != e {
+		t.Fatalf("%q %q", g, e)
+	}
+
+	if g, e := app.Query(func() interface{} {
+		c[1].SetVisible(true)
+		d.Root().click(tcell.Button1, Position{5, 1}, 0)
+		return fmt.Sprint(c[1].Visible(), clicks, events)
+	}), "true 1 [1 false 1 true]"; g != e {
+		t.Fatalf("%q %q", g, e)
+	}
+
+	if g, e := app.Query(func() interface{} { return screenText(s, area) }), "┌─┐ ┌─┐ ┌─┐\n│ │ │ │ │ │\n└─┘ └─┘ └─┘"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	// Hiding the parent of the focused window removes the focus.
+	if g, e := app.Query(func() interface{} {
+		gc := c[2].NewChild(Rectangle{Size: Size{1, 1}})
+		gc.SetFocus(true)
+		f := d.FocusedWindow() == gc
+		c[2].SetVisible(false)
+		return fmt.Sprint(f, gc.Focus(), d.FocusedWindow() == nil)
+	}), "true false true"; g != e {
+		t.Fatalf("%q %q", g, e)
+	}
+}
+
+func TestRectangleGeometry(t *testing.T) {
+	z := Rectangle{}
+	a := Rectangle{Position{1, 2}, Size{10, 6}}
+	b := Rectangle{Position{5, 4}, Size{10, 10}}
+	c := Rectangle{Position{20, 20}, Size{2, 2}}
+	e := Rectangle{Position{3, 3}, Size{0, 5}}
+
+	for i, v := range []struct {
+		r, s Rectangle
+		e    Rectangle
+	}{
+		{z, z, z},
+		{z, a, a},
+		{a, z, a},
+		{a, e, a},
+		{e, a, a},
+		{a, a, a},
+		{a, b, Rectangle{Position{1, 2}, Size{14, 12}}},
+		{b, a, Rectangle{Position{1, 2}, Size{14, 12}}},
+		{a, c, Rectangle{Position{1, 2}, Size{21, 20}}},
+	} {
+		if g, e := v.r.Union(v.s), v.e; g != e {
+			t.Errorf("Union %v: %v %v", i, g, e)
+		}
+	}
+
+	for i, v := range []struct {
+		r, s Rectangle
+		e    Rectangle
+		ok   bool
+	}{
+		{z, z, z, false},
+		{z, a, z, false},
+		{a, z, z, false},
+		{a, e, z, false},
+		{a, a, a, true},
+		{a, b, Rectangle{Position{5, 4}, Size{6, 4}}, true},
+		{b, a, Rectangle{Position{5, 4}, Size{6, 4}}, true},
+		{a, c, z, false},
+		{a, Rectangle{Position{11, 2}, Size{1, 1}}, z, false},
+	} {
+		g, ok := v.r.Intersection(v.s)
+		if g != v.e || ok != v.ok {
+			t.Errorf("Intersection %v: %v %v, %v %v", i, g, ok, v.e, v.ok)
+		}
+	}
+
+	for i, v := range []struct {
+		r Rectangle
+		e Position
+	}{
+		{z, Position{}},
+		{a, Position{6, 5}},
+		{Rectangle{Position{-3, -3}, Size{3, 3}}, Position{-2, -2}},
+		{Rectangle{Position{0, 0}, Size{1, 1}}, Position{0, 0}},
+	} {
+		if g, e := v.r.Center(), v.e; g != e {
+			t.Errorf("Center %v: %v %v", i, g, e)
+		}
+	}
+
+	for i, v := range []struct {
+		r      Rectangle
+		dx, dy int
+		e      Rectangle
+	}{
+		{z, 0, 0, z},
+		{z, 1, 1, Rectangle{Position{1, 1}, Size{0, 0}}},
+		{z, -1, -2, Rectangle{Position{-1, -2}, Size{2, 4}}},
+		{a, 0, 0, a},
+		{a, 1, 2, Rectangle{Position{2, 4}, Size{8, 2}}},
+		{a, 3, 3, Rectangle{Position{4, 5}, Size{4, 0}}},
+		{a, 6, 0, Rectangle{Position{7, 2}, Size{0, 6}}},
+		{a, -1, -1, Rectangle{Position{0, 1}, Size{12, 8}}},
+	} {
+		if g, e := v.r.Inset(v.dx, v.dy), v.e; g != e {
+			t.Errorf("Inset %v: %v %v", i, g, e)
+		}
+	}
+}
+
+func TestPositionRectangleContains(t *testing.T) {
+	if g, e := (Position{1, 2}).Add(Position{10, 20}), (Position{11, 22}); g != e {
+		t.Fatal(g, e)
+	}
+
+	if g, e := (Position{1, 2}).Sub(Position{10, 20}), (Position{-9, -18}); g != e {
+		t.Fatal(g, e)
+	}
+
+	a := Rectangle{Position{1, 2}, Size{10, 6}}
+	for i, v := range []struct {
+		r, s Rectangle
+		e    bool
+	}{
+		{Rectangle{}, Rectangle{}, false},
+		{a, Rectangle{}, false},
+		{a, a, true},
+		{a, Rectangle{Position{1, 2}, Size{1, 1}}, true},
+		{a, Rectangle{Position{10, 7}, Size{1, 1}}, true},
+		{a, Rectangle{Position{10, 7}, Size{2, 1}}, false},
+		{a, Rectangle{Position{10, 7}, Size{1, 2}}, false},
+		{a, Rectangle{Position{0, 2}, Size{1, 1}}, false},
+		{a, Rectangle{Position{1, 1}, Size{1, 1}}, false},
+		{a, Rectangle{Position: Position{5, 5}}, true},
+		{a, Rectangle{Position: Position{11, 5}}, false},
+		{a, Rectangle{Position{0, 0}, Size{20, 20}}, false},
+		{Rectangle{Position{3, 4}, Size{2, 2}}, a, false},
+	} {
+		if g, e := v.r.Contains(v.s), v.e; g != e {
+			t.Errorf("%v: %v %v", i, g, e)
+		}
+	}
+}
+
+func TestInactiveStyles(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{
+		ChildWindow: WindowStyle{
+			Border:         Style{Foreground: tcell.ColorWhite, Background: tcell.ColorBlue},
+			BorderInactive: Style{Foreground: tcell.ColorGray, Background: tcell.ColorBlack},
+			Title:          Style{Foreground: tcell.ColorYellow, Background: tcell.ColorBlue},
+			TitleInactive:  Style{Foreground: tcell.ColorSilver, Background: tcell.ColorBlack},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c *Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c = d.Root().NewChild(Rectangle{Position{0, 0}, Size{10, 3}})
+		c.SetTitle("foo")
+	})
+	// Styles of the upper left corner, the title and the left border.
+	styles := func() []Style {
+		var r []Style
+		for _, p := range []Position{{0, 0}, {2, 0}, {0, 1}} {
+			_, _, st, _ := s.GetContent(p.X, p.Y)
+			r = append(r, NewStyle(st))
+		}
+		return r
+	}
+	check := func(focus bool, border, title Style) {
+		g := app.Query(func() interface{} {
+			c.SetFocus(focus)
+			return nil
+		})
+		_ = g
+		st := app.Query(func() interface{} { return styles() }).([]Style)
+		if st[0] != border || st[2] != border || st[1] != title {
+			t.Fatalf("focus %v: %+v", focus, st)
+		}
+	}
+	theme := app.ChildWindowStyle()
+	check(false, theme.BorderInactive, theme.TitleInactive)
+	check(true, theme.Border, theme.Title)
+	check(false, theme.BorderInactive, theme.TitleInactive)
+}
+
+func TestSelectionWideRunes(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	const y = 5
+	var d *Desktop
+	app.Query(func() interface{} {
+		d = app.NewDesktop()
+		app.SetDesktop(d)
+		d.Show()
+		return nil
+	})
+
+	// Fill the row with double width glyphs starting at even columns. The
+	// continuation cells keep a stale double width rune, like they would
+	// after the row content was shifted by one cell.
+	app.Query(func() interface{} {
+		for x := 1; x < 20; x += 2 {
+			s.SetContent(x, y, '界', nil, tcell.StyleDefault)
+		}
+		for x := 0; x < 20; x += 2 {
+			s.SetContent(x, y, '世', nil, tcell.StyleDefault)
+		}
+		return nil
+	})
+
+	reversed := func() (r []int) {
+		app.Query(func() interface{} {
+			for x := 0; x < 20; x++ {
+				_, _, style, _ := s.GetContent(x, y)
+				if _, _, attr := style.Decompose(); attr&tcell.AttrReverse != 0 {
+					r = append(r, x)
+				}
+			}
+			return nil
+		})
+		return r
+	}
+
+	for _, v := range []struct {
+		x, w int
+		e    string
+	}{
+		{3, 4, "[2 4 6]"},
+		{4, 4, "[4 6]"},
+		{5, 1, "[4]"},
+		{4, 5, "[4 6 8]"},
+	} {
+		app.Query(func() interface{} {
+			d.SetSelection(Rectangle{Position{v.x, y}, Size{v.w, 1}})
+			return nil
+		})
+		if g, e := fmt.Sprint(reversed()), v.e; g != e {
+			t.Fatalf("selection at %v width %v: got %v, expected %v", v.x, v.w, g, e)
+		}
+	}
+
+	app.Query(func() interface{} {
+		d.SetSelection(Rectangle{})
+		return nil
+	})
+	if g := reversed(); len(g) != 0 {
+		t.Fatalf("selection removed: got %v", g)
+	}
+}
+
+func TestRenderToCells(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	text := func(cells [][]Cell) string {
+		var a []string
+		for _, row := range cells {
+			var b []rune
+			for x := 0; x < len(row); x++ {
+				r := row[x].Mainc
+				if r == 0 {
+					r = '.'
+				}
+				b = append(b, r)
+			}
+			a = append(a, string(b))
+		}
+		return strings.Join(a, "\n")
+	}
+
+	g := app.Query(func() interface{} {
+		// The desktop is never shown.
+		d := app.NewDesktop()
+		w := d.Root().NewChild(Rectangle{Position{3, 2}, Size{12, 5}})
+		w.SetTitle("abc")
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			w.Printf(0, 0, w.ClientAreaStyle(), "hello")
+		}, nil)
+		w.NewChild(Rectangle{Position{6, 1}, Size{4, 2}})
+		return text(w.RenderToCells())
+	}).(string)
+	if e := strings.Join([]string{
+		"┌ abc ─────┐",
+		"│hello     │",
+		"│      ┌──┐│",
+		"│      └──┘│",
+		"└──────────┘",
+	}, "\n"); g != e {
+		t.Fatalf("got\n%s\nexpected\n%s", g, e)
+	}
+
+	g = app.Query(func() interface{} { return screenText(s, Rectangle{Size: Size{20, 10}}) }).(string)
+	if strings.TrimSpace(g) != "" {
+		t.Fatalf("screen was updated:\n%s", g)
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var d *Desktop
+	app.Query(func() interface{} {
+		d = app.NewDesktop()
+		d.Root().OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			w.Printf(1, 0, Style{Foreground: tcell.ColorRed, Background: tcell.ColorNavy, Attr: tcell.AttrBold}, "ab")
+			w.Printf(1, 1, w.ClientAreaStyle(), "世x")
+		}, nil)
+		return nil
+	})
+
+	shot := func(f Format) (string, error) {
+		var b strings.Builder
+		err, _ := app.Query(func() interface{} { return d.Screenshot(&b, f) }).(error)
+		return b.String(), err
+	}
+
+	g, err := shot(FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := " ab\n 世x\n\n"; !strings.HasPrefix(g, e) {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	if g, err = shot(FormatANSI); err != nil {
+		t.Fatal(err)
+	}
+
+	row := strings.Split(g, "\n")[0]
+	if e := "\x1b[0;30;40m \x1b[0;1;91;44mab\x1b[0;30;40m" + strings.Repeat(" ", 77) + "\x1b[0m"; row != e {
+		t.Fatalf("got %q, expected %q", row, e)
+	}
+
+	if _, err = shot(Format(-1)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestOnMouse(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var a []string
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c := d.Root().NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		c.OnClick(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+			a = append(a, fmt.Sprintf("click %v", winPos))
+			return true
+		}, nil)
+		app.OnMouse(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+			a = append(a, fmt.Sprintf("filter %v", screenPos))
+			return screenPos.X > 15
+		}, nil)
+		return nil
+	})
+	s.PostEvent(newEventMouse(mouseClick, tcell.Button1, 0, Position{12, 7}))
+	s.PostEvent(newEventMouse(mouseClick, tcell.Button1, 0, Position{16, 7}))
+	app.Query(func() interface{} {
+		app.RemoveOnMouse()
+		return nil
+	})
+	s.PostEvent(newEventMouse(mouseClick, tcell.Button1, 0, Position{16, 7}))
+	g := app.Query(func() interface{} { return strings.Join(a, "|") })
+	if e := "filter {12 7}|click {1 1}|filter {16 7}|click {5 1}"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestMouseMoveBorder(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var a []string
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c := d.Root().NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		c.OnMouseMove(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+			a = append(a, fmt.Sprintf("client %v", winPos))
+			return true
+		}, nil)
+		c.OnMouseMoveBorder(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+			a = append(a, fmt.Sprintf("border %v %v", button == tcell.WheelUp, winPos))
+			return true
+		}, nil)
+		return nil
+	})
+	g := app.Query(func() interface{} {
+		r := app.Desktop().Root()
+		r.mouseMove(tcell.WheelUp, Position{29, 7}, 0)
+		r.mouseMove(tcell.ButtonNone, Position{12, 7}, 0)
+		return strings.Join(a, "|")
+	})
+	if e := "border true {19 2}|client {1 1}"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestPrintfPosition(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var a []string
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		w := d.Root().NewChild(Rectangle{Position{3, 2}, Size{20, 5}})
+		a = append(a, fmt.Sprint(w.Printf(1, 2, w.ClientAreaStyle(), "ignored")))
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			x, y := w.Printf(0, 0, w.ClientAreaStyle(), "ab世")
+			a = append(a, fmt.Sprint(x, y))
+			x, y = w.Printf(x, y+1, w.ClientAreaStyle(), "cd")
+			a = append(a, fmt.Sprint(x, y))
+		}, nil)
+		w.RenderToCells()
+		return nil
+	})
+	if g, e := strings.Join(a, "|"), "1 2|4 0|6 1"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestPrintfWidth(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var a []string
+	g := app.Query(func() interface{} {
+		d := app.NewDesktop()
+		w := d.Root().NewChild(Rectangle{Position{0, 0}, Size{16, 8}})
+		w.SetBorderBottom(0)
+		w.SetBorderLeft(0)
+		w.SetBorderRight(0)
+		w.SetBorderTop(0)
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			for i, v := range []struct {
+				x, width int
+				s        string
+			}{
+				{0, 4, "abcdef"},
+				{1, 4, "a世界"},   // Wide rune at the boundary.
+				{0, 5, "a世界"},   // Wide rune fits exactly.
+				{0, 10, "a\tb"}, // Tab within the budget.
+				{2, 4, "a\tb"},  // Tab crosses the boundary.
+				{0, 0, "abc"},
+				{0, 3, "ábcd"}, // Combining char.
+			} {
+				x, y := w.PrintfWidth(v.x, i, v.width, w.ClientAreaStyle(), "%s", v.s)
+				a = append(a, fmt.Sprint(x, y))
+			}
+		}, nil)
+		var b []string
+		for _, row := range w.RenderToCells() {
+			var r []rune
+			for x := 0; x < len(row); x++ {
+				switch c := row[x]; {
+				case c.Mainc == 0:
+					r = append(r, '.')
+				default:
+					r = append(r, c.Mainc)
+					r = append(r, c.Combc...)
+					x += runewidth.RuneWidth(c.Mainc) - 1
+				}
+			}
+			b = append(b, strings.TrimRight(string(r), ". "))
+		}
+		return strings.Join(b, "\n")
+	}).(string)
+	if e := strings.Join([]string{
+		"abcd",
+		" a世",
+		"a世界",
+		"a       b",
+		"  a",
+		"",
+		"ábc",
+		"",
+	}, "\n"); g != e {
+		t.Errorf("got\n%s\nexpected\n%s", g, e)
+	}
+	if g, e := strings.Join(a, "|"), "4 0|4 1|5 2|9 3|6 4|0 5|3 6"; g != e {
+		t.Errorf("got %q, expected %q", g, e)
+	}
+}
+
+func TestWrapWords(t *testing.T) {
+	for i, v := range []struct {
+		s     string
+		x     int
+		width int
+		e     string
+	}{
+		{"", 0, 10, `[""]`},
+		{"foo bar baz", 0, 7, `["foo bar" "baz"]`},
+		{"foo bar baz", 0, 6, `["foo" "bar" "baz"]`},
+		{"abcdefghij xy", 0, 4, `["abcd" "efgh" "ij" "xy"]`},
+		{"a\nb c", 0, 10, `["a" "b c"]`},
+		{"a\tb", 0, 10, `["a       b"]`},
+		{"a\tb", 4, 10, `["a   b"]`},
+		{"ab\rc d", 0, 3, `["ab\rc d"]`},
+		{"世界 世界", 0, 4, `["世界" "世界"]`},
+		{"世界世", 0, 3, `["世" "界" "世"]`},
+	} {
+		if g, e := fmt.Sprintf("%q", wrapWords(v.s, v.x, v.width)), v.e; g != e {
+			t.Errorf("%v: got %s, expected %s", i, g, e)
+		}
+	}
+}
+
+func TestRefreshRate(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	painted := make(chan struct{}, 100)
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w := d.Root().NewChild(Rectangle{Position{1, 1}, Size{10, 5}})
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			select {
+			case painted <- struct{}{}:
+			default:
+			}
+		}, nil)
+		w.SetAnimated(true)
+		app.SetRefreshRate(100)
+		return nil
+	})
+	for i := 0; i < 3; i++ {
+		select {
+		case <-painted:
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+
+	app.Query(func() interface{} {
+		app.SetRefreshRate(0)
+		return nil
+	})
+	for len(painted) != 0 {
+		<-painted
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(painted); n != 0 {
+		t.Fatalf("painted %v times after stopping animation", n)
+	}
+}
+
+func TestRefreshRateQueueFull(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	painted := make(chan struct{}, 1)
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w := d.Root().NewChild(Rectangle{Position{1, 1}, Size{10, 5}})
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			select {
+			case painted <- struct{}{}:
+			default:
+			}
+		}, nil)
+		w.SetAnimated(true)
+		app.SetRefreshRate(100)
+		for i := 0; i < 20; i++ { // Fill the event queue.
+			app.Post(func() {})
+		}
+		time.Sleep(50 * time.Millisecond) // Let the refreshes be dropped.
+	})
+	for len(painted) != 0 {
+		<-painted
+	}
+	select {
+	case <-painted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no refresh after the event queue was full")
+	}
+	app.PostWait(func() { app.SetRefreshRate(0) })
+}
+
+func TestBatchPaint(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var w *Window
+	paints := 0
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w = d.Root().NewChild(Rectangle{Position{1, 1}, Size{20, 10}})
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			paints++
+		}, nil)
+		return nil
+	})
+
+	const n = 10
+	count := func(batch bool) int {
+		app.Query(func() interface{} {
+			app.SetBatchPaint(batch)
+			paints = 0
+			for i := 0; i < n; i++ {
+				i := i
+				app.Post(func() { w.InvalidateClientArea(Rectangle{Position{i, 0}, Size{1, 1}}) })
+			}
+			return nil
+		})
+		app.Query(func() interface{} { return nil }) // Let the posted flush execute.
+		return app.Query(func() interface{} { return paints }).(int)
+	}
+	unbatched := count(false)
+	batched := count(true)
+	t.Logf("paint calls: unbatched %v, batched %v", unbatched, batched)
+	if unbatched != n || batched >= unbatched {
+		t.Fatalf("unbatched %v, batched %v", unbatched, batched)
+	}
+}
+
+func TestBatchPaintQueueFull(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var w *Window
+	paints := 0
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w = d.Root().NewChild(Rectangle{Position{1, 1}, Size{20, 10}})
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			paints++
+		}, nil)
+		app.SetBatchPaint(true)
+		return nil
+	})
+
+	app.Query(func() interface{} {
+		for i := 0; i < 20; i++ { // Fill the event queue.
+			app.Post(func() {})
+		}
+		paints = 0
+		w.InvalidateClientArea(Rectangle{Size: Size{1, 1}})
+		return nil
+	})
+	// The flush could not be posted, the area was painted immediately.
+	g := app.Query(func() interface{} { return fmt.Sprint(paints, w.Desktop().flushPending) }).(string)
+	if e := "1 0"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.Query(func() interface{} {
+		paints = 0
+		w.InvalidateClientArea(Rectangle{Size: Size{1, 1}})
+		return nil
+	})
+	app.Query(func() interface{} { return nil }) // Let the posted flush execute.
+	if g, e := app.Query(func() interface{} { return paints }).(int), 1; g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+}
+
+func TestFrameMetrics(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var w *Window
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w = d.Root().NewChild(Rectangle{Position{1, 1}, Size{20, 10}})
+		return nil
+	})
+	m0 := app.Query(func() interface{} { return app.Metrics() }).(FrameMetrics)
+	app.Query(func() interface{} {
+		w.Invalidate(Rectangle{Position{2, 3}, Size{4, 2}})
+		return nil
+	})
+	m := app.Query(func() interface{} { return app.Metrics() }).(FrameMetrics)
+	if g, e := m.Frames, m0.Frames+1; g != e {
+		t.Errorf("frames: got %v, expected %v", g, e)
+	}
+	if g, e := m.Cells, 8; g < e { // Overlapping windows write some cells more than once.
+		t.Errorf("cells: got %v, expected at least %v", g, e)
+	}
+	if m.PeakFrame < m.LastFrame {
+		t.Errorf("peak %v < last %v", m.PeakFrame, m.LastFrame)
+	}
+
+	m2 := app.Query(func() interface{} {
+		w.RenderToCells()
+		app.BeginUpdate()
+		w.Desktop().SetSelection(Rectangle{Position{2, 2}, Size{5, 3}})
+		app.EndUpdate()
+		return app.Metrics()
+	}).(FrameMetrics)
+	if g, e := m2.Cells, m.Cells; g != e {
+		t.Errorf("cells outside of a frame: got %v, expected %v", g, e)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	cursor := func() string {
+		x, y, visible := s.GetCursor()
+		return fmt.Sprint(x, y, visible)
+	}
+	var w *Window
+	var a []string
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		app.ShowCursor(3, 4)
+		a = append(a, cursor())
+		w = d.Root().NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		w.SetCursor(2, 1)
+		a = append(a, cursor())
+		w.SetPosition(Position{11, 5})
+		return nil
+	})
+	app.Query(func() interface{} {
+		a = append(a, cursor())
+		w.SetCursor(30, 1) // Outside of the client area.
+		a = append(a, cursor())
+		w.SetCursor(2, 1)
+		w.ForceClose()
+		a = append(a, cursor())
+		return nil
+	})
+	if g, e := strings.Join(a, "|"), "3 4 true|13 7 true|14 7 true|-1 -1 false|-1 -1 false"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestSelectionText(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	setup := func(d *Desktop) {
+		r := d.Root()
+		r.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			w.Printf(0, 1, w.ClientAreaStyle(), "ab世界cd")
+			w.Printf(0, 2, w.ClientAreaStyle(), "efgh")
+		}, nil)
+		r.Invalidate(r.Area())
+	}
+	var d, d2 *Desktop
+	app.Query(func() interface{} {
+		d = app.NewDesktop()
+		app.SetDesktop(d)
+		setup(d)
+		d2 = app.NewDesktop() // Not shown.
+		setup(d2)
+		return nil
+	})
+	for i, v := range []struct {
+		area Rectangle
+		e    string
+	}{
+		{Rectangle{}, ""},
+		{Rectangle{Position{0, 1}, Size{2, 1}}, "ab"},
+		{Rectangle{Position{3, 1}, Size{3, 2}}, "世界\nh"}, // Partially covered double width glyphs.
+		{Rectangle{Position{1, 0}, Size{10, 3}}, "\nb世界cd\nfgh"},
+	} {
+		for _, d := range []*Desktop{d, d2} {
+			g := app.Query(func() interface{} {
+				d.SetSelection(v.area)
+				return d.SelectionText()
+			}).(string)
+			if e := v.e; g != e {
+				t.Errorf("%v: got %q, expected %q", i, g, e)
+			}
+		}
+	}
+}
+
+func TestSnapAxis(t *testing.T) {
+	edges := []int{0, 80, 30, 50}
+	for i, v := range []struct {
+		pos, size, dist, e int
+	}{
+		{2, 10, 0, 2},   // Disabled.
+		{2, 10, 2, 0},   // Left edge to parent.
+		{3, 10, 2, 3},   // Too far.
+		{69, 10, 2, 70}, // Right edge to parent.
+		{19, 10, 2, 20}, // Right edge to sibling left edge.
+		{51, 10, 2, 50}, // Left edge to sibling right edge.
+		{28, 21, 2, 29}, // Both edges within dist, nearest wins.
+		{-1, 10, 2, 0},  // From outside.
+	} {
+		if g, e := snapAxis(v.pos, v.size, v.dist, edges), v.e; g != e {
+			t.Errorf("%v: got %v, expected %v", i, g, e)
+		}
+	}
+}
+
+func TestConfineToParent(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		p := d.Root().NewChild(Rectangle{Position{0, 0}, Size{42, 22}}) // Client area 40x20.
+		c := p.NewChild(Rectangle{Position{5, 5}, Size{10, 6}})
+		c.SetConfineToParent(true)
+		for _, v := range []Position{{-20, -3}, {50, 30}, {3, 4}} {
+			c.SetPosition(v)
+			a = append(a, fmt.Sprint(c.Position()))
+		}
+		c.SetConfineFully(true)
+		a = append(a, fmt.Sprint(c.Position()))
+		c.SetPosition(Position{50, 30})
+		a = append(a, fmt.Sprint(c.Position()))
+		p.SetSize(Size{22, 12}) // Client area 20x10.
+		a = append(a, fmt.Sprint(c.Position()))
+		c.SetConfineToParent(false)
+		c.SetPosition(Position{-20, -3})
+		a = append(a, fmt.Sprint(c.Position()))
+		return strings.Join(a, "|")
+	}).(string)
+	if e := "{-6 0}|{36 19}|{3 4}|{3 4}|{30 14}|{10 4}|{-20 -3}"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestMovableResizable(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		c := r.NewChild(Rectangle{Position{2, 3}, Size{20, 10}})
+		c.SetCloseButton(true)
+		drag := func(p Position) int {
+			c.dragState = 0
+			r.drag(tcell.Button1, p, 0)
+			return c.dragState
+		}
+		title, bottom := Position{7, 3}, Position{7, 12}
+		a = append(a, fmt.Sprint(drag(title) == dragPos, drag(bottom) == dragBottomSize))
+		c.SetMovable(false)
+		c.SetResizable(false)
+		a = append(a, fmt.Sprint(c.Movable(), c.Resizable(), drag(title), drag(bottom)))
+		c.BeginKeyboardMove()
+		c.BeginKeyboardResize()
+		a = append(a, fmt.Sprint(c.keyboardMode))
+		r.click(tcell.Button1, c.closeButtonArea().Position.Add(c.Position()), 0)
+		a = append(a, fmt.Sprint(r.Children()))
+		return strings.Join(a, "|")
+	}).(string)
+	if e := "true true|false false 0 0|false|0"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestResizeEdge(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c := d.Root().NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		for _, v := range []struct {
+			e     Edge
+			delta int
+		}{
+			{EdgeLeft, 2},
+			{EdgeTopRight, 1},
+			{EdgeBottom, -3},
+			{EdgeLeft, -100},
+			{EdgeBottomRight, 4},
+		} {
+			c.ResizeEdge(v.e, v.delta)
+			a = append(a, fmt.Sprint(c.Position(), c.Size()))
+		}
+		c.SetMinSize(Size{5, 3})
+		c.ResizeEdge(EdgeTop, -100)
+		a = append(a, fmt.Sprint(c.Position(), c.Size()))
+		return strings.Join(a, "\n")
+	}).(string)
+	if e := `{8 5} {22 10}
+{8 4} {23 11}
+{8 4} {23 8}
+{29 4} {2 8}
+{29 4} {6 12}
+{29 13} {6 3}`; g != e {
+		t.Fatalf("got\n%s\nexpected\n%s", g, e)
+	}
+}
+
+func TestApplyDrag(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		for _, v := range []struct {
+			from, to Position
+		}{
+			{Position{15, 5}, Position{18, 7}},   // dragPos
+			{Position{29, 10}, Position{33, 10}}, // dragRightSize
+			{Position{10, 10}, Position{14, 10}}, // dragLeftSize
+			{Position{10, 10}, Position{50, 10}}, // dragLeftSize, over-drag
+			{Position{20, 14}, Position{20, 16}}, // dragBottomSize
+			{Position{29, 14}, Position{31, 15}}, // dragLRC
+			{Position{29, 5}, Position{31, 3}},   // dragURC
+			{Position{10, 14}, Position{8, 16}},  // dragLLC
+			{Position{10, 5}, Position{12, 6}},   // dragULC
+			{Position{10, 5}, Position{60, 30}},  // dragULC, over-drag
+		} {
+			c := r.NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+			r.drag(tcell.Button1, v.from, 0)
+			r.mouseMove(tcell.Button1, v.to, 0)
+			ok := c.applyDrag(v.to)
+			p, sz := c.Position(), c.Size()
+			r.drop(tcell.Button1, v.to, 0)
+			ok2 := c.applyDrag(v.to)
+			a = append(a, fmt.Sprint(ok, p, sz, p == c.Position() && sz == c.Size(), ok2))
+			c.ForceClose()
+		}
+		return strings.Join(a, "\n")
+	}).(string)
+	if e := `true {13 7} {20 10} true false
+true {10 5} {24 10} true false
+true {14 5} {16 10} true false
+true {29 5} {1 10} true false
+true {10 5} {20 12} true false
+true {10 5} {22 11} true false
+true {10 3} {22 12} true false
+true {8 5} {22 12} true false
+true {12 6} {18 9} true false
+true {29 14} {1 1} true false`; g != e {
+		t.Fatalf("got\n%s\nexpected\n%s", g, e)
+	}
+}
+
+func TestOnResize(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c := d.Root().NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		finalized := false
+		c.OnResize(func(w *Window, old, new Size) {
+			a = append(a, fmt.Sprint(w == c, old, new, w.Size()))
+		}, func() { finalized = true })
+		c.SetSize(Size{30, 12})
+		c.SetSize(Size{30, 12})
+		c.SetClientSize(Size{10, 5})
+		c.SetSize(Size{0, 0})
+		c.RemoveOnSetSize()
+		c.SetSize(Size{5, 5})
+		a = append(a, fmt.Sprint(finalized))
+		return strings.Join(a, "\n")
+	}).(string)
+	if e := `true {20 10} {30 12} {30 12}
+true {30 12} {12 7} {12 7}
+true {12 7} {2 2} {2 2}
+true`; g != e {
+		t.Fatalf("got\n%s\nexpected\n%s", g, e)
+	}
+}
+
+func TestOnFocusChanged(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		c1 := r.NewChild(Rectangle{Position{1, 1}, Size{10, 5}})
+		c1.SetTitle("c1")
+		c2 := r.NewChild(Rectangle{Position{20, 1}, Size{10, 5}})
+		c2.SetTitle("c2")
+		name := func(w *Window) string {
+			if w == nil {
+				return "nil"
+			}
+
+			return w.Title()
+		}
+		d.OnFocusChanged(func(old, new *Window) {
+			a = append(a, name(old)+"->"+name(new))
+		}, nil)
+		c1.SetFocus(true)
+		c1.SetFocus(true)
+		c2.SetFocus(true)
+		d.SetFocusedWindow(nil)
+		d.RemoveOnSetFocusedWindow()
+		c1.SetFocus(true)
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "nil->c1 c1->c2 c2->nil"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestDesktops(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		app.NextDesktop()
+		app.PrevDesktop()
+		a = append(a, fmt.Sprint(len(app.Desktops()), app.Desktop() == nil))
+		d0 := app.NewDesktop()
+		d1 := app.NewDesktop()
+		d2 := app.NewDesktop()
+		app.AddDesktop(d1)
+		index := func() int {
+			for i, v := range app.Desktops() {
+				if v == app.Desktop() {
+					return i
+				}
+			}
+			return -1
+		}
+		app.NextDesktop()
+		a = append(a, fmt.Sprint(len(app.Desktops()), index(), app.Desktop() == d0))
+		for _, f := range []func(){app.NextDesktop, app.NextDesktop, app.NextDesktop, app.PrevDesktop, app.PrevDesktop} {
+			f()
+			a = append(a, fmt.Sprint(index()))
+		}
+		app.SetDesktop(d2)
+		a = append(a, fmt.Sprint(index()))
+		app.RemoveDesktop(d1)
+		a = append(a, fmt.Sprint(len(app.Desktops()), index()))
+		app.RemoveDesktop(d2)
+		app.RemoveDesktop(d2)
+		a = append(a, fmt.Sprint(len(app.Desktops()), index(), app.Desktop() == d2))
+		app.NextDesktop()
+		a = append(a, fmt.Sprint(index(), app.Desktop() == d0))
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "0 true 3 0 true 1 2 0 2 1 2 2 1 1 -1 true 0 true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestPrintfAlign(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var a []string
+	g := app.Query(func() interface{} {
+		d := app.NewDesktop()
+		w := d.Root().NewChild(Rectangle{Position{0, 0}, Size{14, 6}})
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			for i, v := range []struct {
+				right bool
+				s     string
+			}{
+				{true, "abc"},
+				{false, "ab"},
+				{true, "a世界"},
+				{false, "世界"},
+			} {
+				var x, y int
+				switch {
+				case v.right:
+					x, y = w.PrintfRight(i, w.ClientAreaStyle(), "%s", v.s)
+				default:
+					x, y = w.PrintfCenter(i, w.ClientAreaStyle(), "%s", v.s)
+				}
+				a = append(a, fmt.Sprint(x, y))
+			}
+		}, nil)
+		var b []string
+		for _, row := range w.RenderToCells()[1:5] {
+			var r []rune
+			for x := 1; x < len(row)-1; x++ {
+				c := row[x]
+				r = append(r, c.Mainc)
+				x += runewidth.RuneWidth(c.Mainc) - 1
+			}
+			b = append(b, "|"+string(r)+"|")
+		}
+		return strings.Join(b, "\n")
+	}).(string)
+	if e := strings.Join([]string{
+		"|         abc|",
+		"|     ab     |",
+		"|       a世界|",
+		"|    世界    |",
+	}, "\n"); g != e {
+		t.Errorf("got\n%s\nexpected\n%s", g, e)
+	}
+	if g, e := strings.Join(a, "|"), "12 0|7 1|12 2|8 3"; g != e {
+		t.Errorf("got %q, expected %q", g, e)
+	}
+}
+
+func TestInvalidateNested(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		w1 := r.NewChild(Rectangle{Position{10, 5}, Size{30, 15}})
+		w2 := w1.NewChild(Rectangle{Position{2, 2}, Size{10, 6}})
+		w3 := w2.NewChild(Rectangle{Position{1, 1}, Size{4, 3}})
+		r.BeginUpdate()
+		for _, f := range []func(){
+			func() {},
+			func() { w1.SetPosition(Position{20, 5}) },
+			func() { w2.SetOrigin(Position{1, 0}) },
+			func() { w2.SetSize(Size{4, 4}) },
+			func() { w2.SetPosition(Position{30, 30}) },
+		} {
+			f()
+			d.invalidated = nil
+			w3.Invalidate(w3.Area())
+			a = append(a, fmt.Sprint(d.invalidated))
+		}
+		r.EndUpdate()
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "[{{15 10} {4 3}}] [{{25 10} {4 3}}] [{{24 10} {4 3}}] [{{24 10} {2 1}}] []"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestPaintAllocs(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	paint := newPaintBenchmark(app)
+	if g := app.Query(func() interface{} { return testing.AllocsPerRun(10, paint) }).(float64); g != 0 {
+		t.Fatalf("got %v allocations per paint, expected 0", g)
+	}
+}
+
+func TestWindowsAt(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		r.SetTitle("r")
+		w1 := r.NewChild(Rectangle{Position{10, 5}, Size{30, 15}})
+		w1.SetTitle("w1")
+		w2 := w1.NewChild(Rectangle{Position{2, 2}, Size{10, 6}})
+		w2.SetTitle("w2")
+		w3 := r.NewChild(Rectangle{Position{35, 5}, Size{10, 5}})
+		w3.SetTitle("w3")
+		for _, v := range []Position{
+			{0, 0},
+			{10, 5},  // w1 border.
+			{13, 8},  // w2 border.
+			{14, 9},  // w2 client area.
+			{36, 6},  // w3 overlapping w1.
+			{80, 25}, // Outside.
+		} {
+			var b []string
+			for _, w := range d.WindowsAt(v) {
+				b = append(b, w.Title())
+			}
+			a = append(a, strings.Join(b, ","))
+		}
+		w3.SetVisible(false)
+		var b []string
+		for _, w := range d.WindowsAt(Position{36, 6}) {
+			b = append(b, w.Title())
+		}
+		a = append(a, strings.Join(b, ","))
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "r r,w1 r,w1,w2 r,w1,w2 r,w3  r,w1"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestClickBubbling(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		p := r.NewChild(Rectangle{Position{10, 5}, Size{30, 15}})
+		p.SetTitle("p")
+		c := p.NewChild(Rectangle{Position{2, 3}, Size{10, 6}})
+		c.SetTitle("c")
+		handled := false
+		for _, w := range []*Window{r, p, c} {
+			w.OnClick(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+				a = append(a, fmt.Sprintf("%s%v", w.Title(), winPos))
+				return w == c && handled
+			}, nil)
+		}
+		for _, f := range []func(){
+			func() {},
+			func() { c.SetClickBubbling(true) },
+			func() { p.SetClickBubbling(true) },
+			func() { handled = true },
+		} {
+			f()
+			r.click(tcell.Button1, Position{14, 10}, 0)
+			r.click(tcell.Button1, Position{13, 10}, 0) // Left border of c.
+			a = append(a, "|")
+		}
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "c{0 0} | c{0 0} p{3 4} p{2 4} | c{0 0} p{3 4} {14 10} p{2 4} {13 10} | c{0 0} p{2 4} {13 10} |"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestOnGlobalKey(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var a []string
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c := d.Root().NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		c.SetFocus(true)
+		c.OnKey(func(w *Window, prev OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+			a = append(a, fmt.Sprintf("window %c", r))
+			return true
+		}, nil)
+		app.OnGlobalKey(func(w *Window, prev OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+			a = append(a, fmt.Sprintf("global %c %v", r, w == nil))
+			return r == 'q'
+		}, nil)
+		return nil
+	})
+	s.InjectKey(tcell.KeyRune, 'a', 0)
+	s.InjectKey(tcell.KeyRune, 'q', 0)
+	app.Query(func() interface{} {
+		app.RemoveOnGlobalKey()
+		return nil
+	})
+	s.InjectKey(tcell.KeyRune, 'q', 0)
+	g := app.Query(func() interface{} { return strings.Join(a, "|") })
+	if e := "global a true|window a|global q true|window q"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestOnKeyNoFocus(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var a []string
+	var c *Window
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		c = d.Root().NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		c.OnKey(func(w *Window, prev OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+			a = append(a, fmt.Sprintf("window %c", r))
+			return true
+		}, nil)
+		app.OnKeyNoFocus(func(w *Window, prev OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+			a = append(a, fmt.Sprintf("no focus %c %v", r, w == nil))
+			return true
+		}, nil)
+		return nil
+	})
+	s.InjectKey(tcell.KeyRune, 'a', 0)
+	app.Query(func() interface{} {
+		c.SetFocus(true)
+		return nil
+	})
+	s.InjectKey(tcell.KeyRune, 'b', 0)
+	app.Query(func() interface{} {
+		c.SetFocus(false)
+		app.RemoveOnKeyNoFocus()
+		return nil
+	})
+	s.InjectKey(tcell.KeyRune, 'c', 0)
+	g := app.Query(func() interface{} { return strings.Join(a, "|") })
+	if e := "no focus a true|window b"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestTimer(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	done := make(chan struct{})
+	var after, stopped, ticks int
+	var tick *Timer
+	app.PostWait(func() {
+		app.AfterFunc(time.Millisecond, func() { after++ })
+		app.AfterFunc(time.Millisecond, func() { stopped++ }).Stop()
+		tick = app.Tick(time.Millisecond, func() {
+			if ticks++; ticks == 3 {
+				tick.Stop()
+				app.AfterFunc(10*time.Millisecond, func() { close(done) })
+			}
+		})
+	})
+	<-done
+	if g := app.Query(func() interface{} { return fmt.Sprint(after, stopped, ticks, tick.Stopped()) }).(string); g != "1 0 3 true" {
+		t.Fatalf("got %q", g)
+	}
+}
+
+func TestTickQueueFull(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var after, ticks int
+	var tick *Timer
+	app.PostWait(func() {
+		app.AfterFunc(time.Millisecond, func() { after++ })
+		tick = app.Tick(time.Millisecond, func() { ticks++ })
+		for i := 0; i < 20; i++ { // Fill the event queue.
+			app.Post(func() {})
+		}
+		time.Sleep(20 * time.Millisecond) // Let the ticks be dropped.
+	})
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if app.Query(func() interface{} { return after != 0 && ticks != 0 }).(bool) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("timers silent after the event queue was full")
+		}
+	}
+
+	if g := app.Query(func() interface{} { tick.Stop(); return tick.app == app }).(bool); !g {
+		t.Fatal("timer not owned by its application")
+	}
+}
+
+func TestPostCancelable(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var a []string
+	var cancel func()
+	app.Query(func() interface{} {
+		app.PostCancelable(func() { a = append(a, "1") })
+		cancel = app.PostCancelable(func() { a = append(a, "2") })
+		app.PostCancelable(func() { a = append(a, "3") })
+		cancel()
+		return nil
+	})
+	if g, e := app.Query(func() interface{} { return strings.Join(a, " ") }).(string), "1 3"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	cancel()
+}
+
+func TestOnSetClientSizeCancelable(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w := d.Root().NewChild(Rectangle{Position{1, 1}, Size{20, 10}})
+		h := func(s string) OnSetSizeHandler {
+			return func(w *Window, prev OnSetSizeHandler, dst *Size, src Size) {
+				a = append(a, s)
+				if prev != nil {
+					prev(w, nil, dst, src)
+				}
+			}
+		}
+		w.OnSetClientSize(h("1"), nil)
+		cancel := w.OnSetClientSizeCancelable(h("2"), func() { a = append(a, "f") })
+		w.OnSetClientSize(h("3"), nil)
+		cancel()
+		cancel()
+		w.SetClientSize(Size{10, 5})
+		a = append(a, fmt.Sprint(w.ClientSize()))
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "f 3 1 {10 5}"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestPostAfterExit(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ran int32
+	var d *Desktop
+	app.Query(func() interface{} {
+		d = app.NewDesktop()
+		app.SetDesktop(d)
+		return nil
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 100; i++ {
+			app.Post(func() {
+				d.Root().NewChild(Rectangle{Position{i, 1}, Size{10, 5}})
+				select {
+				case <-app.exited:
+					atomic.StoreInt32(&ran, 1)
+				default:
+				}
+			})
+		}
+	}()
+	app.PostWait(func() {
+		app.Post(func() { atomic.StoreInt32(&ran, 1) })
+		app.Exit(nil)
+	})
+	if err := app.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	<-done
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Fatal("function executed after Exit")
+	}
+}
+
+func TestBuffered(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	text := "hello"
+	var w, w2 *Window
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		w = r.NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		w.SetBuffered(true)
+		w.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			w.Print(0, 0, w.ClientAreaStyle(), text)
+		}, nil)
+		w2 = r.NewChild(Rectangle{Position{8, 4}, Size{10, 5}})
+		return nil
+	})
+	screen := func() string {
+		p := app.Query(func() interface{} { return w.ClientPosition().add(w.Position()) }).(Position)
+		var a []rune
+		for x := 0; x < len(text); x++ {
+			r, _, _, _ := s.GetContent(p.X+x, p.Y)
+			a = append(a, r)
+		}
+		return string(a)
+	}
+	cells := func(f func()) int {
+		app.Query(func() interface{} { f(); return nil })
+		return app.Query(func() interface{} { return app.metrics.Cells }).(int)
+	}
+
+	if g, e := screen(), "     "; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.PostWait(func() { w2.Close() })
+	if g, e := screen(), "hello"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	// The root window clears the invalidated area first, the buffered window
+	// then writes only the cells differing from the cleared background.
+	invalidate := func() { w.InvalidateClientArea(Rectangle{Size: w.ClientSize()}) }
+	n := app.Query(func() interface{} { sz := w.ClientSize(); return sz.Width * sz.Height }).(int)
+	if g, e := cells(invalidate), n+len(text); g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+
+	text = "hallo"
+	cells(invalidate)
+	if g, e := screen(), "hallo"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	n = app.Query(func() interface{} {
+		w.SetSize(Size{30, 12})
+		if g, e := len(w.buffer), w.ClientSize().Height; g != e {
+			t.Errorf("got %v, expected %v", g, e)
+		}
+		w.SetBuffered(false)
+		if w.Buffered() {
+			t.Error("buffered")
+		}
+		sz := w.ClientSize()
+		return sz.Width * sz.Height
+	}).(int)
+	if g, e := cells(invalidate), 2*n+len(text); g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+}
+
+func TestChildList(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	app.Query(func() interface{} {
+		d := app.NewDesktop()
+		r := d.Root()
+		if g := r.ChildList(); len(g) != 0 {
+			t.Errorf("got %v, expected empty list", g)
+		}
+
+		w1 := r.NewChild(Rectangle{Position{0, 0}, Size{10, 5}})
+		w2 := r.NewChild(Rectangle{Position{5, 5}, Size{10, 5}})
+		w3 := r.NewChild(Rectangle{Position{10, 5}, Size{10, 5}})
+		w1.BringToFront()
+		a := r.ChildList()
+		if g, e := fmt.Sprint(a), fmt.Sprint([]*Window{w2, w3, w1}); g != e {
+			t.Errorf("got %v, expected %v", g, e)
+		}
+
+		for _, c := range a {
+			c.Close()
+		}
+		if g, e := r.Children(), 0; g != e {
+			t.Errorf("got %v, expected %v", g, e)
+		}
+
+		if g, e := len(a), 3; g != e {
+			t.Errorf("got %v, expected %v", g, e)
+		}
+		return nil
+	})
+}
+
+func TestMouseEnterLeave(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		w1 := r.NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		w2 := w1.NewChild(Rectangle{Position{2, 2}, Size{5, 3}})
+		for i, w := range []*Window{r, w1, w2} {
+			i := i
+			w.OnMouseEnter(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+				a = append(a, fmt.Sprintf("enter %d %v", i, winPos))
+				return false
+			}, nil)
+			w.OnMouseLeave(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+				a = append(a, fmt.Sprintf("leave %d %v", i, winPos))
+				return false
+			}, nil)
+		}
+		for _, p := range []Position{{0, 0}, {1, 0}, {10, 5}, {14, 8}, {15, 8}, {40, 20}, {14, 8}} {
+			r.mouseMove(0, p, 0)
+		}
+		w1.Close()
+		r.mouseMove(0, Position{11, 6}, 0)
+		return strings.Join(a, "|")
+	}).(string)
+	if e := "enter 0 {0 0}|leave 0 {10 5}|enter 1 {0 0}|leave 1 {4 3}|enter 2 {1 0}|leave 2 {27 12}|enter 0 {40 20}|leave 0 {14 8}|enter 2 {1 0}|enter 0 {11 6}"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestRootBorders(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		r.SetBorderTop(1)
+		r.SetBorderLeft(2)
+		r.SetBorderRight(3)
+		r.SetBorderBottom(4)
+		r.SetCloseButton(true)
+		return fmt.Sprint(r.Position(), r.Size(), r.ClientArea(), r.CloseButton())
+	}).(string)
+	if e := "{0 0} {80 25} {{2 1} {75 20}} false"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+	g = app.Query(func() interface{} {
+		r := app.Desktop().Root()
+		r.SetClientSize(Size{10, 10})
+		a := []string{fmt.Sprint(r.Size(), r.ClientArea())}
+		app.setSize(Size{60, 20})
+		a = append(a, fmt.Sprint(r.Size(), r.ClientArea()))
+		w := r.NewChild(Rectangle{Position{0, 0}, Size{10, 5}})
+		w.Maximize()
+		return strings.Join(append(a, fmt.Sprint(w.Position(), w.Size())), " ")
+	}).(string)
+	if e := "{80 25} {{2 1} {75 20}} {60 20} {{2 1} {55 15}} {0 0} {55 15}"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.Query(func() interface{} { return nil })
+	for _, v := range []struct {
+		x, y int
+		e    rune
+	}{
+		{0, 0, '┌'},
+		{59, 0, '┐'},
+		{0, 19, '└'},
+		{59, 19, '┘'},
+		{2, 1, '┌'},
+		{56, 15, '┘'},
+	} {
+		if g, _, _, _ := s.GetContent(v.x, v.y); g != v.e {
+			t.Errorf("%v, %v: got %q, expected %q", v.x, v.y, g, v.e)
+		}
+	}
+}
+
+func TestIsAncestorOf(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	app.Query(func() interface{} {
+		r := app.NewDesktop().Root()
+		w1 := r.NewChild(Rectangle{Position{0, 0}, Size{20, 10}})
+		w2 := w1.NewChild(Rectangle{Position{0, 0}, Size{10, 5}})
+		w3 := w2.NewChild(Rectangle{Position{0, 0}, Size{5, 3}})
+		x := r.NewChild(Rectangle{Position{0, 0}, Size{20, 10}})
+		for i, v := range []struct {
+			a, b *Window
+			e    bool
+		}{
+			{r, w1, true},
+			{r, w3, true},
+			{w1, w3, true},
+			{w2, w3, true},
+			{w3, w3, false},
+			{w3, w1, false},
+			{w1, r, false},
+			{x, w3, false},
+			{w1, x, false},
+			{w1, nil, false},
+		} {
+			if g, e := v.a.IsAncestorOf(v.b), v.e; g != e {
+				t.Errorf("#%d: got %v, expected %v", i, g, e)
+			}
+		}
+		for i, v := range []*Window{r, w1, w2, w3, x} {
+			if g, e := v.Root(), r; g != e {
+				t.Errorf("#%d: got %p, expected %p", i, g, e)
+			}
+		}
+		return nil
+	})
+}
+
+func TestSetHandlerLists(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w := d.Root().NewChild(Rectangle{Size: Size{10, 5}})
+
+		var lr *OnSetRectangleHandlerList
+		AddOnSetRectangleHandler(&lr, func(w *Window, prev OnSetRectangleHandler, dst *Rectangle, src Rectangle) {
+			a = append(a, "r0")
+			*dst = src
+		}, func() { a = append(a, "f0") })
+		AddOnSetRectangleHandler(&lr, func(w *Window, prev OnSetRectangleHandler, dst *Rectangle, src Rectangle) {
+			a = append(a, "r1")
+			prev(w, nil, dst, src)
+		}, func() { a = append(a, "f1") })
+		var r Rectangle
+		lr.Handle(w, &r, Rectangle{Size: Size{1, 1}})
+		lr.Handle(w, &r, Rectangle{Size: Size{1, 1}})
+		RemoveOnSetRectangleHandler(&lr)
+		lr.Handle(w, &r, Rectangle{})
+		lr.Clear()
+
+		var lw *OnSetWindowHandlerList
+		AddOnSetWindowHandler(&lw, func(w *Window, prev OnSetWindowHandler, dst **Window, src *Window) {
+			a = append(a, "w0")
+			*dst = src
+		}, nil)
+		var u *Window
+		lw.Handle(w, &u, w)
+
+		var ls *OnSetWindowStyleHandlerList
+		ls.Handle(w, &w.style, WindowStyle{Title: Style{Background: tcell.ColorRed}})
+		w.OnSetStyle(func(w *Window, prev OnSetWindowStyleHandler, dst *WindowStyle, src WindowStyle) {
+			a = append(a, "s0")
+			prev(w, nil, dst, src)
+		}, nil)
+		w.SetStyle(WindowStyle{})
+		return fmt.Sprint(a, r, u == w, w.Style() == WindowStyle{})
+	}).(string)
+	if e := "[r1 r0 f1 r0 f0 w0 s0] {{0 0} {0 0}} true true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestThemeValidate(t *testing.T) {
+	if err := DefaultTheme().Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range []struct {
+		json string
+		ok   bool
+	}{
+		{`{}`, true},
+		{`{"ChildWindow":{"Title":{"Foreground":3,"Background":3}}}`, false},
+		{`{"ChildWindow":{"BorderInactive":{"Foreground":5,"Background":5}}}`, false},
+		{`{"ChildWindow":{"BorderInactive":{"Foreground":0,"Background":0}}}`, true},
+		{`{"ChildWindow":{"ClientArea":{"Foreground":-1,"Background":-1}}}`, true},
+		{`{"ChildWindow":{"BorderLine":42}}`, false},
+		{`{"Desktop":{"Border":{"Foreground":0,"Background":0}}}`, true},
+		{`{"Desktop":{"ClientArea":{"Foreground":0,"Background":0}}}`, false},
+	} {
+		th := DefaultTheme()
+		if _, err := th.ReadFrom(strings.NewReader(v.json)); err != nil {
+			t.Fatal(i, err)
+		}
+
+		if g, e := th.Validate() == nil, v.ok; g != e {
+			t.Errorf("%v: %v %v %v", i, g, e, th.Validate())
+		}
+	}
+}
+
+func TestStyleJSON(t *testing.T) {
+	s := NewStyleRGB(1, 2, 3, 255, 128, 0)
+	s.Attr = tcell.AttrBold
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := string(b), `{"Foreground":"#010203","Background":"#ff8000","Attr":33554432}`; g != e {
+		t.Fatalf("got %s, expected %s", g, e)
+	}
+
+	var s2 Style
+	if err := json.Unmarshal(b, &s2); err != nil {
+		t.Fatal(err)
+	}
+
+	if s2 != s {
+		t.Fatalf("got %+v, expected %+v", s2, s)
+	}
+
+	if b, err = json.Marshal(Style{Foreground: tcell.ColorNavy, Background: tcell.ColorDefault}); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := string(b), `{"Foreground":"navy","Background":"default","Attr":0}`; g != e {
+		t.Fatalf("got %s, expected %s", g, e)
+	}
+
+	if b, err = json.Marshal(Style{Foreground: tcell.ColorDarkGray, Background: tcell.Color(200)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := string(b), `{"Foreground":"darkgray","Background":200,"Attr":0}`; g != e {
+		t.Fatalf("got %s, expected %s", g, e)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Foreground":"Silver","Background":"grey"}`), &s2); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := s2, (Style{Foreground: tcell.ColorSilver, Background: tcell.ColorGray, Attr: s.Attr}); g != e {
+		t.Fatalf("got %+v, expected %+v", g, e)
+	}
+
+	s2 = Style{Foreground: tcell.ColorRed, Background: tcell.ColorBlue, Attr: tcell.AttrReverse}
+	if err := json.Unmarshal([]byte(`{"Background":"#00ff00"}`), &s2); err != nil {
+		t.Fatal(err)
+	}
+
+	if g, e := s2, (Style{tcell.ColorRed, tcell.NewRGBColor(0, 255, 0), tcell.AttrReverse}); g != e {
+		t.Fatalf("got %+v, expected %+v", g, e)
+	}
+
+	for _, v := range []string{`{"Foreground":"#0000"}`, `{"Foreground":"#00000g"}`, `{"Foreground":true}`, `{"Foreground":"nosuchcolor"}`} {
+		if err := json.Unmarshal([]byte(v), &s2); err == nil {
+			t.Fatalf("%s: unexpected success", v)
+		}
+	}
+
+	th := DefaultTheme()
+	th.ChildWindow.Title = NewStyleRGB(10, 20, 30, 40, 50, 60)
+	var buf bytes.Buffer
+	if _, err := th.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var th2 Theme
+	if _, err := th2.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if th2 != *th {
+		t.Fatalf("got %+v, expected %+v", th2, *th)
+	}
+}
+
+func TestSetBorders(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		w := d.Root().NewChild(Rectangle{Position{1, 1}, Size{20, 10}})
+		n, m := 0, 0
+		w.OnSetClientSize(func(w *Window, prev OnSetSizeHandler, dst *Size, src Size) {
+			n++
+			prev(w, nil, dst, src)
+		}, nil)
+		w.OnSetBorderTop(func(w *Window, prev OnSetIntHandler, dst *int, src int) {
+			m++
+			prev(w, nil, dst, src)
+		}, nil)
+		for _, v := range [][4]int{{2, 1, 0, 3}, {2, 1, 0, 3}, {1, 2, 1, 2}, {0, 0, 0, 0}} {
+			n, m = 0, 0
+			w.SetBorders(v[0], v[1], v[2], v[3])
+			t, r, b, l := w.Borders()
+			a = append(a, fmt.Sprint(t, r, b, l, w.ClientArea(), n, m))
+		}
+		return strings.Join(a, "|")
+	}).(string)
+	if e := "2 1 0 3 {{3 2} {16 8}} 1 1|2 1 0 3 {{3 2} {16 8}} 0 0|1 2 1 2 {{2 1} {16 8}} 0 1|0 0 0 0 {{0 0} {20 10}} 1 1"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestSetRectangle(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		w := r.NewChild(Rectangle{Position{10, 5}, Size{6, 4}})
+		r.BeginUpdate()
+		d.invalidated = nil
+		w.SetRectangle(Rectangle{Position{12, 6}, Size{8, 5}})
+		a = append(a, fmt.Sprint(d.invalidated, w.Position(), w.Size(), w.ClientSize()))
+		d.invalidated = nil
+		w.SetRectangle(Rectangle{Position{12, 6}, Size{8, 5}})
+		a = append(a, fmt.Sprint(d.invalidated))
+		rsz := r.Size()
+		r.SetRectangle(Rectangle{Position{1, 1}, Size{3, 3}})
+		a = append(a, fmt.Sprint(d.invalidated, r.Position(), r.Size() == rsz))
+		r.EndUpdate()
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "[{{10 5} {10 6}}] {12 6} {8 5} {6 3} [] [] {0 0} true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestWideRuneClip(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var c, c2 *Window
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		r.BeginUpdate()
+		c = r.NewChild(Rectangle{Position{0, 0}, Size{6, 4}})
+		c.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			w.Print(0, 0, w.ClientAreaStyle(), "a中文")
+		}, nil)
+		c2 = c.NewChild(Rectangle{Position{1, 1}, Size{5, 1}})
+		c2.SetBorders(0, 0, 0, 0)
+		c2.OnPaintClientArea(func(w *Window, prev OnPaintHandler, ctx PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+			w.Print(0, 0, w.ClientAreaStyle(), "xy世界")
+		}, nil)
+		r.EndUpdate()
+	})
+	check := func(e string) {
+		t.Helper()
+		if g := app.Query(func() interface{} { return screenText(s, Rectangle{Position{0, 1}, Size{6, 2}}) }); g != e {
+			t.Fatalf("\n%s\n%s", g, e)
+		}
+
+		if g := app.Query(func() interface{} {
+			r, _, _, _ := s.GetContent(5, 1)
+			r2, _, _, _ := s.GetContent(5, 2)
+			return string([]rune{r, r2})
+		}); g != "││" {
+			t.Fatalf("%q", g)
+		}
+	}
+	check("│a中 │\n│ xy │")
+
+	// A partial repaint splitting a glyph keeps it.
+	app.PostWait(func() { c.Invalidate(Rectangle{Position{2, 1}, Size{1, 1}}) })
+	check("│a中 │\n│ xy │")
+
+	app.PostWait(func() { c.SetOrigin(Position{1, 0}) })
+	check("│中文│\n│xy世│")
+}
+
+func TestFocusOnClose(t *testing.T) {
+	app, err := newApplication(tcell.NewSimulationScreen(""), &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		w1 := r.NewChild(Rectangle{Position{0, 0}, Size{20, 10}})
+		w1.SetTitle("w1")
+		w2 := r.NewChild(Rectangle{Position{30, 0}, Size{20, 10}})
+		w2.SetTitle("w2")
+		w3 := r.NewChild(Rectangle{Position{50, 0}, Size{20, 10}})
+		w3.SetTitle("w3")
+		c := w2.NewChild(Rectangle{Position{1, 1}, Size{5, 4}})
+		c.SetTitle("c")
+		cc := c.NewChild(Rectangle{Position{0, 0}, Size{3, 3}})
+		cc.SetTitle("cc")
+		title := func() string {
+			if f := d.FocusedWindow(); f != nil {
+				return f.Title()
+			}
+
+			return "-"
+		}
+
+		w3.BringToFront()
+		c.SetFocus(true)
+		c.Close()
+		a = append(a, title())
+
+		// Closing a window having a focused descendant.
+		c = w2.NewChild(Rectangle{Position{1, 1}, Size{5, 4}})
+		cc = c.NewChild(Rectangle{Position{0, 0}, Size{3, 3}})
+		cc.SetTitle("cc")
+		cc.SetFocus(true)
+		w2.Close()
+		a = append(a, title())
+
+		// Closing an unfocused window keeps the focus.
+		w1.Close()
+		a = append(a, title())
+
+		w1 = r.NewChild(Rectangle{Position{0, 0}, Size{20, 10}})
+		w1.SetTitle("w1")
+		w3.SetFocusOnClose(false)
+		a = append(a, fmt.Sprint(w3.FocusOnClose(), w1.FocusOnClose()))
+		w3.Close()
+		a = append(a, title())
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "w2 w3 w3 false true -"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestResizeInvalidate(t *testing.T) {
+	app, err := newApplication(tcell.NewSimulationScreen(""), &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		w := r.NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		w.SetRepaintOnResize(false)
+		resize := func(sz Size) {
+			d.invalidated = nil
+			w.SetSize(sz)
+			var b []byte
+			for _, x := range []int{15, 28, 29, 30} {
+				c := byte('.')
+				for _, v := range d.invalidated {
+					if (Position{x, 8}).In(v) {
+						c = '*'
+						break
+					}
+				}
+				b = append(b, c)
+			}
+			a = append(a, string(b))
+		}
+		r.BeginUpdate()
+		resize(Size{21, 10})
+		resize(Size{19, 10})
+		resize(Size{19, 10})
+		w.SetRepaintOnResize(true)
+		resize(Size{20, 10})
+		r.EndUpdate()
+		return strings.Join(a, " ")
+	}).(string)
+	if e := "..** .*** .... ***."; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestMouseState(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	state := func() string {
+		return app.Query(func() interface{} { return fmt.Sprint(app.MousePosition(), app.MouseButtons()) }).(string)
+	}
+	app.PostWait(func() { app.SetDesktop(app.NewDesktop()) })
+	if g, e := state(), "{0 0} 0"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	s.InjectMouse(12, 7, tcell.Button1, 0)
+	if g, e := state(), "{12 7} 1"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	s.InjectMouse(13, 8, tcell.ButtonNone, 0)
+	if g, e := state(), "{13 8} 0"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestDragStartEnd(t *testing.T) {
+	app, err := newApplication(tcell.NewSimulationScreen(""), &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		w := r.NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		w2 := r.NewChild(Rectangle{Position{40, 5}, Size{20, 10}})
+		h := func(s string, v bool) OnDragHandler {
+			return func(w *Window, prev OnDragHandler, button tcell.ButtonMask, screenPos0, screenPos, winPos Position, mods tcell.ModMask) bool {
+				a = append(a, fmt.Sprint(s, screenPos0, screenPos, winPos))
+				return v && winPos.X < 5
+			}
+		}
+		w.OnDragStart(h("start", true), nil)
+		w.OnDragMove(h("move", true), nil)
+		w.OnDragEnd(h("end", false), nil)
+		w2.OnDrop(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+			a = append(a, fmt.Sprint("drop", screenPos))
+			return true
+		}, nil)
+
+		r.drag(tcell.Button1, Position{12, 7}, 0)
+		r.mouseMove(tcell.Button1, Position{45, 8}, 0)
+		r.drop(tcell.Button1, Position{45, 8}, 0)
+
+		// Not accepted.
+		r.drag(tcell.Button1, Position{20, 7}, 0)
+		r.mouseMove(tcell.Button1, Position{21, 8}, 0)
+		r.drop(tcell.Button1, Position{21, 8}, 0)
+		a = append(a, fmt.Sprint(w.Position()))
+		return strings.Join(a, "\n")
+	}).(string)
+	if e := strings.Join([]string{
+		"start{12 7} {12 7} {2 2}",
+		"move{12 7} {45 8} {35 3}",
+		"end{12 7} {45 8} {35 3}",
+		"drop{45 8}",
+		"start{20 7} {20 7} {10 2}",
+		"{10 5}",
+	}, "\n"); g != e {
+		t.Fatalf("got\n%s\nexpected\n%s", g, e)
+	}
+}
+
+func TestInputEnabled(t *testing.T) {
+	app, err := newApplication(tcell.NewSimulationScreen(""), &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	g := app.Query(func() interface{} {
+		var a []string
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		r := d.Root()
+		p := r.NewChild(Rectangle{Position{10, 5}, Size{20, 10}})
+		p.SetTitle("p")
+		c := p.NewChild(Rectangle{Position{2, 2}, Size{8, 5}})
+		c.SetTitle("c")
+		cc := c.NewChild(Rectangle{Position{1, 1}, Size{4, 4}})
+		cc.SetTitle("cc")
+		click := func(w *Window) {
+			w.OnClick(func(w *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+				a = append(a, fmt.Sprint(w.Title(), winPos))
+				return true
+			}, nil)
+		}
+		click(p)
+		click(c)
+		click(cc)
+		focused := func() {
+			s := "-"
+			if f := d.FocusedWindow(); f != nil {
+				s = f.Title()
+			}
+			a = append(a, s)
+		}
+
+		r.click(tcell.Button1, Position{17, 11}, 0)
+		focused()
+		c.SetInputEnabled(false)
+		a = append(a, fmt.Sprint(c.InputEnabled(), cc.InputEnabled(), p.InputEnabled()))
+		focused()
+		r.click(tcell.Button1, Position{17, 11}, 0)
+		focused()
+		cc.SetFocus(true)
+		focused()
+		c.SetInputEnabled(true)
+		r.click(tcell.Button1, Position{17, 11}, 0)
+		focused()
+		return strings.Join(a, "\n")
+	}).(string)
+	if e := strings.Join([]string{
+		"cc{1 0}",
+		"cc",
+		"false false true",
+		"-",
+		"p{6 5}",
+		"p",
+		"p",
+		"cc{1 0}",
+		"cc",
+	}, "\n"); g != e {
+		t.Fatalf("got\n%s\nexpected\n%s", g, e)
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	area := Rectangle{Position{1, 1}, Size{4, 3}}
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		d.Root().NewChild(area)
+	})
+	e := "┌──┐\n│  │\n└──┘"
+	if g := app.Query(func() interface{} { return screenText(s, area) }); g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	errSuspend := fmt.Errorf("suspend")
+	g := app.Query(func() interface{} {
+		var sz Size
+		err := app.Suspend(func() error {
+			sz = newSize(s.Size())
+			return errSuspend
+		})
+		return fmt.Sprint(sz, err == errSuspend, app.Size())
+	})
+	if e := "{0 0} true {80 25}"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	if g := app.Query(func() interface{} { return screenText(s, area) }); g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	// Events queued before Suspend are kept, other goroutines posting while
+	// the terminal is released wait until it's taken back.
+	var a []string
+	done := make(chan struct{})
+	app.PostWait(func() {
+		app.Post(func() { a = append(a, "queued") })
+		app.Suspend(func() error {
+			go func() {
+				app.Post(func() { a = append(a, "post") })
+				app.PostWait(func() { a = append(a, "wait") })
+				close(done)
+			}()
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		})
+		a = append(a, "resumed")
+	})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("PostWait blocked after Suspend")
+	}
+	if g, e := app.Query(func() interface{} { return strings.Join(a, " ") }), "resumed queued post wait"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestRedraw(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	app, err := newApplication(s, &Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		app.PostWait(func() { app.Exit(nil) })
+		if err := app.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	area := Rectangle{Position{1, 1}, Size{4, 3}}
+	app.PostWait(func() {
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+		d.Root().NewChild(area)
+	})
+	e := "┌──┐\n│  │\n└──┘"
+	if g := app.Query(func() interface{} { return screenText(s, area) }); g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	app.PostWait(func() {
+		for y := area.Y; y < area.Y+area.Height; y++ {
+			for x := area.X; x < area.X+area.Width; x++ {
+				s.SetContent(x, y, 'x', nil, tcell.StyleDefault)
+			}
+		}
+		app.Sync()
+	})
+	if g, e := app.Query(func() interface{} { return screenText(s, area) }), "xxxx\nxxxx\nxxxx"; g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+
+	app.PostWait(app.Redraw)
+	if g := app.Query(func() interface{} { return screenText(s, area) }); g != e {
+		t.Fatalf("\n%s\n%s", g, e)
+	}
+}
+
+func TestWindowTitleSequence(t *testing.T) {
+	for i, v := range []struct {
+		term, s, e string
+	}{
+		{"", "foo", ""},
+		{"linux", "foo", ""},
+		{"xtermfoo", "foo", ""},
+		{"xterm", "foo", "\x1b]2;foo\a"},
+		{"xterm-256color", "foo", "\x1b]2;foo\a"},
+		{"tmux-256color", "a\x1b]2;b\ac\u009cd", "\x1b]2;a]2;bcd\a"},
+		{"screen", "日本", "\x1b]2;日本\a"},
+	} {
+		if g, e := windowTitleSequence(v.term, v.s), v.e; g != e {
+			t.Errorf("%v: %q %q: got %q, expected %q", i, v.term, v.s, g, e)
+		}
+	}
+}
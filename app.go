@@ -57,10 +57,14 @@ package wm
 
 import (
 	"fmt"
+	"os"
 	rdebug "runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cznic/mathutil"
 	"github.com/gdamore/tcell"
 	"github.com/gdamore/tcell/encoding"
 )
@@ -74,8 +78,37 @@ var (
 	// App is the instance of Application created by NewApplication.
 	App                *Application
 	onceNewApplication sync.Once
+
+	// Prefixes of the TERM values of terminals known to support setting
+	// the window title using OSC 2.
+	titleTerms = []string{
+		"alacritty",
+		"foot",
+		"gnome",
+		"iterm",
+		"kitty",
+		"konsole",
+		"putty",
+		"rxvt",
+		"screen",
+		"st",
+		"tmux",
+		"vte",
+		"wezterm",
+		"xterm",
+	}
 )
 
+// FrameMetrics describes the painting performance of an application. A frame
+// is a single update of the screen painting all areas invalidated since the
+// previous one.
+type FrameMetrics struct {
+	Cells     int           // Number of cells written by the last frame.
+	Frames    int           // Number of frames painted so far.
+	LastFrame time.Duration // Duration of the last frame.
+	PeakFrame time.Duration // Duration of the longest frame.
+}
+
 // Application represents an interactive terminal application.
 //
 // Application methods must be called only directly from an event handler
@@ -83,15 +116,33 @@ var (
 // Application.PostWait.  The only exception is Application.Wait, it can be
 // called from any goroutine.
 type Application struct {
+	animated          map[*Window]struct{}      // Windows invalidated on every refresh.
+	backlog           []tcell.Event             // Events queued before Suspend, handled first.
+	batchPaint        bool                      //
+	blitting          bool                      // Skip writing cells the screen already shows.
+	capture           *Window                   // Window being rendered by RenderToCells, if any.
+	cells             [][]Cell                  // RenderToCells buffer.
 	click             time.Duration             //
+	cursor            Position                  // Screen position or cursorWindow client area position.
+	cursorShown       bool                      //
+	cursorWindow      *Window                   // Window.SetCursor target, if any.
 	desktop           *Desktop                  //
+	desktops          []*Desktop                // Registered desktops, see AddDesktop.
 	doubleClick       time.Duration             //
 	exitError         error                     //
+	exited            chan struct{}             // Closed by Exit.
+	exiting           int32                     // Atomic. Set by Exit.
+	flushing          bool                      // Desktop.flush in progress, cells are counted.
+	frozen            int                       // FreezePaint nesting level.
+	metrics           FrameMetrics              //
 	mouseButtonFSMs   [8]*mouseButtonFSM        //
 	mouseButtonsState tcell.ButtonMask          //
 	mouseX            int                       //
 	mouseY            int                       //
+	onGlobalKey       *onKeyHandlerList         // Invoked before the focused window key handlers.
 	onKey             *onKeyHandlerList         //
+	onKeyNoFocus      *onKeyHandlerList         // Invoked when no window is focused.
+	onMouse           *OnMouseHandlerList       //
 	onSetClick        *onSetDurationHandlerList //
 	onSetDesktop      *onSetDesktopHandlerList  //
 	onSetDoubleClick  *onSetDurationHandlerList //
@@ -99,8 +150,14 @@ type Application struct {
 	onceExit          sync.Once                 //
 	onceFinalize      sync.Once                 //
 	onceWait          sync.Once                 //
+	refreshPending    int32                     // Atomic. A refresh is posted but not yet executed.
+	refreshRate       int                       // Frames per second.
+	refreshStop       chan struct{}             // Stops the refresh goroutine.
 	screen            tcell.Screen              //
 	size              Size                      //
+	snapDistance      int                       //
+	stopped           chan struct{}             // Closed when handleEvents returns.
+	suspendMu         sync.RWMutex              // Write locked by Suspend while the screen is released.
 	theme             *Theme                    //
 	updateLevel       int32                     //
 	wait              chan error                //
@@ -138,6 +195,18 @@ func NewApplication(theme *Theme) (*Application, error) {
 	return app, err
 }
 
+// NewApplicationScreen is like NewApplication but the application uses
+// screen, for example a tcell.SimulationScreen when testing. Unlike
+// NewApplication, it can be called more than once, provided Wait of the
+// previously created application has returned.
+func NewApplicationScreen(screen tcell.Screen, theme *Theme) (*Application, error) {
+	if screen == nil {
+		panic("cannot use nil screen")
+	}
+
+	return newApplication(screen, theme)
+}
+
 func newApplication(screen tcell.Screen, t *Theme) (*Application, error) {
 	encoding.Register()
 	var err error
@@ -156,16 +225,19 @@ func newApplication(screen tcell.Screen, t *Theme) (*Application, error) {
 	theme := *t
 	App = &Application{
 		click:       150 * time.Millisecond,
+		animated:    map[*Window]struct{}{},
 		doubleClick: 120 * time.Millisecond,
+		exited:      make(chan struct{}),
 		screen:      screen,
 		size:        size,
+		stopped:     make(chan struct{}),
 		theme:       &theme,
 		wait:        make(chan error, 1),
 	}
 
 	mask := tcell.Button1
 	for i := range App.mouseButtonFSMs {
-		App.mouseButtonFSMs[i] = newMouseButtonFSM(mask)
+		App.mouseButtonFSMs[i] = newMouseButtonFSM(App, mask)
 		mask <<= 1
 	}
 	App.screen.EnableMouse()
@@ -177,6 +249,13 @@ func newApplication(screen tcell.Screen, t *Theme) (*Application, error) {
 }
 
 func (a *Application) handleEvents() {
+	defer func() {
+		for _, v := range a.mouseButtonFSMs {
+			v.close()
+		}
+		close(a.stopped)
+	}()
+
 	defer func() {
 		if err := recover(); err != nil {
 			a.finalize()
@@ -185,9 +264,15 @@ func (a *Application) handleEvents() {
 	}()
 
 	for {
-		ev := a.screen.PollEvent()
-		if ev == nil {
-			return
+		var ev tcell.Event
+		switch {
+		case len(a.backlog) != 0:
+			ev = a.backlog[0]
+			a.backlog = a.backlog[1:]
+		default:
+			if ev = a.screen.PollEvent(); ev == nil {
+				return
+			}
 		}
 
 		d := a.desktop
@@ -210,7 +295,7 @@ func (a *Application) handleEvents() {
 			if x != a.mouseX || y != a.mouseY || btn&anyWheel != 0 {
 				a.mouseX = x
 				a.mouseY = y
-				a.screen.PostEvent(newEventMouse(mouseMove, btn, e.Modifiers(), Position{x, y}))
+				a.postEvent(newEventMouse(mouseMove, btn, e.Modifiers(), Position{x, y}))
 			}
 			if b := btn & anyButton; b != a.mouseButtonsState {
 				diff := b ^ a.mouseButtonsState
@@ -228,13 +313,19 @@ func (a *Application) handleEvents() {
 			w := a.Desktop().Root()
 			switch e.kind {
 			case mouseDrag:
-				w.drag(e.button, e.Position, e.mods)
+				if !a.onMouse.Handle(w, e.button, e.Position, e.Position, e.mods) {
+					w.drag(e.button, e.Position, e.mods)
+				}
 			case mouseDrop:
 				w.drop(e.button, e.Position, e.mods)
 			case mouseClick:
-				w.click(e.button, e.Position, e.mods)
+				if !a.onMouse.Handle(w, e.button, e.Position, e.Position, e.mods) {
+					w.click(e.button, e.Position, e.mods)
+				}
 			case mouseDoubleClick:
-				w.doubleClick(e.button, e.Position, e.mods)
+				if !a.onMouse.Handle(w, e.button, e.Position, e.Position, e.mods) {
+					w.doubleClick(e.button, e.Position, e.mods)
+				}
 			case mouseMove:
 				w.mouseMove(e.button, e.Position, e.mods)
 			default:
@@ -242,7 +333,9 @@ func (a *Application) handleEvents() {
 			}
 			e.dispose()
 		case *eventFunc:
-			e.f()
+			if atomic.LoadInt32(&a.exiting) == 0 {
+				e.f()
+			}
 			e.dispose()
 		default:
 			panic(fmt.Errorf("%T", e))
@@ -266,11 +359,26 @@ func (a *Application) onSetDesktopHandler(_ *Window, prev OnSetDesktopHandler, d
 	w.Invalidate(w.Area())
 }
 
+// desktopIndex returns the index of d in the registered desktops or -1 if d
+// is not registered.
+func (a *Application) desktopIndex(d *Desktop) int {
+	for i, v := range a.desktops {
+		if v == d {
+			return i
+		}
+	}
+	return -1
+}
+
 func (a *Application) onKeyHandler(w *Window, prev OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
 	if prev != nil {
 		panic("internal error")
 	}
 
+	if a.onGlobalKey.handle(nil, key, mod, r) {
+		return true
+	}
+
 	d := a.Desktop()
 	if d == nil {
 		return true
@@ -278,7 +386,7 @@ func (a *Application) onKeyHandler(w *Window, prev OnKeyHandler, key tcell.Key,
 
 	fw := d.FocusedWindow()
 	if fw == nil {
-		return false
+		return a.onKeyNoFocus.handle(nil, key, mod, r)
 	}
 
 	return fw.onKey.handle(fw, key, mod, r)
@@ -312,26 +420,20 @@ func (a *Application) paintSelection() {
 		return
 	}
 
-	o := area.Position
-	for y := 0; y < area.Height; y++ {
-		sy := o.Y + y
-		fx := true
-		for x := 0; x < area.Width; x++ {
-			sx := o.X + x
-			if fx {
-				_, _, _, width := a.screen.GetContent(sx-1, sy)
-				if width == 2 {
-					sx--
-					x--
-				}
-			}
-			fx = false
+	// Glyph boundaries are found by scanning each row from its start, the
+	// width reported for a cell following a double width glyph is not
+	// reliable.
+	for sy := area.Y; sy < area.Y+area.Height; sy++ {
+		for sx := 0; sx < area.X+area.Width; {
 			mainc, combc, style, width := a.screen.GetContent(sx, sy)
-			style ^= tcell.Style(tcell.AttrReverse)
-			a.screen.SetContent(sx, sy, mainc, combc, style)
-			if width == 2 {
-				x++
+			if width < 1 {
+				width = 1
 			}
+			if sx+width > area.X {
+				style ^= tcell.Style(tcell.AttrReverse)
+				a.screen.SetContent(sx, sy, mainc, combc, style)
+			}
+			sx += width
 		}
 	}
 }
@@ -339,6 +441,16 @@ func (a *Application) paintSelection() {
 var marker = Style{Background: tcell.ColorRed, Foreground: tcell.ColorBlack}
 
 func (a *Application) setCell(p Position, mainc rune, combc []rune, style tcell.Style) {
+	if a.blitting {
+		m, c, s, _ := a.screen.GetContent(p.X, p.Y)
+		if cell := (Cell{m, c, s}); cell.equal(mainc, combc, style) {
+			return
+		}
+	}
+
+	if a.flushing {
+		a.metrics.Cells++
+	}
 	switch {
 	case debug:
 		// Make screen updates slow enough for human observation.
@@ -355,10 +467,173 @@ func (a *Application) setCell(p Position, mainc rune, combc []rune, style tcell.
 	}
 }
 
+// postEvent puts ev in the event queue, if the queue is not full. While
+// Suspend releases the screen, postEvent blocks until the screen is taken
+// back.
+func (a *Application) postEvent(ev tcell.Event) error {
+	a.suspendMu.RLock()
+	defer a.suspendMu.RUnlock()
+	return a.screen.PostEvent(ev)
+}
+
+// postEventWait is like postEvent but it waits while the queue is full, unless
+// the event handler goroutine has returned.
+func (a *Application) postEventWait(ev tcell.Event) {
+	for a.postEvent(ev) != nil {
+		select {
+		case <-a.stopped:
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// drainEvents removes the events from the event queue and returns them. Other
+// goroutines must not post events meanwhile, see suspendMu.
+func (a *Application) drainEvents() (r []tcell.Event) {
+	mark := newEventFunc(nil)
+	for a.screen.PostEvent(mark) != nil { // The queue is full.
+		if ev := a.screen.PollEvent(); ev != nil {
+			r = append(r, ev)
+		}
+	}
+	for {
+		switch ev := a.screen.PollEvent(); ev {
+		case mark:
+			mark.dispose()
+			return r
+		case nil: // The screen was finalized.
+			return r
+		default:
+			r = append(r, ev)
+		}
+	}
+}
+
+// postOnce enqueues f unless pending is set, ie. unless f posted by a previous
+// postOnce call using the same pending flag was not yet executed. The flag is
+// cleared before f executes or when the event queue is full, in which case
+// postOnce returns false.
+func (a *Application) postOnce(pending *int32, f func()) bool {
+	if !atomic.CompareAndSwapInt32(pending, 0, 1) {
+		return true
+	}
+
+	e := newEventFunc(func() {
+		atomic.StoreInt32(pending, 0)
+		f()
+	})
+	if err := a.postEvent(e); err != nil {
+		e.dispose()
+		atomic.StoreInt32(pending, 0)
+		return false
+	}
+
+	return true
+}
+
+// refresh invalidates the animated windows of the active desktop.
+func (a *Application) refresh() {
+	if a.refreshRate == 0 { // Stopped after this refresh was posted.
+		return
+	}
+
+	for w := range a.animated {
+		if w.Desktop() == a.desktop {
+			w.Invalidate(Rectangle{Size: w.Size()})
+		}
+	}
+}
+
+// placeCursor shows the cursor at its position or hides it when it is not
+// set or when its position is not visible.
+func (a *Application) placeCursor() {
+	if !a.cursorShown {
+		return
+	}
+
+	p := a.cursor
+	if w := a.cursorWindow; w != nil {
+		var ok bool
+		if p, ok = w.clientToScreen(p); !ok || w.Desktop() != a.desktop {
+			a.screen.HideCursor()
+			return
+		}
+	}
+
+	a.screen.ShowCursor(p.X, p.Y)
+}
+
 func (a *Application) finalize() { a.onceFinalize.Do(func() { a.screen.Fini() }) }
 
+// windowTitleSequence returns the escape sequence setting the window title of
+// a terminal of type term to s or an empty string if the terminal is not
+// known to support it. Control characters of s are removed.
+func windowTitleSequence(term, s string) string {
+	for _, v := range titleTerms {
+		if term == v || strings.HasPrefix(term, v+"-") {
+			return "\x1b]2;" + strings.Map(func(r rune) rune {
+				if r < ' ' || r >= 0x7f && r < 0xa0 {
+					return -1
+				}
+
+				return r
+			}, s) + "\a"
+		}
+	}
+	return ""
+}
+
 // ----------------------------------------------------------------------------
 
+// AddDesktop registers d for switching using NextDesktop and PrevDesktop.
+// Registering a desktop again has no effect. Desktops created by NewDesktop or
+// made active by SetDesktop are registered automatically, see RemoveDesktop.
+// Passing nil d will panic.
+func (a *Application) AddDesktop(d *Desktop) {
+	if d == nil {
+		panic("cannot add nil desktop")
+	}
+
+	if a.desktopIndex(d) < 0 {
+		a.desktops = append(a.desktops, d)
+	}
+}
+
+// AfterFunc executes f on the event handler goroutine after at least
+// duration d elapses, unless the returned timer is stopped before. If the
+// event queue is full, enqueuing f is retried until it succeeds. The timer
+// stops when the application exits.
+func (a *Application) AfterFunc(d time.Duration, f func()) *Timer {
+	t := newTimer(a)
+	go func() {
+		tm := time.NewTimer(d)
+		defer tm.Stop()
+		for {
+			select {
+			case <-tm.C:
+				if t.post(func() {
+					t.Stop()
+					f()
+				}) {
+					return
+				}
+
+				tm.Reset(time.Millisecond)
+			case <-t.stop:
+				return
+			case <-a.exited:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// BatchPaint reports whether painting of invalidated areas is batched. See
+// SetBatchPaint.
+func (a *Application) BatchPaint() bool { return a.batchPaint }
+
 // BeginUpdate marks the start of one or more updates to the application
 // screen.
 //
@@ -389,6 +664,10 @@ func (a *Application) Desktop() (d *Desktop) { return a.desktop }
 // DesktopStyle returns the style assigned to new desktops.
 func (a *Application) DesktopStyle() WindowStyle { return a.theme.Desktop }
 
+// Desktops returns the registered desktops in the order of their
+// registration.
+func (a *Application) Desktops() []*Desktop { return append([]*Desktop(nil), a.desktops...) }
+
 // DoubleClickDuration returns the maximum duration of a double click. Mouse
 // click not followed by another one within the DoubleClickDuration is a single
 // click.
@@ -402,19 +681,87 @@ func (a *Application) EndUpdate() {
 	a.updateLevel--
 	if a.updateLevel == 0 {
 		a.paintSelection() // Show selection.
-		a.screen.Show()
+		a.placeCursor()
+		if a.frozen == 0 {
+			a.screen.Show()
+		}
 	}
 }
 
 // Exit terminates the interactive terminal application and returns err from
-// Wait(). Only the first call of this method is considered.
+// Wait(). Only the first call of this method is considered. Functions enqueued
+// by Post or PostWait and not yet executed are discarded.
 func (a *Application) Exit(err error) {
+	atomic.StoreInt32(&a.exiting, 1)
 	a.finalize()
-	a.onceExit.Do(func() { a.wait <- err })
+	a.onceExit.Do(func() {
+		close(a.exited)
+		a.wait <- err
+	})
+}
+
+// FreezePaint suspends painting of the application screen. Invalidated areas
+// of the active desktop are accumulated and painted at once by the matching
+// ThawPaint call. FreezePaint calls nest, painting is resumed only after the
+// outermost ThawPaint call.
+//
+// Unlike BeginUpdate/EndUpdate, the frozen state spans any number of events,
+// including functions enqueued by Application.Post.
+//
+// Failing to properly pair FreezePaint with a corresponding ThawPaint will
+// cause the application screen to not be updated anymore.
+//
+// FreezePaint must be called only directly from an event handler goroutine or
+// from a function that was enqueued using Application.Post or
+// Application.PostWait.
+func (a *Application) FreezePaint() { a.frozen++ }
+
+// HideCursor hides the cursor.
+func (a *Application) HideCursor() {
+	a.cursorShown = false
+	a.cursorWindow = nil
+	a.screen.HideCursor()
+}
+
+// Metrics returns the painting performance metrics of the application.
+func (a *Application) Metrics() FrameMetrics { return a.metrics }
+
+// MouseButtons returns the mouse buttons pressed as of the last mouse event
+// processed by the event loop.
+func (a *Application) MouseButtons() tcell.ButtonMask { return a.mouseButtonsState }
+
+// MousePosition returns the screen position of the mouse as of the last mouse
+// event processed by the event loop.
+func (a *Application) MousePosition() Position { return Position{a.mouseX, a.mouseY} }
+
+// NewDesktop returns a newly created desktop. The desktop is registered, see
+// AddDesktop.
+func (a *Application) NewDesktop() *Desktop {
+	d := newDesktop()
+	a.AddDesktop(d)
+	return d
+}
+
+// NextDesktop makes the registered desktop following the active one active.
+// The first registered desktop follows the last one. The method has no effect
+// if there are no registered desktops.
+func (a *Application) NextDesktop() {
+	n := len(a.desktops)
+	if n == 0 {
+		return
+	}
+
+	a.SetDesktop(a.desktops[(a.desktopIndex(a.desktop)+1)%n])
 }
 
-// NewDesktop returns a newly created desktop.
-func (a *Application) NewDesktop() *Desktop { return newDesktop() }
+// OnGlobalKey sets a key event handler invoked before the key handlers of the
+// focused window. If the handler returns true, the focused window does not
+// see the key event. Global key handlers are suitable for application wide
+// hot keys. The window passed to the handler is nil. When the event handler is
+// removed, finalize is called, if not nil.
+func (a *Application) OnGlobalKey(h OnKeyHandler, finalize func()) {
+	addOnKeyHandler(&a.onGlobalKey, h, finalize)
+}
 
 // OnKey sets a key event handler. When the event handler is removed, finalize
 // is called, if not nil.
@@ -422,6 +769,42 @@ func (a *Application) OnKey(h OnKeyHandler, finalize func()) {
 	addOnKeyHandler(&a.onKey, h, finalize)
 }
 
+// OnKeyCancelable is like OnKey but it returns a function which removes the
+// handler, even if other handlers were set after it. Calling the returned
+// function more than once has no effect.
+func (a *Application) OnKeyCancelable(h OnKeyHandler, finalize func()) (cancel func()) {
+	addOnKeyHandler(&a.onKey, h, finalize)
+	node := a.onKey
+	return func() { removeOnKeyHandlerNode(&a.onKey, node) }
+}
+
+// OnKeyNoFocus sets a key event handler invoked when the active desktop has
+// no focused window, for example after the user clicked the desktop
+// background. The window passed to the handler is nil. When the event handler
+// is removed, finalize is called, if not nil.
+func (a *Application) OnKeyNoFocus(h OnKeyHandler, finalize func()) {
+	addOnKeyHandler(&a.onKeyNoFocus, h, finalize)
+}
+
+// OnMouse sets a handler invoked before a mouse click, double click or drag
+// event is dispatched to the window under the mouse. The handler receives the
+// root window of the current desktop and the screen position of the event in
+// both screenPos and winPos. Returning true consumes the event. Mouse move and
+// drop events are not filtered. When the event handler is removed, finalize is
+// called, if not nil.
+func (a *Application) OnMouse(h OnMouseHandler, finalize func()) {
+	AddOnMouseHandler(&a.onMouse, h, finalize)
+}
+
+// OnMouseCancelable is like OnMouse but it returns a function which removes
+// the handler, even if other handlers were set after it. Calling the returned
+// function more than once has no effect.
+func (a *Application) OnMouseCancelable(h OnMouseHandler, finalize func()) (cancel func()) {
+	AddOnMouseHandler(&a.onMouse, h, finalize)
+	node := a.onMouse
+	return func() { removeOnMouseHandlerNode(&a.onMouse, node) }
+}
+
 // OnSetClickDuration sets a handler invoked on SetClickDuration. When the
 // event handler is removed, finalize is called, if not nil.
 func (a *Application) OnSetClickDuration(h OnSetDurationHandler, finalize func()) {
@@ -447,16 +830,109 @@ func (a *Application) OnSetSize(h OnSetSizeHandler, finalize func()) {
 }
 
 // Post puts f in the event queue, if the queue is not full, and executes it on
-// dequeuing the event.
-func (a *Application) Post(f func()) { a.screen.PostEvent(newEventFunc(f)) }
+// dequeuing the event, unless Exit was called before. While Suspend runs, Post
+// blocks until the terminal is taken back.
+func (a *Application) Post(f func()) { a.postEvent(newEventFunc(f)) }
+
+// PostCancelable is like Post but it returns a function which, when called
+// before f is dequeued, prevents f from executing. The returned function can
+// be called from any goroutine, calling it after f was dequeued has no
+// effect.
+func (a *Application) PostCancelable(f func()) (cancel func()) {
+	var canceled int32
+	a.Post(func() {
+		if atomic.LoadInt32(&canceled) == 0 {
+			f()
+		}
+	})
+	return func() { atomic.StoreInt32(&canceled, 1) }
+}
+
+// PostWait puts f in the event queue and executes it on dequeuing the event,
+// unless Exit was called before. While Suspend runs, PostWait blocks until the
+// terminal is taken back.
+func (a *Application) PostWait(f func()) { a.postEventWait(newEventFunc(f)) }
+
+// PrevDesktop makes the registered desktop preceding the active one active.
+// The last registered desktop precedes the first one. The method has no effect
+// if there are no registered desktops.
+func (a *Application) PrevDesktop() {
+	n := len(a.desktops)
+	if n == 0 {
+		return
+	}
+
+	i := a.desktopIndex(a.desktop)
+	if i < 0 {
+		i = 0
+	}
+	a.SetDesktop(a.desktops[(i+n-1)%n])
+}
+
+// Query puts f in the event queue, waits for it to be executed and returns
+// its result. Query provides a safe way to read the state of the application,
+// its desktops and windows from any goroutine.
+//
+// If the application exits before f is executed, f is discarded and Query
+// returns nil.
+//
+// Calling Query from the event handler goroutine, ie. from an event handler
+// or from a function enqueued by Post or PostWait, will deadlock.
+func (a *Application) Query(f func() interface{}) interface{} {
+	ch := make(chan interface{}, 1)
+	a.PostWait(func() { ch <- f() })
+	select {
+	case r := <-ch:
+		return r
+	case <-a.stopped:
+		// f, if executed at all, has completed.
+		select {
+		case r := <-ch:
+			return r
+		default:
+			return nil
+		}
+	}
+}
 
-// PostWait puts f in the event queue and executes it on dequeuing the event.
-func (a *Application) PostWait(f func()) { a.screen.PostEventWait(newEventFunc(f)) }
+// Redraw repaints the active desktop from its windows and updates every
+// character cell of the application screen. Unlike Sync, which only makes the
+// terminal match what was last painted, Redraw also repaints every window, so
+// it recovers from screen content that got out of date or was overwritten by
+// other output written to the terminal.
+func (a *Application) Redraw() {
+	a.screen.Sync()
+	if d := a.Desktop(); d != nil {
+		r := d.Root()
+		r.Invalidate(r.Area())
+	}
+}
+
+// RemoveDesktop unregisters d, undoing AddDesktop, so that NextDesktop and
+// PrevDesktop no longer switch to it. Removing a desktop that is not
+// registered has no effect. The active desktop stays active when removed.
+func (a *Application) RemoveDesktop(d *Desktop) {
+	if i := a.desktopIndex(d); i >= 0 {
+		a.desktops = append(a.desktops[:i], a.desktops[i+1:]...)
+	}
+}
+
+// RemoveOnGlobalKey undoes the most recent OnGlobalKey call. The function
+// will panic if there is no handler set.
+func (a *Application) RemoveOnGlobalKey() { removeOnKeyHandler(&a.onGlobalKey) }
 
 // RemoveOnKey undoes the most recent OnKey call. The function will panic if
 // there is no handler set.
 func (a *Application) RemoveOnKey() { removeOnKeyHandler(&a.onKey) }
 
+// RemoveOnKeyNoFocus undoes the most recent OnKeyNoFocus call. The function
+// will panic if there is no handler set.
+func (a *Application) RemoveOnKeyNoFocus() { removeOnKeyHandler(&a.onKeyNoFocus) }
+
+// RemoveOnMouse undoes the most recent OnMouse call. The function will panic
+// if there is no handler set.
+func (a *Application) RemoveOnMouse() { RemoveOnMouseHandler(&a.onMouse) }
+
 // RemoveOnSetClickDuration undoes the most recent OnSetClickDuration call. The
 // function will panic if there is no handler set.
 func (a *Application) RemoveOnSetClickDuration() { removeOnSetDurationHandler(&a.onSetClick) }
@@ -485,6 +961,57 @@ func (a *Application) Run(setup func()) error {
 	return a.Wait()
 }
 
+// RefreshRate returns the number of animation frames per second. Zero means
+// animation is disabled.
+func (a *Application) RefreshRate() int { return a.refreshRate }
+
+// SetRefreshRate sets the number of animation frames per second. On every
+// frame the windows marked by Window.SetAnimated, if they belong to the active
+// desktop, are invalidated. A frame is skipped while the previous one was not
+// yet processed. Setting fps to zero stops the animation. Animation stops
+// when the application exits.
+func (a *Application) SetRefreshRate(fps int) {
+	if fps < 0 {
+		fps = 0
+	}
+	if a.refreshRate == fps {
+		return
+	}
+
+	if a.refreshStop != nil {
+		close(a.refreshStop)
+		a.refreshStop = nil
+	}
+	a.refreshRate = fps
+	if fps == 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	a.refreshStop = stop
+	go func() {
+		t := time.NewTicker(time.Second / time.Duration(fps))
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				a.postOnce(&a.refreshPending, a.refresh)
+			case <-stop:
+				return
+			case <-a.exited:
+				return
+			}
+		}
+	}()
+}
+
+// SetBatchPaint sets whether painting of invalidated areas is batched. When
+// batching, areas invalidated by any number of event handlers are painted at
+// once by a function enqueued using Post after the first of them, instead of
+// being painted at the end of every event handler. Batching reduces redundant
+// repaints under high-frequency updates at the cost of a small latency.
+func (a *Application) SetBatchPaint(v bool) { a.batchPaint = v }
+
 // SetClickDuration sets the maximum duration of a single click. Holding a
 // mouse button for any longer duration generates a drag event instead.
 func (a *Application) SetClickDuration(d time.Duration) { a.onSetClick.handle(nil, &a.click, d) }
@@ -495,6 +1022,7 @@ func (a *Application) SetDesktop(d *Desktop) {
 		panic("cannot set nil desktop")
 	}
 
+	a.AddDesktop(d)
 	a.onSetDesktop.handle(nil, &a.desktop, d)
 }
 
@@ -504,22 +1032,163 @@ func (a *Application) SetDesktop(d *Desktop) {
 //
 // Note: Setting DoubleClickDuration to zero disables double click support.
 func (a *Application) SetDoubleClickDuration(d time.Duration) {
-	a.onSetClick.handle(nil, &a.doubleClick, d)
+	a.onSetDoubleClick.handle(nil, &a.doubleClick, d)
 }
 
 func (a *Application) setSize(s Size) { a.onSetSize.Handle(nil, &a.size, s) }
 
+// SetSnapDistance sets the distance, in cells, within which the edges of a
+// window dragged by the mouse snap to the edges of its parent's client area
+// and to the edges of its siblings. Zero, the default, disables snapping.
+func (a *Application) SetSnapDistance(n int) { a.snapDistance = mathutil.Max(0, n) }
+
+// SetWindowTitle sets the title of the terminal window showing the
+// application to s. Not to be confused with Window.SetTitle, which sets the
+// title of a window of the application.
+//
+// The title is set by writing the OSC 2 escape sequence, ESC ] 2 ; s BEL, to
+// /dev/tty as tcell provides no way to set it. Control characters of s are
+// removed. The method has no effect if the terminal, as reported by the TERM
+// environment variable, is not known to support the sequence, if the
+// application screen is a tcell.SimulationScreen or if /dev/tty cannot be
+// opened.
+func (a *Application) SetWindowTitle(s string) {
+	if _, ok := a.screen.(tcell.SimulationScreen); ok {
+		return
+	}
+
+	seq := windowTitleSequence(os.Getenv("TERM"), s)
+	if seq == "" {
+		return
+	}
+
+	f, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+
+	f.WriteString(seq)
+	f.Close()
+}
+
+// ShowCursor shows the cursor at screen position x, y. The cursor position is
+// restored after every screen update.
+func (a *Application) ShowCursor(x, y int) {
+	a.cursor = Position{x, y}
+	a.cursorShown = true
+	a.cursorWindow = nil
+	a.placeCursor()
+}
+
 // Size returns the size of the terminal the application runs in.
 func (a *Application) Size() (s Size) { return a.size }
 
-// Sync updates every character cell of the application screen.
+// SnapDistance returns the distance within which dragged windows snap to
+// nearby edges.
+func (a *Application) SnapDistance() int { return a.snapDistance }
+
+// Suspend releases the terminal, calls f and then takes the terminal back,
+// repainting the active desktop. It's intended for running external programs
+// using the terminal, like an editor or a shell. No events are processed while
+// f runs. Events queued before Suspend was called are kept and processed
+// after it returns. Post and PostWait called by other goroutines while f runs
+// block until the terminal is taken back, f itself must not call them as that
+// would deadlock. Suspend returns the error of f, if any, or an error of
+// resuming the terminal, in which case the application exits with that error.
+//
+// Suspend must be called only directly from an event handler goroutine or
+// from a function that was enqueued using Application.Post or
+// Application.PostWait.
+func (a *Application) Suspend(f func() error) error {
+	// Init replaces the event queue of the screen, keep the queued events
+	// and make the other goroutines wait instead of posting to the old queue.
+	a.suspendMu.Lock()
+	a.backlog = append(a.backlog, a.drainEvents()...)
+	a.screen.Fini()
+	err := f()
+	e := a.screen.Init()
+	a.suspendMu.Unlock()
+	if e != nil {
+		a.onceFinalize.Do(func() {})
+		a.Exit(e)
+		return e
+	}
+
+	a.screen.EnableMouse()
+	a.setSize(newSize(a.screen.Size()))
+	a.Redraw()
+	return err
+}
+
+// Sync updates every character cell of the application screen with the
+// content last painted by the windows. The windows are not repainted, see
+// Redraw.
 func (a *Application) Sync() { a.screen.Sync() }
 
-// Wait blocks until the interactive terminal application terminates.
+// ThawPaint undoes the most recent FreezePaint call. The outermost call paints
+// the areas invalidated while frozen and updates the application screen. The
+// function will panic if the application is not frozen.
+//
+// ThawPaint must be called only directly from an event handler goroutine or
+// from a function that was enqueued using Application.Post or
+// Application.PostWait.
+func (a *Application) ThawPaint() {
+	if a.frozen == 0 {
+		panic("ThawPaint without FreezePaint")
+	}
+
+	a.frozen--
+	if a.frozen != 0 {
+		return
+	}
+
+	a.BeginUpdate()
+	if d := a.Desktop(); d != nil {
+		r := d.Root()
+		r.BeginUpdate()
+		r.EndUpdate()
+	}
+	a.EndUpdate()
+}
+
+// Tick executes f on the event handler goroutine repeatedly, every duration
+// d, until the returned timer is stopped. A tick is skipped while f from the
+// previous tick was not yet executed. Like with Post, f is not executed if
+// the event queue is full. The timer stops when the application exits.
+// Passing d <= 0 will panic.
+func (a *Application) Tick(d time.Duration, f func()) *Timer {
+	if d <= 0 {
+		panic("non-positive tick duration")
+	}
+
+	t := newTimer(a)
+	go func() {
+		tk := time.NewTicker(d)
+		defer tk.Stop()
+		for {
+			select {
+			case <-tk.C:
+				t.post(f)
+			case <-t.stop:
+				return
+			case <-a.exited:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// Wait blocks until the interactive terminal application terminates and its
+// event handler goroutine returns. Calling Wait from the event handler
+// goroutine will deadlock.
 //
 // Calling this method more than once will panic.
 func (a *Application) Wait() error {
 	err := a.exitError
-	a.onceWait.Do(func() { err = <-a.wait })
+	a.onceWait.Do(func() {
+		err = <-a.wait
+		<-a.stopped
+	})
 	return err
 }
@@ -154,12 +154,18 @@ func setup(d *wm.Desktop) {
 	app.SetDoubleClickDuration(0)
 	r := d.Root()
 	var renderedIn time.Duration
+	var sampled time.Time
 	r.OnPaintClientArea(
 		func(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
 			if prev != nil {
 				prev(w, nil, ctx)
 			}
 
+			if time.Since(sampled) >= time.Second {
+				renderedIn = r.Rendered()
+				sampled = time.Now()
+			}
+
 			mousePosStr := ""
 			if w == mouseMoveWindow {
 				mousePosStr = fmt.Sprintf("Mouse: %+v", mousePos)
@@ -234,19 +240,8 @@ Rendered in %s.
 		},
 		nil,
 	)
-	fps := 25
-	i := 0
-	go func() {
-		for range time.NewTicker(time.Second / time.Duration(fps)).C {
-			app.Post(func() {
-				i = (i + 1) % fps
-				r.Invalidate(r.Area())
-				if i == 0 {
-					renderedIn = r.Rendered()
-				}
-			})
-		}
-	}()
+	r.SetAnimated(true)
+	app.SetRefreshRate(25)
 	d.Show()
 }
 
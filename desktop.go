@@ -4,6 +4,51 @@
 
 package wm
 
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxRegion = 8 // Maximum number of rectangles in a region.
+
+// region is a set of rectangles, possibly overlapping.
+type region []Rectangle
+
+// add adds r to g. Rectangles already in g, which are contained in r, are
+// removed. If g would grow beyond maxRegion rectangles, it is replaced by
+// their bounding rectangle.
+func (g *region) add(r Rectangle) {
+	if r.IsZero() {
+		return
+	}
+
+	for _, v := range *g {
+		u := v
+		if u.join(r); u == v { // v contains r.
+			return
+		}
+	}
+
+	s := (*g)[:0]
+	for _, v := range *g {
+		u := r
+		if u.join(v); u != r { // r does not contain v.
+			s = append(s, v)
+		}
+	}
+	s = append(s, r)
+	if len(s) > maxRegion {
+		var b Rectangle
+		for _, v := range s {
+			b.join(v)
+		}
+		s = append(s[:0], b)
+	}
+	*g = s
+}
+
 // Desktop represents a virtual screen. An application has one or more
 // independent desktops, of which only one is visible at any given moment.
 //
@@ -13,13 +58,18 @@ package wm
 // or from a function that was enqueued using Application.Post or
 // Application.PostWait.
 type Desktop struct {
-	invalidated Rectangle //
-	root        *Window   // Never changes.
-	updateLevel int       //
+	flushDue     bool      // The posted flush is executing.
+	flushPending int32     // Atomic. A flush is posted but not yet executed.
+	geometry     uint64    // Incremented on every window geometry change.
+	invalidated  region    //
+	root         *Window   // Never changes.
+	tabFocus     *Window   // Window focused by the last FocusNext/FocusPrev.
+	tabOrder     []*Window // Keyboard traversal snapshot, see traversal.
+	updateLevel  int       //
 }
 
 func newDesktop() *Desktop {
-	d := &Desktop{}
+	d := &Desktop{geometry: 1}
 	w := newWindow(d, nil, App.DesktopStyle())
 	d.root = w
 	w.setSize(App.Size())
@@ -28,6 +78,92 @@ func newDesktop() *Desktop {
 	return d
 }
 
+// flush paints the invalidated areas.
+func (d *Desktop) flush() {
+	d.flushDue = false
+	invalidated := d.invalidated
+	d.invalidated = nil
+	App.BeginUpdate()
+	r := d.Root()
+	m := &App.metrics
+	m.Cells = 0
+	t := time.Now()
+	App.flushing = true
+	for _, v := range invalidated {
+		r.paint(v)
+	}
+	App.flushing = false
+	r.rendered = time.Since(t)
+	m.Frames++
+	m.LastFrame = r.rendered
+	if m.LastFrame > m.PeakFrame {
+		m.PeakFrame = m.LastFrame
+	}
+	App.EndUpdate()
+}
+
+// postFlush enqueues painting of the invalidated areas, unless it is already
+// enqueued. Areas invalidated until the enqueued function executes are
+// painted at once. If the event queue is full, the areas are painted
+// immediately.
+func (d *Desktop) postFlush() {
+	posted := App.postOnce(&d.flushPending, func() {
+		if len(d.invalidated) == 0 {
+			return
+		}
+
+		d.flushDue = true
+		if d.updateLevel == 0 {
+			d.Root().BeginUpdate()
+			d.Root().EndUpdate()
+		}
+	})
+	if !posted {
+		d.flush()
+	}
+}
+
+// traversal returns the visible, input enabled child windows of the root
+// window having a non negative tab index, sorted by the tab index. Windows
+// having equal tab indices are ordered by z-order. As traversal brings windows
+// to front, the order is snapshotted and reused while the focus stays where
+// the last traversal put it and the set of the windows does not change. The
+// index of the child window containing the focused window is returned as well,
+// or -1 if there's no such window.
+func (d *Desktop) traversal() (r []*Window, focused int) {
+	root := d.Root()
+	if root == nil {
+		return nil, -1
+	}
+
+	f := d.FocusedWindow()
+	for f != nil && f.parent != root {
+		f = f.parent
+	}
+	m := map[*Window]bool{}
+	for _, v := range root.ChildList() {
+		if v.Visible() && !v.noInput && v.tabIndex >= 0 {
+			r = append(r, v)
+			m[v] = true
+		}
+	}
+	reuse := f != nil && f == d.tabFocus && len(r) == len(d.tabOrder)
+	for _, v := range d.tabOrder {
+		reuse = reuse && m[v]
+	}
+	if reuse {
+		r = append(r[:0], d.tabOrder...)
+	}
+	sort.SliceStable(r, func(i, j int) bool { return r[i].tabIndex < r[j].tabIndex })
+	d.tabOrder = r
+	for i, v := range r {
+		if v == f {
+			return r, i
+		}
+	}
+	return r, -1
+}
+
 // ----------------------------------------------------------------------------
 
 // FocusedWindow returns the window with focus, if any.
@@ -40,6 +176,69 @@ func (d *Desktop) FocusedWindow() *Window {
 	return r.focusedWindow
 }
 
+// FocusNext moves the focus to the next visible, input enabled child window of
+// the root window in tab order, see Window.SetTabIndex. The window is brought
+// to front and focused. When no child window of the root window has focus, the
+// first one in tab order is focused. The method has no effect if the root
+// window has no such children with a non negative tab index.
+func (d *Desktop) FocusNext() {
+	a, i := d.traversal()
+	if len(a) == 0 {
+		return
+	}
+
+	c := a[(i+1)%len(a)]
+	c.BringToFront()
+	c.SetFocus(true)
+	d.tabFocus = c
+}
+
+// FocusPrev undoes the effect of FocusNext. The currently focused child window
+// of the root window is sent to back and the previous visible child window in
+// tab order is brought to front and focused. When no child window of the root
+// window has focus, the last one in tab order is focused. The method has no
+// effect if the root window has no visible children with a non negative tab
+// index.
+func (d *Desktop) FocusPrev() {
+	a, i := d.traversal()
+	if len(a) == 0 {
+		return
+	}
+
+	j := len(a) - 1
+	if i >= 0 {
+		a[i].SendToBack()
+		j = (i + len(a) - 1) % len(a)
+	}
+	c := a[j]
+	c.BringToFront()
+	c.SetFocus(true)
+	d.tabFocus = c
+}
+
+// OnFocusChanged sets a function invoked after the focused window of d
+// changed. It's passed the previously and the newly focused window, either of
+// them can be nil. Any intermediate focus changes made while the focus moves
+// are not reported.
+//
+// The function is installed as an OnSetFocusedWindow handler, use
+// RemoveOnSetFocusedWindow to remove it. When it's removed, finalize is
+// called, if not nil.
+func (d *Desktop) OnFocusChanged(f func(old, new *Window), finalize func()) {
+	depth := 0
+	d.OnSetFocusedWindow(func(w *Window, prev OnSetWindowHandler, dst **Window, src *Window) {
+		old := *dst
+		depth++
+		if prev != nil {
+			prev(w, nil, dst, src)
+		}
+		depth--
+		if depth == 0 && *dst != old {
+			f(old, *dst)
+		}
+	}, finalize)
+}
+
 // OnSetFocusedWindow sets a handler invoked on SetFocusedWindow. When the
 // event handler is removed, finalize is called, if not nil.
 func (d *Desktop) OnSetFocusedWindow(h OnSetWindowHandler, finalize func()) {
@@ -48,7 +247,7 @@ func (d *Desktop) OnSetFocusedWindow(h OnSetWindowHandler, finalize func()) {
 		return
 	}
 
-	addOnSetWindowHandler(&r.onSetFocusedWindow, h, finalize)
+	AddOnSetWindowHandler(&r.onSetFocusedWindow, h, finalize)
 }
 
 // OnSetSelection sets a handler invoked on SetSelection. When the event
@@ -59,7 +258,7 @@ func (d *Desktop) OnSetSelection(h OnSetRectangleHandler, finalize func()) {
 		return
 	}
 
-	addOnSetRectangleHandler(&r.onSetSelection, h, finalize)
+	AddOnSetRectangleHandler(&r.onSetSelection, h, finalize)
 }
 
 // RemoveOnSetFocusedWindow undoes the most recent OnSetFocusedWindow call. The
@@ -70,7 +269,7 @@ func (d *Desktop) RemoveOnSetFocusedWindow() {
 		return
 	}
 
-	removeOnSetWindowHandler(&r.onSetFocusedWindow)
+	RemoveOnSetWindowHandler(&r.onSetFocusedWindow)
 }
 
 // RemoveOnSetSelection undoes the most recent OnSetSelection call. The
@@ -81,12 +280,18 @@ func (d *Desktop) RemoveOnSetSelection() {
 		return
 	}
 
-	removeOnSetRectangleHandler(&r.onSetSelection)
+	RemoveOnSetRectangleHandler(&r.onSetSelection)
 }
 
 // Root returns the root window of d.
 func (d *Desktop) Root() *Window { return d.root }
 
+// Screenshot writes the content of d to w using format. The desktop does not
+// need to be shown.
+func (d *Desktop) Screenshot(w io.Writer, format Format) error {
+	return writeScreenshot(w, d.Root().RenderToCells(), format)
+}
+
 // Selection returns the area of the desktop shown in reverse.
 func (d *Desktop) Selection() Rectangle {
 	r := d.Root()
@@ -97,6 +302,50 @@ func (d *Desktop) Selection() Rectangle {
 	return r.selection
 }
 
+// SelectionText returns the text of the desktop area under the selection.
+// Rows are joined using '\n' and their trailing spaces are removed. Double
+// width glyphs partially covered by the selection are included. The result
+// is empty if there is no selection.
+func (d *Desktop) SelectionText() string {
+	area := d.Selection()
+	if area.IsZero() {
+		return ""
+	}
+
+	var cells [][]Cell
+	if d != App.Desktop() {
+		cells = d.Root().RenderToCells()
+	}
+	var a []string
+	for y := area.Y; y < area.Y+area.Height; y++ {
+		var row []Cell
+		switch {
+		case cells != nil:
+			if y >= len(cells) {
+				break
+			}
+
+			row = cells[y]
+		default:
+			row = make([]Cell, area.X+area.Width)
+			for x := range row {
+				mainc, combc, style, _ := App.screen.GetContent(x, y)
+				row[x] = Cell{mainc, combc, style}
+			}
+		}
+		var b []string
+		for x := 0; x < len(row) && x < area.X+area.Width; {
+			s, w := cellText(row[x])
+			if x+w > area.X {
+				b = append(b, s)
+			}
+			x += w
+		}
+		a = append(a, strings.TrimRight(strings.Join(b, ""), " "))
+	}
+	return strings.Join(a, "\n")
+}
+
 // SetFocusedWindow sets the focused window.
 func (d *Desktop) SetFocusedWindow(w *Window) {
 	r := d.root
@@ -114,8 +363,23 @@ func (d *Desktop) SetSelection(area Rectangle) {
 		return
 	}
 
-	r.onSetSelection.handle(r, &r.selection, area)
+	r.onSetSelection.Handle(r, &r.selection, area)
 }
 
 // Show sets d as the application active desktop.
 func (d *Desktop) Show() { App.SetDesktop(d) }
+
+// WindowsAt returns the visible, input enabled windows at screenPos, starting
+// with the root window of d and ending with the topmost window at screenPos,
+// which is the window receiving mouse events at that position. Every window in
+// the result, except the first one, is a child window of its predecessor. The
+// result is nil if screenPos is outside of the root window.
+func (d *Desktop) WindowsAt(screenPos Position) (r []*Window) {
+	root := d.Root()
+	if root == nil || !screenPos.In(root.Area()) {
+		return nil
+	}
+
+	root.hitTest(screenPos, func(w *Window) { r = append(r, w) })
+	return r
+}
@@ -7,15 +7,52 @@ package wm
 import (
 	"github.com/cznic/interval"
 	"github.com/cznic/mathutil"
+	"github.com/gdamore/tcell"
 )
 
+// Cell represents the content of a single character cell.
+type Cell struct {
+	Mainc rune        // Primary rune.
+	Combc []rune      // Combining runes, if any.
+	Style tcell.Style //
+}
+
+// newCells returns a buffer of zero cells indexed as [y][x].
+func newCells(sz Size) [][]Cell {
+	r := make([][]Cell, sz.Height)
+	for i := range r {
+		r[i] = make([]Cell, sz.Width)
+	}
+	return r
+}
+
+// equal reports whether c has the given content.
+func (c *Cell) equal(mainc rune, combc []rune, style tcell.Style) bool {
+	if c.Mainc != mainc || c.Style != style || len(c.Combc) != len(combc) {
+		return false
+	}
+
+	for i, v := range combc {
+		if c.Combc[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
 // Position represents 2D coordinates.
 type Position struct {
 	X, Y int
 }
 
-func (p Position) add(q Position) Position { return Position{p.X + q.X, p.Y + q.Y} }
-func (p Position) sub(q Position) Position { return Position{p.X - q.X, p.Y - q.Y} }
+func (p Position) add(q Position) Position { return p.Add(q) }
+func (p Position) sub(q Position) Position { return p.Sub(q) }
+
+// Add returns p translated by q.
+func (p Position) Add(q Position) Position { return Position{p.X + q.X, p.Y + q.Y} }
+
+// Sub returns p translated by -q.
+func (p Position) Sub(q Position) Position { return Position{p.X - q.X, p.Y - q.Y} }
 
 // In returns whether p is inside r.
 func (p Position) In(r Rectangle) bool { return r.Has(p) }
@@ -37,6 +74,12 @@ func NewRectangle(x1, y1, x2, y2 int) Rectangle {
 	return Rectangle{Position{x1, y1}, Size{x2 - x1 + 1, y2 - y1 + 1}}
 }
 
+// Center returns the position of the center of r. For even dimensions the
+// center is rounded towards the bottom right corner.
+func (r Rectangle) Center() Position {
+	return Position{r.X + r.Width/2, r.Y + r.Height/2}
+}
+
 // Clip sets r to the intersection of r and s and returns a boolean value indicating
 // whether the result is of non zero size.
 func (r *Rectangle) Clip(s Rectangle) bool {
@@ -65,6 +108,44 @@ func (r *Rectangle) Clip(s Rectangle) bool {
 	return true
 }
 
+// Contains returns whether s is completely inside r. A zero sized s is
+// contained in r if its position is.
+func (r Rectangle) Contains(s Rectangle) bool {
+	if s.IsZero() {
+		return r.Has(s.Position)
+	}
+
+	return s.X >= r.X && s.X+s.Width <= r.X+r.Width &&
+		s.Y >= r.Y && s.Y+s.Height <= r.Y+r.Height
+}
+
+// Inset returns r shrunk by dx on its left and right sides and by dy on its
+// top and bottom sides. Negative values of dx or dy grow the rectangle. The
+// size of the result is never negative.
+func (r Rectangle) Inset(dx, dy int) Rectangle {
+	return Rectangle{
+		Position{r.X + dx, r.Y + dy},
+		Size{mathutil.Max(0, r.Width-2*dx), mathutil.Max(0, r.Height-2*dy)},
+	}
+}
+
+// Intersection returns the intersection of r and s and a boolean value
+// indicating whether the result is of non zero size.
+func (r Rectangle) Intersection(s Rectangle) (Rectangle, bool) {
+	if r.IsZero() || s.IsZero() || !r.Clip(s) {
+		return Rectangle{}, false
+	}
+
+	return r, true
+}
+
+// Union returns the smallest rectangle containing both r and s. Zero sized
+// rectangles are ignored.
+func (r Rectangle) Union(s Rectangle) Rectangle {
+	r.join(s)
+	return r
+}
+
 func (r *Rectangle) join(s Rectangle) {
 	if s.IsZero() {
 		return
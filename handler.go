@@ -13,6 +13,7 @@ import (
 type PaintContext struct {
 	Rectangle
 	origin Position
+	size   Size // Unclipped size of the painted area.
 	view   Position
 }
 
@@ -73,6 +74,132 @@ func removeOnCloseHandler(l **onCloseHandlerList) {
 	}
 }
 
+// OnCloseQueryHandler is called before closing a window. If there was a
+// previous handler installed, it's passed in prev. The handler then has the
+// opportunity to call the previous handler before or after its own execution.
+// The handler should return false to abort closing the window.
+type OnCloseQueryHandler func(w *Window, prev OnCloseQueryHandler) bool
+
+type onCloseQueryHandlerList struct {
+	prev      *onCloseQueryHandlerList
+	h         OnCloseQueryHandler
+	finalizer func()
+}
+
+func addOnCloseQueryHandler(l **onCloseQueryHandlerList, h OnCloseQueryHandler, finalizer func()) {
+	prev := *l
+	if prev == nil {
+		*l = &onCloseQueryHandlerList{
+			h:         h,
+			finalizer: finalizer,
+		}
+		return
+	}
+
+	*l = &onCloseQueryHandlerList{
+		prev: prev,
+		h: func(w *Window, _ OnCloseQueryHandler) bool {
+			return h(w, prev.h)
+		},
+		finalizer: finalizer,
+	}
+}
+
+func (l *onCloseQueryHandlerList) clear() {
+	for l != nil {
+		if f := l.finalizer; f != nil {
+			f()
+		}
+		l = l.prev
+	}
+}
+
+func (l *onCloseQueryHandlerList) handle(w *Window) bool {
+	if l != nil {
+		w.BeginUpdate()
+		r := l.h(w, nil)
+		w.EndUpdate()
+		return r
+	}
+
+	return true
+}
+
+func removeOnCloseQueryHandler(l **onCloseQueryHandlerList) {
+	node := *l
+	*l = node.prev
+	if f := node.finalizer; f != nil {
+		f()
+	}
+}
+
+// OnDragHandler handles the start, the mouse moves and the end of a mouse
+// drag. screenPos0 is the screen position where the drag started, screenPos
+// is the current mouse screen position and winPos is screenPos in window
+// coordinates. If there was a previous handler installed, it's passed in prev.
+// The handler then has the opportunity to call the previous handler before or
+// after its own execution. The handler should return true if it consumes the
+// event and it should not be considered by other subscribed handlers.
+type OnDragHandler func(w *Window, prev OnDragHandler, button tcell.ButtonMask, screenPos0, screenPos, winPos Position, mods tcell.ModMask) bool
+
+// OnDragHandlerList represents a list of handlers subscribed to an event.
+type OnDragHandlerList struct {
+	prev      *OnDragHandlerList
+	h         OnDragHandler
+	finalizer func()
+}
+
+// AddOnDragHandler adds a handler to the handler list.
+func AddOnDragHandler(l **OnDragHandlerList, h OnDragHandler, finalizer func()) {
+	prev := *l
+	if prev == nil {
+		*l = &OnDragHandlerList{
+			h:         h,
+			finalizer: finalizer,
+		}
+		return
+	}
+
+	*l = &OnDragHandlerList{
+		prev: prev,
+		h: func(w *Window, _ OnDragHandler, button tcell.ButtonMask, screenPos0, screenPos, winPos Position, mods tcell.ModMask) bool {
+			return h(w, prev.h, button, screenPos0, screenPos, winPos, mods)
+		},
+		finalizer: finalizer,
+	}
+}
+
+// Clear calls any finalizers on the handler list.
+func (l *OnDragHandlerList) Clear() {
+	for l != nil {
+		if f := l.finalizer; f != nil {
+			f()
+		}
+		l = l.prev
+	}
+}
+
+// Handle performs handling mouse drag events.
+func (l *OnDragHandlerList) Handle(w *Window, button tcell.ButtonMask, screenPos0, screenPos, winPos Position, mods tcell.ModMask) bool {
+	if l != nil {
+		w.BeginUpdate()
+		r := l.h(w, nil, button, screenPos0, screenPos, winPos, mods)
+		w.EndUpdate()
+		return r
+	}
+
+	return false
+}
+
+// RemoveOnDragHandler undoes the most recent call to AddOnDragHandler.
+func RemoveOnDragHandler(l **OnDragHandlerList) {
+	node := *l
+	*l = node.prev
+	if f := node.finalizer; f != nil {
+		f()
+	}
+}
+
 // OnKeyHandler handles key events. If there was a previous handler installed,
 // it's passed in prev. The handler then has the opportunity to call the
 // previous handler before or after its own execution.  The handler should
@@ -97,13 +224,20 @@ func addOnKeyHandler(l **onKeyHandlerList, h OnKeyHandler, finalizer func()) {
 		return
 	}
 
-	*l = &onKeyHandlerList{
-		prev: prev,
-		h: func(w *Window, _ OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
-			return h(w, prev.h, key, mod, r)
-		},
+	// The previous handler is looked up on every call, so that
+	// removeOnKeyHandlerNode can unlink it.
+	n := &onKeyHandlerList{
+		prev:      prev,
 		finalizer: finalizer,
 	}
+	n.h = func(w *Window, _ OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+		var prev OnKeyHandler
+		if n.prev != nil {
+			prev = n.prev.h
+		}
+		return h(w, prev, key, mod, r)
+	}
+	*l = n
 }
 
 func (l *onKeyHandlerList) clear() {
@@ -134,6 +268,20 @@ func removeOnKeyHandler(l **onKeyHandlerList) {
 	}
 }
 
+// removeOnKeyHandlerNode removes node from the list l, wherever it is. It's a
+// nop if node is not in l.
+func removeOnKeyHandlerNode(l **onKeyHandlerList, node *onKeyHandlerList) {
+	for p := l; *p != nil; p = &(*p).prev {
+		if *p == node {
+			*p = node.prev
+			if f := node.finalizer; f != nil {
+				f()
+			}
+			return
+		}
+	}
+}
+
 // OnMouseHandler handles mouse events. If there was a previous handler
 // installed, it's passed in prev. The handler then has the opportunity to call
 // the previous handler before or after its own execution. The handler should
@@ -159,13 +307,20 @@ func AddOnMouseHandler(l **OnMouseHandlerList, h OnMouseHandler, finalizer func(
 		return
 	}
 
-	*l = &OnMouseHandlerList{
-		prev: prev,
-		h: func(w *Window, _ OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
-			return h(w, prev.h, button, screenPos, winPos, mods)
-		},
+	// The previous handler is looked up on every call, so that
+	// removeOnMouseHandlerNode can unlink it.
+	n := &OnMouseHandlerList{
+		prev:      prev,
 		finalizer: finalizer,
 	}
+	n.h = func(w *Window, _ OnMouseHandler, button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) bool {
+		var prev OnMouseHandler
+		if n.prev != nil {
+			prev = n.prev.h
+		}
+		return h(w, prev, button, screenPos, winPos, mods)
+	}
+	*l = n
 }
 
 // Clear calls any finalizers on the handler list.
@@ -199,6 +354,20 @@ func RemoveOnMouseHandler(l **OnMouseHandlerList) {
 	}
 }
 
+// removeOnMouseHandlerNode removes node from the list l, wherever it is. It's
+// a nop if node is not in l.
+func removeOnMouseHandlerNode(l **OnMouseHandlerList, node *OnMouseHandlerList) {
+	for p := l; *p != nil; p = &(*p).prev {
+		if *p == node {
+			*p = node.prev
+			if f := node.finalizer; f != nil {
+				f()
+			}
+			return
+		}
+	}
+}
+
 // OnPaintHandler handles paint requests. If there was a previous handler
 // installed, it's passed in prev. The handler then has the opportunity to call
 // the previous handler before or after its own execution.
@@ -332,7 +501,6 @@ func removeOnSetDesktopHandler(l **onSetDesktopHandlerList) {
 	if f := node.finalizer; f != nil {
 		f()
 	}
-
 }
 
 // OnSetDurationHandler handles requests to change values of type time.Duration.
@@ -609,23 +777,25 @@ func RemoveOnSetPositionHandler(l **OnSetPositionHandlerList) {
 // own execution.
 type OnSetRectangleHandler func(w *Window, prev OnSetRectangleHandler, dst *Rectangle, src Rectangle)
 
-type onSetRectangleHandlerList struct {
-	prev      *onSetRectangleHandlerList
+// OnSetRectangleHandlerList represents a list of handlers subscribed to an event.
+type OnSetRectangleHandlerList struct {
+	prev      *OnSetRectangleHandlerList
 	h         OnSetRectangleHandler
 	finalizer func()
 }
 
-func addOnSetRectangleHandler(l **onSetRectangleHandlerList, h OnSetRectangleHandler, finalizer func()) {
+// AddOnSetRectangleHandler adds a handler to the handler list.
+func AddOnSetRectangleHandler(l **OnSetRectangleHandlerList, h OnSetRectangleHandler, finalizer func()) {
 	prev := *l
 	if prev == nil {
-		*l = &onSetRectangleHandlerList{
+		*l = &OnSetRectangleHandlerList{
 			h:         h,
 			finalizer: finalizer,
 		}
 		return
 	}
 
-	*l = &onSetRectangleHandlerList{
+	*l = &OnSetRectangleHandlerList{
 		prev: prev,
 		h: func(w *Window, _ OnSetRectangleHandler, dst *Rectangle, src Rectangle) {
 			h(w, prev.h, dst, src)
@@ -634,7 +804,8 @@ func addOnSetRectangleHandler(l **onSetRectangleHandlerList, h OnSetRectangleHan
 	}
 }
 
-func (l *onSetRectangleHandlerList) clear() {
+// Clear calls any finalizers on the handler list.
+func (l *OnSetRectangleHandlerList) Clear() {
 	for l != nil {
 		if f := l.finalizer; f != nil {
 			f()
@@ -643,7 +814,8 @@ func (l *onSetRectangleHandlerList) clear() {
 	}
 }
 
-func (l *onSetRectangleHandlerList) handle(w *Window, dst *Rectangle, src Rectangle) {
+// Handle performs updating of dst from src or calling and associated handler.
+func (l *OnSetRectangleHandlerList) Handle(w *Window, dst *Rectangle, src Rectangle) {
 	if *dst == src {
 		return
 	}
@@ -658,7 +830,8 @@ func (l *onSetRectangleHandlerList) handle(w *Window, dst *Rectangle, src Rectan
 	w.EndUpdate()
 }
 
-func removeOnSetRectangleHandler(l **onSetRectangleHandlerList) {
+// RemoveOnSetRectangleHandler undoes the most recent call to AddOnSetRectangleHandler.
+func RemoveOnSetRectangleHandler(l **OnSetRectangleHandlerList) {
 	node := *l
 	*l = node.prev
 	if f := node.finalizer; f != nil {
@@ -690,13 +863,20 @@ func AddOnSetSizeHandler(l **OnSetSizeHandlerList, h OnSetSizeHandler, finalizer
 		return
 	}
 
-	*l = &OnSetSizeHandlerList{
-		prev: prev,
-		h: func(w *Window, _ OnSetSizeHandler, dst *Size, src Size) {
-			h(w, prev.h, dst, src)
-		},
+	// The previous handler is looked up on every call, so that
+	// removeOnSetSizeHandlerNode can unlink it.
+	n := &OnSetSizeHandlerList{
+		prev:      prev,
 		finalizer: finalizer,
 	}
+	n.h = func(w *Window, _ OnSetSizeHandler, dst *Size, src Size) {
+		var prev OnSetSizeHandler
+		if n.prev != nil {
+			prev = n.prev.h
+		}
+		h(w, prev, dst, src)
+	}
+	*l = n
 }
 
 // Clear calls any finalizers on the handler list.
@@ -734,29 +914,44 @@ func RemoveOnSetSizeHandler(l **OnSetSizeHandlerList) {
 	}
 }
 
+// removeOnSetSizeHandlerNode removes node from the handler list, if present.
+func removeOnSetSizeHandlerNode(l **OnSetSizeHandlerList, node *OnSetSizeHandlerList) {
+	for p := l; *p != nil; p = &(*p).prev {
+		if *p == node {
+			*p = node.prev
+			if f := node.finalizer; f != nil {
+				f()
+			}
+			return
+		}
+	}
+}
+
 // OnSetStringHandler handles requests to change values of type String. If there
 // was a previous handler installed, it's passed in prev. The handler then has
 // the opportunity to call the previous handler before or after its own
 // execution.
 type OnSetStringHandler func(w *Window, prev OnSetStringHandler, dst *string, src string)
 
-type onSetStringHandlerList struct {
-	prev      *onSetStringHandlerList
+// OnSetStringHandlerList represents a list of handlers subscribed to an event.
+type OnSetStringHandlerList struct {
+	prev      *OnSetStringHandlerList
 	h         OnSetStringHandler
 	finalizer func()
 }
 
-func addOnSetStringHandler(l **onSetStringHandlerList, h OnSetStringHandler, finalizer func()) {
+// AddOnSetStringHandler adds a handler to the handler list.
+func AddOnSetStringHandler(l **OnSetStringHandlerList, h OnSetStringHandler, finalizer func()) {
 	prev := *l
 	if prev == nil {
-		*l = &onSetStringHandlerList{
+		*l = &OnSetStringHandlerList{
 			h:         h,
 			finalizer: finalizer,
 		}
 		return
 	}
 
-	*l = &onSetStringHandlerList{
+	*l = &OnSetStringHandlerList{
 		prev: prev,
 		h: func(w *Window, _ OnSetStringHandler, dst *string, src string) {
 			h(w, prev.h, dst, src)
@@ -765,7 +960,8 @@ func addOnSetStringHandler(l **onSetStringHandlerList, h OnSetStringHandler, fin
 	}
 }
 
-func (l *onSetStringHandlerList) clear() {
+// Clear calls any finalizers on the handler list.
+func (l *OnSetStringHandlerList) Clear() {
 	for l != nil {
 		if f := l.finalizer; f != nil {
 			f()
@@ -774,7 +970,8 @@ func (l *onSetStringHandlerList) clear() {
 	}
 }
 
-func (l *onSetStringHandlerList) handle(w *Window, dst *string, src string) {
+// Handle performs updating of dst from src or calling and associated handler.
+func (l *OnSetStringHandlerList) Handle(w *Window, dst *string, src string) {
 	if *dst == src {
 		return
 	}
@@ -789,7 +986,8 @@ func (l *onSetStringHandlerList) handle(w *Window, dst *string, src string) {
 	w.EndUpdate()
 }
 
-func removeOnSetStringHandler(l **onSetStringHandlerList) {
+// RemoveOnSetStringHandler undoes the most recent call to AddOnSetStringHandler.
+func RemoveOnSetStringHandler(l **OnSetStringHandlerList) {
 	node := *l
 	*l = node.prev
 	if f := node.finalizer; f != nil {
@@ -871,23 +1069,25 @@ func RemoveOnSetStyleHandler(l **OnSetStyleHandlerList) {
 // own execution.
 type OnSetWindowHandler func(w *Window, prev OnSetWindowHandler, dst **Window, src *Window)
 
-type onSetWindowHandlerList struct {
-	prev      *onSetWindowHandlerList
+// OnSetWindowHandlerList represents a list of handlers subscribed to an event.
+type OnSetWindowHandlerList struct {
+	prev      *OnSetWindowHandlerList
 	h         OnSetWindowHandler
 	finalizer func()
 }
 
-func addOnSetWindowHandler(l **onSetWindowHandlerList, h OnSetWindowHandler, finalizer func()) {
+// AddOnSetWindowHandler adds a handler to the handler list.
+func AddOnSetWindowHandler(l **OnSetWindowHandlerList, h OnSetWindowHandler, finalizer func()) {
 	prev := *l
 	if prev == nil {
-		*l = &onSetWindowHandlerList{
+		*l = &OnSetWindowHandlerList{
 			h:         h,
 			finalizer: finalizer,
 		}
 		return
 	}
 
-	*l = &onSetWindowHandlerList{
+	*l = &OnSetWindowHandlerList{
 		prev: prev,
 		h: func(w *Window, _ OnSetWindowHandler, dst **Window, src *Window) {
 			h(w, prev.h, dst, src)
@@ -896,7 +1096,8 @@ func addOnSetWindowHandler(l **onSetWindowHandlerList, h OnSetWindowHandler, fin
 	}
 }
 
-func (l *onSetWindowHandlerList) clear() {
+// Clear calls any finalizers on the handler list.
+func (l *OnSetWindowHandlerList) Clear() {
 	for l != nil {
 		if f := l.finalizer; f != nil {
 			f()
@@ -905,7 +1106,8 @@ func (l *onSetWindowHandlerList) clear() {
 	}
 }
 
-func (l *onSetWindowHandlerList) handle(w *Window, dst **Window, src *Window) {
+// Handle performs updating of dst from src or calling and associated handler.
+func (l *OnSetWindowHandlerList) Handle(w *Window, dst **Window, src *Window) {
 	if *dst == src {
 		return
 	}
@@ -920,13 +1122,13 @@ func (l *onSetWindowHandlerList) handle(w *Window, dst **Window, src *Window) {
 	w.EndUpdate()
 }
 
-func removeOnSetWindowHandler(l **onSetWindowHandlerList) {
+// RemoveOnSetWindowHandler undoes the most recent call to AddOnSetWindowHandler.
+func RemoveOnSetWindowHandler(l **OnSetWindowHandlerList) {
 	node := *l
 	*l = node.prev
 	if f := node.finalizer; f != nil {
 		f()
 	}
-
 }
 
 // OnSetWindowStyleHandler handles requests to change values of type
@@ -935,23 +1137,25 @@ func removeOnSetWindowHandler(l **onSetWindowHandlerList) {
 // after its own execution.
 type OnSetWindowStyleHandler func(w *Window, prev OnSetWindowStyleHandler, dst *WindowStyle, src WindowStyle)
 
-type onSetWindowStyleHandlerList struct {
-	prev      *onSetWindowStyleHandlerList
+// OnSetWindowStyleHandlerList represents a list of handlers subscribed to an event.
+type OnSetWindowStyleHandlerList struct {
+	prev      *OnSetWindowStyleHandlerList
 	h         OnSetWindowStyleHandler
 	finalizer func()
 }
 
-func addOnSetWindowStyleHandler(l **onSetWindowStyleHandlerList, h OnSetWindowStyleHandler, finalizer func()) {
+// AddOnSetWindowStyleHandler adds a handler to the handler list.
+func AddOnSetWindowStyleHandler(l **OnSetWindowStyleHandlerList, h OnSetWindowStyleHandler, finalizer func()) {
 	prev := *l
 	if prev == nil {
-		*l = &onSetWindowStyleHandlerList{
+		*l = &OnSetWindowStyleHandlerList{
 			h:         h,
 			finalizer: finalizer,
 		}
 		return
 	}
 
-	*l = &onSetWindowStyleHandlerList{
+	*l = &OnSetWindowStyleHandlerList{
 		prev: prev,
 		h: func(w *Window, _ OnSetWindowStyleHandler, dst *WindowStyle, src WindowStyle) {
 			h(w, prev.h, dst, src)
@@ -960,7 +1164,8 @@ func addOnSetWindowStyleHandler(l **onSetWindowStyleHandlerList, h OnSetWindowSt
 	}
 }
 
-func (l *onSetWindowStyleHandlerList) clear() {
+// Clear calls any finalizers on the handler list.
+func (l *OnSetWindowStyleHandlerList) Clear() {
 	for l != nil {
 		if f := l.finalizer; f != nil {
 			f()
@@ -969,7 +1174,8 @@ func (l *onSetWindowStyleHandlerList) clear() {
 	}
 }
 
-func (l *onSetWindowStyleHandlerList) handle(w *Window, dst *WindowStyle, src WindowStyle) {
+// Handle performs updating of dst from src or calling and associated handler.
+func (l *OnSetWindowStyleHandlerList) Handle(w *Window, dst *WindowStyle, src WindowStyle) {
 	if *dst == src {
 		return
 	}
@@ -984,7 +1190,8 @@ func (l *onSetWindowStyleHandlerList) handle(w *Window, dst *WindowStyle, src Wi
 	w.EndUpdate()
 }
 
-func removeOnSetWindowStyleHandler(l **onSetWindowStyleHandlerList) {
+// RemoveOnSetWindowStyleHandler undoes the most recent call to AddOnSetWindowStyleHandler.
+func RemoveOnSetWindowStyleHandler(l **OnSetWindowStyleHandlerList) {
 	node := *l
 	*l = node.prev
 	if f := node.finalizer; f != nil {
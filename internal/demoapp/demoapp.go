@@ -16,18 +16,7 @@ import (
 )
 
 var (
-	windowStyle = wm.WindowStyle{
-		Border:     wm.Style{Background: tcell.ColorNavy, Foreground: tcell.ColorGreen},
-		ClientArea: wm.Style{Background: tcell.ColorSilver, Foreground: tcell.ColorNavy},
-		Title:      wm.Style{Background: tcell.ColorNavy, Foreground: tcell.ColorSilver},
-	}
-
-	Theme = &wm.Theme{
-		ChildWindow: windowStyle,
-		Desktop: wm.WindowStyle{
-			ClientArea: wm.Style{Background: tcell.ColorTeal, Foreground: tcell.ColorWhite},
-		},
-	}
+	Theme = wm.DefaultTheme()
 
 	logoStyle  = wm.Style{Background: Theme.Desktop.ClientArea.Background, Foreground: tcell.ColorWhite}
 	pnameStyle = wm.Style{Background: Theme.Desktop.ClientArea.Background, Foreground: tcell.ColorNavy}
@@ -88,9 +77,8 @@ func New() (*wm.Application, *wm.Desktop) {
 				prev(w, nil, ctx)
 			}
 
-			sz := w.Size()
-			w.Printf(sz.Width-border-len(pname), 0, pnameStyle, "%s", pname)
-			w.Printf(sz.Width-border-len(logo), sz.Height-border-1, logoStyle, "%s", logo)
+			w.PrintfRight(0, pnameStyle, "%s%*s", pname, border, "")
+			w.PrintfRight(w.Size().Height-border-1, logoStyle, "%s%*s", logo, border, "")
 		}, nil)
 	})
 	return app, d
@@ -22,6 +22,7 @@ const (
 )
 
 type mouseButtonFSM struct {
+	app     *Application           //
 	in      chan *tcell.EventMouse //
 	button  tcell.ButtonMask       //
 	mods    tcell.ModMask          //
@@ -31,8 +32,9 @@ type mouseButtonFSM struct {
 	timeout <-chan time.Time       //
 }
 
-func newMouseButtonFSM(button tcell.ButtonMask) *mouseButtonFSM {
+func newMouseButtonFSM(app *Application, button tcell.ButtonMask) *mouseButtonFSM {
 	m := &mouseButtonFSM{
+		app:    app,
 		in:     make(chan *tcell.EventMouse, 1),
 		button: button,
 		quit:   make(chan struct{}, 1),
@@ -63,7 +65,7 @@ func (m *mouseButtonFSM) run() {
 					m.mods = e.Modifiers()
 					x, y := e.Position()
 					m.pos = Position{x, y}
-					m.timeout = time.After(App.ClickDuration())
+					m.timeout = time.After(m.app.ClickDuration())
 					m.state = mbsDown
 				}
 			case <-m.timeout:
@@ -76,20 +78,20 @@ func (m *mouseButtonFSM) run() {
 			case e := <-m.in:
 				switch e.Buttons() & m.button {
 				case 0: // Button up.
-					if d := App.DoubleClickDuration(); d != 0 {
+					if d := m.app.DoubleClickDuration(); d != 0 {
 						m.timeout = time.After(d)
 						m.state = mbsUp
 						break
 					}
 
-					App.screen.PostEvent(newEventMouse(mouseClick, m.button, m.mods, m.pos))
+					m.app.postEvent(newEventMouse(mouseClick, m.button, m.mods, m.pos))
 					m.state = mbsIdle
 					m.timeout = nil
 				default: // Button down.
 					m.state = mbsIdle
 				}
 			case <-m.timeout:
-				App.screen.PostEvent(newEventMouse(mouseDrag, m.button, m.mods, m.pos))
+				m.app.postEvent(newEventMouse(mouseDrag, m.button, m.mods, m.pos))
 				m.state = mbsDrag
 			case <-m.quit:
 				return
@@ -101,11 +103,11 @@ func (m *mouseButtonFSM) run() {
 				case 0: // Button up.
 					m.state = mbsIdle
 				default: // Button down.
-					App.screen.PostEvent(newEventMouse(mouseDoubleClick, m.button, m.mods, m.pos))
+					m.app.postEvent(newEventMouse(mouseDoubleClick, m.button, m.mods, m.pos))
 					m.state = mbsDown2
 				}
 			case <-m.timeout:
-				App.screen.PostEvent(newEventMouse(mouseClick, m.button, m.mods, m.pos))
+				m.app.postEvent(newEventMouse(mouseClick, m.button, m.mods, m.pos))
 				m.state = mbsIdle
 				m.timeout = nil
 			case <-m.quit:
@@ -131,7 +133,7 @@ func (m *mouseButtonFSM) run() {
 				switch e.Buttons() & m.button {
 				case 0: // Button up.
 					x, y := e.Position()
-					App.screen.PostEvent(newEventMouse(mouseDrop, m.button, e.Modifiers(), Position{x, y}))
+					m.app.postEvent(newEventMouse(mouseDrop, m.button, e.Modifiers(), Position{x, y}))
 					m.state = mbsIdle
 					m.timeout = nil
 				default: // Button down.
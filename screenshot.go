@@ -0,0 +1,122 @@
+// Copyright 2015 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// Format selects the output format of Desktop.Screenshot.
+type Format int
+
+// Values of Format.
+const (
+	FormatText Format = iota // Plain UTF-8 text, trailing spaces trimmed.
+	FormatANSI               // UTF-8 text with ANSI SGR escape sequences.
+)
+
+func writeScreenshot(w io.Writer, cells [][]Cell, format Format) error {
+	var f func(*bufio.Writer, []Cell)
+	switch format {
+	case FormatText:
+		f = writeTextRow
+	case FormatANSI:
+		f = writeANSIRow
+	default:
+		return fmt.Errorf("unsupported screenshot format: %d", format)
+	}
+
+	b := bufio.NewWriter(w)
+	for _, row := range cells {
+		f(b, row)
+		b.WriteByte('\n')
+	}
+	return b.Flush()
+}
+
+// cellText returns the text of c and its width.
+func cellText(c Cell) (string, int) {
+	if c.Mainc < ' ' {
+		return " ", 1
+	}
+
+	s := string(c.Mainc) + string(c.Combc)
+	if runewidth.RuneWidth(c.Mainc) == 2 {
+		return s, 2
+	}
+
+	return s, 1
+}
+
+func writeTextRow(b *bufio.Writer, row []Cell) {
+	var a []string
+	for x := 0; x < len(row); {
+		s, w := cellText(row[x])
+		a = append(a, s)
+		x += w
+	}
+	b.WriteString(strings.TrimRight(strings.Join(a, ""), " "))
+}
+
+func writeANSIRow(b *bufio.Writer, row []Cell) {
+	first := true
+	var last tcell.Style
+	for x := 0; x < len(row); {
+		c := row[x]
+		if first || c.Style != last {
+			b.WriteString(sgr(c.Style))
+			first = false
+			last = c.Style
+		}
+		s, w := cellText(c)
+		b.WriteString(s)
+		x += w
+	}
+	b.WriteString("\x1b[0m")
+}
+
+// sgr returns the ANSI escape sequence selecting s.
+func sgr(s tcell.Style) string {
+	fg, bg, attr := s.Decompose()
+	a := []string{"0"}
+	for _, v := range []struct {
+		tcell.AttrMask
+		code string
+	}{
+		{tcell.AttrBold, "1"},
+		{tcell.AttrDim, "2"},
+		{tcell.AttrUnderline, "4"},
+		{tcell.AttrBlink, "5"},
+		{tcell.AttrReverse, "7"},
+	} {
+		if attr&v.AttrMask != 0 {
+			a = append(a, v.code)
+		}
+	}
+	a = append(a, sgrColor(fg, 30, 90, 38), sgrColor(bg, 40, 100, 48))
+	return "\x1b[" + strings.Join(a, ";") + "m"
+}
+
+func sgrColor(c tcell.Color, base, bright, extended int) string {
+	switch {
+	case c == tcell.ColorDefault:
+		return fmt.Sprint(base + 9)
+	case c&tcell.ColorIsRGB != 0:
+		r, g, b := c.RGB()
+		return fmt.Sprintf("%d;2;%d;%d;%d", extended, r, g, b)
+	case c < 8:
+		return fmt.Sprint(base + int(c))
+	case c < 16:
+		return fmt.Sprint(bright + int(c) - 8)
+	default:
+		return fmt.Sprintf("%d;5;%d", extended, int(c))
+	}
+}
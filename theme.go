@@ -6,16 +6,62 @@ package wm
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"strconv"
+	"strings"
 
 	"github.com/gdamore/tcell"
 )
 
 var (
 	zeroStyle Style
+
+	// colorNames maps colors to their names. Of the aliases, like "gray" and
+	// "grey", the lexically smallest one is used.
+	colorNames = func() map[tcell.Color]string {
+		m := map[tcell.Color]string{}
+		for k, v := range tcell.ColorNames {
+			if s, ok := m[v]; !ok || k < s {
+				m[v] = k
+			}
+		}
+		return m
+	}()
+
+	borderLineRunes = [...]borderRunes{
+		BorderLineSingle: {tcell.RuneULCorner, tcell.RuneURCorner, tcell.RuneLLCorner, tcell.RuneLRCorner, tcell.RuneHLine, tcell.RuneVLine},
+		BorderLineDouble: {'╔', '╗', '╚', '╝', '═', '║'},
+		BorderLineThick:  {'┏', '┓', '┗', '┛', '━', '┃'},
+		BorderLineASCII:  {'+', '+', '+', '+', '-', '|'},
+		BorderLineNone:   {' ', ' ', ' ', ' ', ' ', ' '},
+	}
+)
+
+// BorderLineStyle determines the characters used to draw window borders.
+type BorderLineStyle int
+
+// Values of BorderLineStyle.
+const (
+	BorderLineSingle BorderLineStyle = iota // ┌─┐
+	BorderLineDouble                        // ╔═╗
+	BorderLineThick                         // ┏━┓
+	BorderLineASCII                         // +-+
+	BorderLineNone                          // Blank.
 )
 
+type borderRunes struct {
+	ulc, urc, llc, lrc, h, v rune
+}
+
+func (s BorderLineStyle) runes() *borderRunes {
+	if s < 0 || int(s) >= len(borderLineRunes) {
+		s = BorderLineSingle
+	}
+	return &borderLineRunes[s]
+}
+
 // Style represents a text style.
 type Style struct {
 	Foreground tcell.Color
@@ -32,6 +78,118 @@ func NewStyle(s tcell.Style) Style {
 	return Style{f, b, a}
 }
 
+// NewStyleRGB returns a Style having 24-bit foreground and background colors
+// composed from the red, green and blue components in [0, 255]. On terminals
+// supporting fewer colors tcell renders the closest available color.
+func NewStyleRGB(fgR, fgG, fgB, bgR, bgG, bgB int) Style {
+	return Style{
+		Foreground: tcell.NewRGBColor(int32(fgR), int32(fgG), int32(fgB)),
+		Background: tcell.NewRGBColor(int32(bgR), int32(bgG), int32(bgB)),
+	}
+}
+
+// jsonStyle is the JSON form of Style.
+type jsonStyle struct {
+	Foreground interface{}
+	Background interface{}
+	Attr       tcell.AttrMask
+}
+
+// jsonColor returns the JSON form of c, the most readable one available:
+// "default" for tcell.ColorDefault, the color name for named colors, a
+// "#rrggbb" string for other 24-bit colors and the numeric value otherwise.
+func jsonColor(c tcell.Color) interface{} {
+	if c == tcell.ColorDefault {
+		return "default"
+	}
+
+	if s, ok := colorNames[c]; ok {
+		return s
+	}
+
+	if c&tcell.ColorIsRGB != 0 {
+		return fmt.Sprintf("#%06x", c.Hex())
+	}
+
+	return c
+}
+
+// parseColor returns the color represented by the JSON data b, which is
+// either a number, a "#rrggbb" string, "default" or a color name like "navy".
+// Names are not case sensitive.
+func parseColor(b json.RawMessage) (tcell.Color, error) {
+	var n int32
+	if err := json.Unmarshal(b, &n); err == nil {
+		return tcell.Color(n), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return 0, err
+	}
+
+	if len(s) == 7 && s[0] == '#' {
+		if v, err := strconv.ParseUint(s[1:], 16, 32); err == nil {
+			return tcell.NewHexColor(int32(v)), nil
+		}
+	}
+
+	switch k := strings.ToLower(s); k {
+	case "default":
+		return tcell.ColorDefault, nil
+	default:
+		if c, ok := tcell.ColorNames[k]; ok {
+			return c, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid color: %q", s)
+}
+
+// MarshalJSON implements json.Marshaler. Colors are written in their most
+// readable form: "default", a color name like "navy", a "#rrggbb" string for
+// other 24-bit colors or, for the remaining palette colors, a number.
+func (s Style) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonStyle{jsonColor(s.Foreground), jsonColor(s.Background), s.Attr})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Colors can be given as numbers,
+// as "#rrggbb" strings, as "default" or as X11/ANSI color names like "silver".
+// Values of fields having no JSON data are preserved.
+func (s *Style) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Foreground json.RawMessage
+		Background json.RawMessage
+		Attr       *tcell.AttrMask
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	for _, w := range []struct {
+		b   json.RawMessage
+		dst *tcell.Color
+	}{
+		{v.Foreground, &s.Foreground},
+		{v.Background, &s.Background},
+	} {
+		if w.b == nil {
+			continue
+		}
+
+		c, err := parseColor(w.b)
+		if err != nil {
+			return err
+		}
+
+		*w.dst = c
+	}
+	if v.Attr != nil {
+		s.Attr = *v.Attr
+	}
+	return nil
+}
+
 // TCellStyle converts a Style to a tcell.Style value.
 func (s Style) TCellStyle() tcell.Style {
 	return tcell.Style(0).
@@ -51,11 +209,35 @@ type Theme struct {
 	Desktop     WindowStyle
 }
 
+// DefaultTheme returns a newly created, readable Theme. It's the theme used by
+// the examples.
+func DefaultTheme() *Theme {
+	return &Theme{
+		ChildWindow: WindowStyle{
+			Border:     Style{Background: tcell.ColorNavy, Foreground: tcell.ColorGreen},
+			ClientArea: Style{Background: tcell.ColorSilver, Foreground: tcell.ColorNavy},
+			Title:      Style{Background: tcell.ColorNavy, Foreground: tcell.ColorSilver},
+		},
+		Desktop: WindowStyle{
+			ClientArea: Style{Background: tcell.ColorTeal, Foreground: tcell.ColorWhite},
+		},
+	}
+}
+
 // WindowStyle represents visual styles of a Window.
+//
+// BorderInactive and TitleInactive are used instead of Border and Title when
+// the window is not focused. If BorderInactive is zero, the focused window
+// shows its Border with the reverse attribute toggled. If TitleInactive is
+// zero, Title is used regardless of focus.
 type WindowStyle struct {
-	Border     Style
-	ClientArea Style
-	Title      Style
+	Border         Style
+	BorderInactive Style
+	BorderLine     BorderLineStyle
+	ClientArea     Style
+	Scrollbar      Style // Used by scrollbars of the tk package, if not zero.
+	Title          Style
+	TitleInactive  Style
 }
 
 // Clear sets t to its zero value.
@@ -82,3 +264,46 @@ func (t *Theme) ReadFrom(r io.Reader) (int64, error) {
 
 	return int64(len(b)), json.Unmarshal(b, t)
 }
+
+// Validate returns an error if t contains an obviously broken style, for
+// example a style having identical foreground and background colors, which
+// renders invisible text. Styles that may be left zero to select a fallback,
+// like BorderInactive, are checked only when not zero. Applications can call
+// Validate after ReadFrom to reject bad theme files early.
+func (t *Theme) Validate() error {
+	if err := t.ChildWindow.validate("ChildWindow", true); err != nil {
+		return err
+	}
+
+	return t.Desktop.validate("Desktop", false)
+}
+
+// validate checks the styles of s. The border and title styles are required
+// only if borders is true.
+func (s *WindowStyle) validate(name string, borders bool) error {
+	if s.BorderLine < 0 || int(s.BorderLine) >= len(borderLineRunes) {
+		return fmt.Errorf("%s.BorderLine: invalid value %d", name, s.BorderLine)
+	}
+
+	for _, v := range []struct {
+		name     string
+		required bool
+		s        Style
+	}{
+		{"Border", borders, s.Border},
+		{"BorderInactive", false, s.BorderInactive},
+		{"ClientArea", true, s.ClientArea},
+		{"Scrollbar", false, s.Scrollbar},
+		{"Title", borders, s.Title},
+		{"TitleInactive", false, s.TitleInactive},
+	} {
+		if !v.required && v.s.IsZero() {
+			continue
+		}
+
+		if v.s.Foreground == v.s.Background && v.s.Foreground != tcell.ColorDefault {
+			return fmt.Errorf("%s.%s: identical foreground and background color %d", name, v.name, v.s.Foreground)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wm
+
+// Timer represents a function scheduled to execute on the event handler
+// goroutine by Application.AfterFunc or Application.Tick.
+//
+// Timer methods must be called only directly from an event handler goroutine
+// or from a function that was enqueued using Application.Post or
+// Application.PostWait.
+type Timer struct {
+	app     *Application  //
+	pending int32         // Atomic. The function is posted but not yet executed.
+	stop    chan struct{} // Closed by Stop.
+	stopped bool          //
+}
+
+func newTimer(a *Application) *Timer { return &Timer{app: a, stop: make(chan struct{})} }
+
+// post enqueues f unless it is already enqueued. f is not executed if t is
+// stopped before f is dequeued. post returns false if the event queue is full
+// and f was dropped, the next post enqueues it again.
+func (t *Timer) post(f func()) bool {
+	return t.app.postOnce(&t.pending, func() {
+		if !t.stopped {
+			f()
+		}
+	})
+}
+
+// Stop prevents any further executions of the function scheduled by t.
+// Stopping a stopped timer has no effect.
+func (t *Timer) Stop() {
+	if t.stopped {
+		return
+	}
+
+	t.stopped = true
+	close(t.stop)
+}
+
+// Stopped returns whether t was stopped. Timers created by AfterFunc stop
+// after executing their function.
+func (t *Timer) Stopped() bool { return t.stopped }
@@ -11,6 +11,10 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
 )
 
 func caller(s string, va ...interface{}) {
@@ -49,8 +53,1135 @@ func init() {
 	use(caller, dbg, TODO) //TODOOK
 }
 
+func newApp(t testing.TB) (*wm.Application, tcell.SimulationScreen) {
+	s := tcell.NewSimulationScreen("")
+	app, err := wm.NewApplicationScreen(s, &wm.Theme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.PostWait(func() {
+		app.SetDoubleClickDuration(0)
+		d := app.NewDesktop()
+		app.SetDesktop(d)
+	})
+	return app, s
+}
+
+func exit(t testing.TB, app *wm.Application) {
+	app.PostWait(func() { app.Exit(nil) })
+	if err := app.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// query returns the result of f executed by the event handler goroutine.
+func query(app *wm.Application, f func() interface{}) string {
+	return fmt.Sprint(app.Query(f))
+}
+
+// waitFor waits until f, executed by the event handler goroutine, returns e.
+// Mouse clicks and drags are reported asynchronously.
+func waitFor(t testing.TB, app *wm.Application, e string, f func() interface{}) {
+	t.Helper()
+	var g string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if g = query(app, f); g == e {
+			return
+		}
+	}
+
+	t.Fatalf("got %q, expected %q", g, e)
+}
+
+// click injects a click of the left mouse button at x, y.
+func click(s tcell.SimulationScreen, x, y int) {
+	s.InjectMouse(x, y, tcell.Button1, 0)
+	s.InjectMouse(x, y, tcell.ButtonNone, 0)
+}
+
+// press injects a press of the left mouse button at x, y and waits until cond
+// holds, eg. until the drag started after the click duration.
+func press(t testing.TB, app *wm.Application, s tcell.SimulationScreen, x, y int, e string, cond func() interface{}) {
+	t.Helper()
+	s.InjectMouse(x, y, tcell.Button1, 0)
+	waitFor(t, app, e, cond)
+}
+
+func screenText(s tcell.Screen, area wm.Rectangle) string {
+	var a []string
+	for y := area.Y; y < area.Y+area.Height; y++ {
+		var b []rune
+		for x := area.X; x < area.X+area.Width; x++ {
+			r, _, _, w := s.GetContent(x, y)
+			b = append(b, r)
+			if w == 2 {
+				x++
+			}
+		}
+		a = append(a, string(b))
+	}
+	return strings.Join(a, "\n")
+}
+
 // ============================================================================
 
 func Test(t *testing.T) {
 	t.Logf("TODO")
 }
+
+func TestAlignX(t *testing.T) {
+	for i, v := range []struct {
+		a        Alignment
+		width, n int
+		e        int
+	}{
+		{AlignLeft, 10, 4, 0},
+		{AlignCenter, 10, 4, 3},
+		{AlignCenter, 10, 5, 2},
+		{AlignCenter, 3, 5, 0},
+		{AlignRight, 10, 4, 6},
+		{AlignRight, 3, 5, 0},
+	} {
+		if g, e := alignX(v.a, v.width, v.n), v.e; g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+	}
+}
+
+func TestButtonText(t *testing.T) {
+	if g, e := buttonText("OK", false), "OK"; g != e {
+		t.Errorf("got %q, expected %q", g, e)
+	}
+	if g, e := buttonText("OK", true), "[ OK ]"; g != e {
+		t.Errorf("got %q, expected %q", g, e)
+	}
+}
+
+func TestRunesColumnIndex(t *testing.T) {
+	s := []rune("a世b")
+	for i, e := range []int{0, 1, 3, 4} {
+		if g := runesColumn(s, i); g != e {
+			t.Errorf("column of %d: got %v, expected %v", i, g, e)
+		}
+	}
+	for col, e := range []int{0, 1, 1, 2, 3, 3} {
+		if g := runesIndex(s, col); g != e {
+			t.Errorf("index at %d: got %v, expected %v", col, g, e)
+		}
+	}
+}
+
+func TestTextAreaMetrics(t *testing.T) {
+	ta := &TextArea{lines: [][]rune{[]rune("ab"), []rune("世界x"), nil}}
+	ta.measure()
+	if g, e := ta.Metrics(wm.Rectangle{}), (wm.Size{Width: 6, Height: 3}); g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+
+	if g, e := strings.Join(ta.Lines(), "|"), "ab|世界x|"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestProgressFill(t *testing.T) {
+	for i, v := range []struct {
+		value float64
+		n, e  int
+	}{
+		{0, 10, 0},
+		{0.05, 10, 0},
+		{0.1, 10, 1},
+		{0.99, 10, 9},
+		{1, 10, 10},
+		{0.5, 0, 0},
+	} {
+		if g, e := progressFill(v.value, v.n), v.e; g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+	}
+	if g, e := progressLabel(0.425), "42%"; g != e {
+		t.Errorf("got %q, expected %q", g, e)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	for i, v := range []struct {
+		s     string
+		width int
+		e     string
+	}{
+		{"", 10, ""},
+		{"foo bar baz", 7, "foo bar|baz"},
+		{"foo  bar", 20, "foo bar"},
+		{"foo\nbar baz", 20, "foo|bar baz"},
+		{"abcdefgh ij", 3, "abc|def|gh|ij"},
+		{"世界世界", 5, "世界|世界"},
+	} {
+		if g, e := strings.Join(wrapText(v.s, v.width), "|"), v.e; g != e {
+			t.Errorf("#%d: got %q, expected %q", i, g, e)
+		}
+	}
+}
+
+func TestStatusLayout(t *testing.T) {
+	segs := []statusSegment{
+		{AlignLeft, "ab"},
+		{AlignRight, "cde"},
+		{AlignLeft, "f"},
+		{AlignRight, "gh"},
+	}
+	if g, e := fmt.Sprint(statusLayout(20, segs)), "[1 11 6 17]"; g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+}
+
+func TestMenuLayout(t *testing.T) {
+	if g, e := fmt.Sprint(menuBarLayout([]string{"File", "Edit", "世"})), "[1 7 13]"; g != e {
+		t.Errorf("got %v, expected %v", g, e)
+	}
+	if g, e := menuWidth([]MenuItem{{Label: "Open"}, {Label: "Quit", Accelerator: "Ctrl+Q"}}), 14; g != e {
+		t.Errorf("got %v, expected %v", g, e)
+	}
+	if g, e := menuWidth([]MenuItem{{Label: "Open"}}), 6; g != e {
+		t.Errorf("got %v, expected %v", g, e)
+	}
+}
+
+func TestListboxMetrics(t *testing.T) {
+	l := &Listbox{items: []string{"a", "世界", "abc"}, maxWidth: 4}
+	if g, e := l.Metrics(wm.Rectangle{}), (wm.Size{Width: 4, Height: 3}); g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+}
+
+func TestLineMeter(t *testing.T) {
+	for i, v := range []struct {
+		s        string
+		tabWidth int
+		e        int
+	}{
+		{"", 8, 0},
+		{"abc", 8, 3},
+		{"\tx", 8, 9},
+		{"ab\tx", 4, 5},
+		{"\tx", 0, 1},
+		{"世界", 8, 4},
+		{"世\tx", 8, 9},
+	} {
+		if g, e := lineWidth([]byte(v.s), v.tabWidth), v.e; g != e {
+			t.Errorf("%v: got %v, expected %v", i, g, e)
+		}
+	}
+
+	m := NewLineMeter([][]byte{[]byte("a"), []byte("世界\t"), nil}, 8)
+	if g, e := m.Metrics(wm.Rectangle{}), (wm.Size{Width: 8, Height: 3}); g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+}
+
+func TestWrapMeter(t *testing.T) {
+	m := NewWrapMeter([][]byte{[]byte("abcdefg"), nil, []byte("a\tb"), []byte("ab世界")}, 4)
+	if g, e := fmt.Sprintf("%q", m.Wrap(3)), `["abc" "def" "g" "" "a  " " b" "ab" "世" "界"]`; g != e {
+		t.Fatalf("\ngot %s\nexp %s", g, e)
+	}
+	if g, e := m.Metrics(wm.Rectangle{Size: wm.Size{Width: 7, Height: 2}}), (wm.Size{Width: 7, Height: 4}); g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+}
+
+func TestTileSpans(t *testing.T) {
+	for i, v := range []struct {
+		total, n int
+		e        string
+	}{
+		{0, 0, "[]"},
+		{0, 2, "[0 0]"},
+		{10, 1, "[10]"},
+		{10, 2, "[5 5]"},
+		{10, 3, "[4 3 3]"},
+		{11, 4, "[3 3 3 2]"},
+		{2, 3, "[1 1 0]"},
+		{-1, 2, "[0 0]"},
+	} {
+		if g, e := fmt.Sprint(tileSpans(v.total, v.n)), v.e; g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+	}
+}
+
+func TestSplitExtent(t *testing.T) {
+	for i, v := range []struct {
+		total, min int
+		ratio      float64
+		e          int
+	}{
+		{0, 1, 0.5, 0},
+		{1, 1, 0.5, 0},
+		{2, 1, 0.5, 0},
+		{3, 1, 0.5, 1},
+		{11, 1, 0.5, 5},
+		{11, 1, 0, 1},
+		{11, 1, 1, 9},
+		{11, 0, 0, 0},
+		{11, 0, 1, 10},
+		{11, 3, 0.1, 3},
+		{11, 3, 0.9, 7},
+		{5, 3, 0.5, 2},
+	} {
+		if g, e := splitExtent(v.total, v.min, v.ratio), v.e; g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+	}
+}
+
+func TestTabLayout(t *testing.T) {
+	titles := []string{"a", "bcd", "世界"}
+	if g, e := fmt.Sprint(tabLayout(titles)), "[1 5 11]"; g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+
+	for i, v := range []struct {
+		x, e int
+	}{
+		{0, -1},
+		{1, 0},
+		{3, 0},
+		{4, -1},
+		{5, 1},
+		{9, 1},
+		{10, -1},
+		{11, 2},
+		{16, 2},
+		{17, -1},
+	} {
+		if g, e := tabAt(titles, v.x), v.e; g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+	}
+}
+
+func TestTreeRows(t *testing.T) {
+	c := &TreeNode{Label: "c", Children: []*TreeNode{{Label: "d"}}}
+	b := &TreeNode{Label: "b", Children: []*TreeNode{c, {Label: "e"}}}
+	root := &TreeNode{Label: "a", Children: []*TreeNode{b, {Label: "f"}}}
+	text := func() string {
+		var a []string
+		for _, v := range treeRows(root) {
+			a = append(a, fmt.Sprintf("%d:%s", v.parent, treeText(v)))
+		}
+		return strings.Join(a, "|")
+	}
+
+	if g, e := text(), "-1:[+] a"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	root.expanded = true
+	b.expanded = true
+	if g, e := text(), "-1:[-] a|0:├─[-] b|1:│ ├─[+] c|1:│ └─ e|0:└─ f"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	c.expanded = true
+	if g, e := text(), "-1:[-] a|0:├─[-] b|1:│ ├─[-] c|2:│ │ └─ d|1:│ └─ e|0:└─ f"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	if g := treeRows(nil); g != nil {
+		t.Fatalf("got %v, expected nil", g)
+	}
+}
+
+func TestTableLayout(t *testing.T) {
+	cols := []TableColumn{
+		{AlignLeft, "a", 5},
+		{AlignRight, "b", 4},
+		{AlignCenter, "c", 6},
+		{AlignLeft, "d", -1},
+	}
+	xs, width := tableColumnX(cols)
+	if g, e := fmt.Sprint(xs, width), "[0 5 9 15] 15"; g != e {
+		t.Fatalf("got %v, expected %v", g, e)
+	}
+
+	for i, v := range []struct {
+		c int
+		s string
+		e int
+	}{
+		{0, "ab", 0},
+		{1, "ab", 2},
+		{1, "abcdef", 0},
+		{1, "世", 2},
+		{2, "ab", 2},
+		{2, "世界", 1},
+	} {
+		if g, e := tableCellX(cols[v.c], v.s), v.e; g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+	}
+}
+
+func TestTooltipPosition(t *testing.T) {
+	area := wm.Size{Width: 80, Height: 25}
+	for i, v := range []struct {
+		p  wm.Position
+		sz wm.Size
+		e  wm.Position
+	}{
+		{wm.Position{X: 10, Y: 10}, wm.Size{Width: 6, Height: 1}, wm.Position{X: 11, Y: 11}},
+		{wm.Position{X: 78, Y: 10}, wm.Size{Width: 6, Height: 1}, wm.Position{X: 74, Y: 11}},
+		{wm.Position{X: 10, Y: 24}, wm.Size{Width: 6, Height: 1}, wm.Position{X: 11, Y: 23}},
+		{wm.Position{X: 10, Y: 0}, wm.Size{Width: 6, Height: 30}, wm.Position{X: 11, Y: 0}},
+		{wm.Position{X: 0, Y: 0}, wm.Size{Width: 90, Height: 1}, wm.Position{X: 0, Y: 1}},
+	} {
+		if g, e := tooltipPosition(v.p, v.sz, area), v.e; g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+	}
+}
+
+func TestHandleGeometry(t *testing.T) {
+	for i, v := range []struct {
+		origin, viewport, content, track int
+		pos, size                        int
+	}{
+		{0, 10, 0, 8, 0, 0},
+		{0, 10, 5, 8, 0, 8},
+		{0, 10, 10, 8, 0, 8},
+		// Small overflow.
+		{0, 10, 11, 8, 0, 7},
+		{1, 10, 11, 8, 1, 7},
+		{0, 20, 21, 20, 0, 19},
+		{1, 20, 21, 20, 1, 19},
+		// Large overflow.
+		{0, 10, 1000, 8, 0, 1},
+		{1, 10, 1000, 8, 1, 1},
+		{495, 10, 1000, 8, 4, 1},
+		{989, 10, 1000, 8, 6, 1},
+		{990, 10, 1000, 8, 7, 1},
+		{995, 10, 1000, 8, 7, 1},
+		// Medium overflow.
+		{0, 10, 30, 9, 0, 3},
+		{10, 10, 30, 9, 3, 3},
+		{19, 10, 30, 9, 5, 3},
+		{20, 10, 30, 9, 6, 3},
+	} {
+		pos, size := handleGeometry(v.origin, v.viewport, v.content, v.track, 1)
+		if g, e := fmt.Sprint(pos, size), fmt.Sprint(v.pos, v.size); g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+	}
+}
+
+func TestMinHandleSize(t *testing.T) {
+	for i, v := range []struct {
+		origin, viewport, content, track, min int
+		pos, size                             int
+	}{
+		{0, 20, 100000, 18, 0, 0, 1},
+		{0, 20, 100000, 18, 4, 0, 4},
+		{1, 20, 100000, 18, 4, 1, 4},
+		{50000, 20, 100000, 18, 4, 7, 4},
+		{99979, 20, 100000, 18, 4, 13, 4},
+		{99980, 20, 100000, 18, 4, 14, 4},
+		{0, 20, 100000, 3, 4, 0, 3},
+		{0, 20, 40, 18, 4, 0, 9},
+	} {
+		pos, size := handleGeometry(v.origin, v.viewport, v.content, v.track, v.min)
+		if g, e := fmt.Sprint(pos, size), fmt.Sprint(v.pos, v.size); g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+
+	}
+}
+
+func TestHandleOrigin(t *testing.T) {
+	for i, v := range []struct {
+		pos, size, viewport, content, track int
+		e                                   int
+	}{
+		{0, 4, 20, 100000, 18, 0},
+		{7, 4, 20, 100000, 18, 49990},
+		{14, 4, 20, 100000, 18, 99980},
+		{5, 18, 20, 10, 18, 0},
+		{1, 7, 10, 11, 8, 1},
+	} {
+		if g, e := handleOrigin(v.pos, v.size, v.viewport, v.content, v.track), v.e; g != e {
+			t.Errorf("#%d: got %v, expected %v", i, g, e)
+		}
+	}
+}
+
+func TestFitContent(t *testing.T) {
+	cur := wm.Size{Width: 7, Height: 8}
+	max := wm.Size{Width: 20, Height: 10}
+	for i, v := range []struct {
+		metrics, e wm.Size
+	}{
+		{wm.Size{}, wm.Size{}},
+		{wm.Size{Width: 5, Height: 3}, wm.Size{Width: 5, Height: 3}},
+		{wm.Size{Width: 30, Height: 3}, wm.Size{Width: 20, Height: 3}},
+		{wm.Size{Width: 5, Height: 30}, wm.Size{Width: 5, Height: 10}},
+		{wm.Size{Width: -1, Height: 3}, wm.Size{Width: 7, Height: 3}},
+		{wm.Size{Width: 5, Height: -1}, wm.Size{Width: 5, Height: 8}},
+		{wm.Size{Width: -1, Height: -1}, cur},
+	} {
+		if g, e := fitContent(v.metrics, cur, max), v.e; g != e {
+			t.Errorf("%v: %v %v", i, g, e)
+		}
+	}
+}
+
+func TestListboxClick(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var l *Listbox
+	app.PostWait(func() {
+		var items []string
+		for i := 0; i < 100; i++ {
+			items = append(items, fmt.Sprint(i))
+		}
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Position: wm.Position{X: 10, Y: 5}, Size: wm.Size{Width: 10, Height: 10}})
+		l = NewListbox(w, items)
+		l.SetOrigin(wm.Position{Y: 25})
+	})
+	selected := func() interface{} { return l.Selected() }
+	if g, e := query(app, selected), "0"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	// The first visible row.
+	click(s, 11, 6)
+	waitFor(t, app, "25", selected)
+
+	click(s, 11, 8)
+	waitFor(t, app, "27", selected)
+}
+
+func TestMenuClick(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var selected []int
+	items := func() []MenuItem {
+		var r []MenuItem
+		for i := 0; i < 3; i++ {
+			i := i
+			r = append(r, MenuItem{Label: fmt.Sprint(i), Action: func() { selected = append(selected, i) }})
+		}
+		return r
+	}
+	sel := func() interface{} { return selected }
+	app.PostWait(func() { NewMenu(app.Desktop().Root(), wm.Position{X: 5, Y: 5}, items()) })
+	click(s, 6, 6)
+	waitFor(t, app, "[0]", sel)
+
+	app.PostWait(func() { NewMenu(app.Desktop().Root(), wm.Position{X: 5, Y: 5}, items()) })
+	click(s, 6, 8)
+	waitFor(t, app, "[0 2]", sel)
+
+	var b *MenuBar
+	app.PostWait(func() {
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Position: wm.Position{X: 2, Y: 2}, Size: wm.Size{Width: 20, Height: 5}})
+		b = NewMenuBar(w)
+		b.AddMenu("File", items())
+		b.AddMenu("Edit", items())
+	})
+	click(s, 10, 3)
+	waitFor(t, app, "true 1", func() interface{} { return fmt.Sprint(b.Menu() != nil, b.opened) })
+}
+
+func TestTableClick(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var tb *Table
+	app.PostWait(func() {
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Position: wm.Position{X: 10, Y: 5}, Size: wm.Size{Width: 10, Height: 10}})
+		tb = NewTable(w, []TableColumn{{Title: "n", Width: 5}})
+		var rows [][]string
+		for i := 0; i < 100; i++ {
+			rows = append(rows, []string{fmt.Sprint(i)})
+		}
+		tb.SetRows(rows)
+	})
+	selected := func() interface{} { return tb.Selected() }
+	if g, e := query(app, selected), "0"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	// The third data row.
+	click(s, 11, 9)
+	waitFor(t, app, "2", selected)
+
+	// Clicking the header selects nothing.
+	click(s, 11, 6)
+	click(s, 11, 7)
+	waitFor(t, app, "0", selected)
+
+	app.PostWait(func() { tb.SetOrigin(wm.Position{Y: 25}) })
+	click(s, 11, 7)
+	waitFor(t, app, "25", selected)
+}
+
+func TestEntryClick(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var e *Entry
+	app.PostWait(func() {
+		e = NewEntry(app.Desktop().Root(), wm.Rectangle{Position: wm.Position{X: 10, Y: 5}, Size: wm.Size{Width: 10, Height: 1}}, strings.Repeat("0123456789", 3))
+	})
+	caret := func() interface{} { return fmt.Sprint(e.Origin().X, e.Caret()) }
+	o := app.Query(func() interface{} { return e.Origin().X }).(int)
+	if o == 0 {
+		t.Fatal("entry not scrolled")
+	}
+
+	click(s, 10, 5)
+	waitFor(t, app, fmt.Sprint(o, o), caret)
+
+	click(s, 13, 5)
+	waitFor(t, app, fmt.Sprint(o, o+3), caret)
+}
+
+func TestTextAreaClick(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var ta *TextArea
+	app.PostWait(func() {
+		var lines []string
+		for i := 0; i < 100; i++ {
+			lines = append(lines, fmt.Sprintf("line %v", i))
+		}
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Position: wm.Position{X: 10, Y: 5}, Size: wm.Size{Width: 10, Height: 10}})
+		ta = NewTextArea(w, lines)
+		ta.SetOrigin(wm.Position{Y: 25})
+	})
+	caret := func() interface{} { return ta.Caret() }
+	click(s, 11, 6)
+	waitFor(t, app, "{0 25}", caret)
+
+	click(s, 13, 7)
+	waitFor(t, app, "{2 26}", caret)
+}
+
+func TestTreeViewClick(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	root := &TreeNode{Label: "root"}
+	for i := 0; i < 50; i++ {
+		root.Children = append(root.Children, &TreeNode{Label: fmt.Sprint(i), Children: []*TreeNode{{Label: "leaf"}}})
+	}
+	var tv *TreeView
+	app.PostWait(func() {
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Position: wm.Position{X: 10, Y: 5}, Size: wm.Size{Width: 20, Height: 10}})
+		tv = NewTreeView(w, root)
+		tv.Expand(root)
+		tv.SetOrigin(wm.Position{Y: 25})
+	})
+	selected := func() interface{} { return tv.Selected().Label }
+
+	// Row 25 shows node 24.
+	click(s, 20, 6)
+	waitFor(t, app, "24", selected)
+
+	// The [+] indicator of node 25 is after the indentation guide.
+	click(s, 13, 7)
+	waitFor(t, app, "true", func() interface{} { return root.Children[25].Expanded() })
+}
+
+func TestViewWrapResize(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var v *View
+	app.PostWait(func() {
+		m := NewWrapMeter([][]byte{[]byte("abcdefghijklmnop")}, 8)
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 12, Height: 6}})
+		v = NewView(w, m)
+		v.SetWrap(true)
+		w.OnPaintClientArea(func(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+			if prev != nil {
+				prev(w, nil, ctx)
+			}
+
+			for y, b := range m.Wrap(w.ClientSize().Width) {
+				w.Print(0, y, w.ClientAreaStyle(), string(b))
+			}
+		}, nil)
+		w.Invalidate(w.Area())
+	})
+	area := wm.Rectangle{Position: wm.Position{X: 1, Y: 1}, Size: wm.Size{Width: 10, Height: 3}}
+	text := func() interface{} { return screenText(s, area) }
+	if g, e := query(app, text), "abcdefghij\nklmnop    \n          "; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.PostWait(func() { v.SetSize(wm.Size{Width: 8, Height: 6}) })
+	area.Width = 6
+	if g, e := query(app, text), "abcdef\nghijkl\nmnop  "; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestMessageBoxFilters(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	result := -1
+	clicks := 0
+	app.PostWait(func() {
+		MessageBox(app.Desktop(), "title", "message", []string{"OK", "Cancel"}, func(n int) { result = n })
+		// Added after the message box, like a menu opened from it would.
+		app.OnMouse(func(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+			clicks++
+			return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+		}, nil)
+	})
+	s.InjectKey(tcell.KeyEscape, 0, 0)
+	waitFor(t, app, "1 0", func() interface{} { return fmt.Sprint(result, app.Desktop().Root().Children()) })
+
+	click(s, 0, 0)
+	waitFor(t, app, "1", func() interface{} { return clicks })
+}
+
+func TestMenuFilter(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	clicks := 0
+	var m *Menu
+	app.PostWait(func() {
+		m = NewMenu(app.Desktop().Root(), wm.Position{X: 5, Y: 5}, []MenuItem{{Label: "foo"}})
+		app.OnMouse(func(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+			clicks++
+			return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+		}, nil)
+		m.Close()
+	})
+	click(s, 0, 0)
+	waitFor(t, app, "1", func() interface{} { return clicks })
+}
+
+func TestScrollbarDrag(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var a, b *wm.Window
+	var v *Listbox
+	app.PostWait(func() {
+		r := app.Desktop().Root()
+		a = r.NewChild(wm.Rectangle{Size: wm.Size{Width: 20, Height: 10}})
+		v = NewListbox(a, make([]string, 100))
+		b = r.NewChild(wm.Rectangle{Position: wm.Position{X: 5, Y: 5}, Size: wm.Size{Width: 20, Height: 10}})
+		b.SetFocus(true)
+	})
+	state := func() interface{} {
+		return fmt.Sprint(a.Focus(), a.Parent().Child(a.Parent().Children()-1) == a, v.vs.draggingHandle, v.Origin().Y > 0)
+	}
+	if g, e := query(app, state), "false false false false"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	press(t, app, s, 18, 2, "true true true false", state)
+	app.PostWait(func() { b.BringToFront(); b.SetFocus(true) })
+	s.InjectMouse(18, 5, tcell.Button1, 0)
+	s.InjectMouse(18, 5, tcell.ButtonNone, 0)
+	waitFor(t, app, "true true false true", state)
+}
+
+func TestStatusBarClose(t *testing.T) {
+	app, _ := newApp(t)
+	defer exit(t, app)
+
+	g := query(app, func() interface{} {
+		p := app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 30, Height: 10}})
+		NewStatusBar(p).Close()
+		s := NewStatusBar(p)
+		p.SetSize(wm.Size{Width: 40, Height: 12})
+		return fmt.Sprint(s.Position().Y == p.ClientSize().Height-1, s.Size().Width == p.ClientSize().Width)
+	})
+	if e := "true true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestScrollbarJumpToClick(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var v *Listbox
+	app.PostWait(func() {
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 20, Height: 10}})
+		v = NewListbox(w, make([]string, 100))
+	})
+	origin := func() interface{} { return v.Origin().Y }
+
+	// The scrollbar track spans rows 2-7, the handle is at row 2.
+	click(s, 18, 5)
+	waitFor(t, app, "8", origin)
+
+	app.PostWait(func() {
+		v.SetJumpToClick(true)
+		v.Home()
+	})
+	click(s, 18, 7)
+	waitFor(t, app, "92", origin)
+
+	click(s, 18, 4)
+	waitFor(t, app, "37", origin)
+}
+
+func TestViewScrollbarAutoHide(t *testing.T) {
+	app, _ := newApp(t)
+	defer exit(t, app)
+
+	var v *Listbox
+	app.PostWait(func() {
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 20, Height: 10}})
+		v = NewListbox(w, make([]string, 100))
+		v.SetScrollbarAutoHide(20 * time.Millisecond)
+	})
+	shown := func() interface{} { return fmt.Sprint(v.vsShown, v.BorderRight()) }
+	if g, e := query(app, shown), "true 2"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	waitFor(t, app, "false 1", shown)
+	app.PostWait(func() { v.SetOrigin(wm.Position{Y: 10}) })
+	if g, e := query(app, shown), "true 2"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	waitFor(t, app, "false 1", shown)
+	app.PostWait(func() { v.SetScrollbarAutoHide(0) })
+	if g, e := query(app, shown), "true 2"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.PostWait(func() {
+		v.SetScrollbarAutoHide(time.Millisecond)
+		v.Close()
+	})
+	time.Sleep(10 * time.Millisecond)
+	if g, e := query(app, func() interface{} { return fmt.Sprint(v.autoHideTimer == nil, v.hidden) }), "true false"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestViewFollow(t *testing.T) {
+	app, _ := newApp(t)
+	defer exit(t, app)
+
+	var v *Listbox
+	app.PostWait(func() {
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 20, Height: 10}})
+		v = NewListbox(w, make([]string, 20))
+		v.SetFollow(true)
+	})
+	state := func() interface{} { return fmt.Sprint(v.Origin().Y, v.Following()) }
+	if g, e := query(app, state), "12 true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.PostWait(func() { v.SetItems(make([]string, 30)) })
+	if g, e := query(app, state), "22 true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.PostWait(func() {
+		v.PageUp()
+		v.SetItems(make([]string, 40))
+	})
+	if g, e := query(app, state), "14 false"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.PostWait(func() {
+		v.End()
+		v.SetItems(make([]string, 50))
+	})
+	if g, e := query(app, state), "42 true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestViewWheelStep(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var v *Listbox
+	app.PostWait(func() {
+		items := make([]string, 100)
+		for i := range items {
+			items[i] = strings.Repeat("x", 50)
+		}
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 20, Height: 10}})
+		v = NewListbox(w, items)
+		v.SetWheelStep(3)
+	})
+	origin := func() interface{} { return v.Origin() }
+	for _, c := range []struct {
+		button tcell.ButtonMask
+		mods   tcell.ModMask
+		e      string
+	}{
+		{tcell.WheelDown, 0, "{0 3}"},
+		{tcell.WheelDown, tcell.ModCtrl, "{3 3}"},
+		{tcell.WheelRight, 0, "{6 3}"},
+		{tcell.WheelUp, tcell.ModCtrl, "{3 3}"},
+		{tcell.WheelUp, 0, "{3 0}"},
+		{tcell.WheelUp, 0, "{3 0}"},
+		{tcell.WheelLeft, 0, "{0 0}"},
+	} {
+		s.InjectMouse(5, 5, c.button, c.mods)
+		waitFor(t, app, c.e, origin)
+	}
+
+	app.PostWait(func() { v.SetOrigin(wm.Position{X: 31, Y: 92}) })
+	s.InjectMouse(5, 5, tcell.WheelDown, 0)
+	waitFor(t, app, "{31 93}", origin)
+	s.InjectMouse(5, 5, tcell.WheelDown, tcell.ModCtrl)
+	waitFor(t, app, "{33 93}", origin)
+}
+
+func TestViewHorizontalPaging(t *testing.T) {
+	app, _ := newApp(t)
+	defer exit(t, app)
+
+	var v *Listbox
+	app.PostWait(func() {
+		items := make([]string, 100)
+		for i := range items {
+			items[i] = strings.Repeat("x", 50)
+		}
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 20, Height: 10}})
+		v = NewListbox(w, items)
+		v.SetOrigin(wm.Position{Y: 5})
+	})
+	// The client area is 17 columns wide, the maximum horizontal origin is
+	// 50-17 = 33.
+	for _, c := range []struct {
+		f func()
+		e string
+	}{
+		{func() { v.PageRight() }, "{17 5}"},
+		{func() { v.PageRight() }, "{33 5}"},
+		{func() { v.PageLeft() }, "{16 5}"},
+		{func() { v.PageLeft() }, "{0 5}"},
+		{func() { v.PageLeft() }, "{0 5}"},
+		{func() { v.LineEnd() }, "{33 5}"},
+		{func() { v.LineHome() }, "{0 5}"},
+	} {
+		if g := query(app, func() interface{} { c.f(); return v.Origin() }); g != c.e {
+			t.Fatalf("got %q, expected %q", g, c.e)
+		}
+	}
+}
+
+func TestViewScrollbarStyle(t *testing.T) {
+	app, _ := newApp(t)
+	defer exit(t, app)
+
+	custom := wm.Style{Background: tcell.ColorNavy, Foreground: tcell.ColorWhite}
+	g := query(app, func() interface{} {
+		w := app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 20, Height: 10}})
+		v := NewView(w, NewLineMeter(nil, 8))
+		w2 := app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 20, Height: 10}})
+		ws := w2.Style()
+		ws.Scrollbar = custom
+		w2.SetStyle(ws)
+		v2 := NewView(w2, NewLineMeter(nil, 8))
+		return fmt.Sprint(
+			v.vs.Style() == wm.Style{Background: tcell.ColorSilver, Foreground: tcell.ColorBlack},
+			v.hs.Style() == v.vs.Style(),
+			v2.vs.Style() == custom,
+			v2.hs.Style() == custom,
+		)
+	})
+	if e := "true true true true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestTileResize(t *testing.T) {
+	app, _ := newApp(t)
+	defer exit(t, app)
+
+	var p *wm.Window
+	var c []*wm.Window
+	layout := func() interface{} {
+		var a []string
+		for _, v := range c {
+			a = append(a, fmt.Sprint(v.Position(), v.Size()))
+		}
+		return strings.Join(a, " ")
+	}
+	g := query(app, func() interface{} {
+		p = app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 12, Height: 7}})
+		for i := 0; i < 3; i++ {
+			c = append(c, p.NewChild(wm.Rectangle{}))
+		}
+		TileHorizontal(p, c)
+		return layout()
+	})
+	if e := "{0 0} {4 5} {4 0} {3 5} {7 0} {3 5}"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	g = query(app, func() interface{} {
+		p.SetSize(wm.Size{Width: 17, Height: 9})
+		return layout()
+	})
+	if e := "{0 0} {5 7} {5 0} {5 7} {10 0} {5 7}"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	g = query(app, func() interface{} {
+		c[1].SetVisible(false)
+		p.SetSize(wm.Size{Width: 12, Height: 9})
+		return layout()
+	})
+	if e := "{0 0} {5 7} {5 0} {5 7} {5 0} {5 7}"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestSplitterDrag(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var sp *Splitter
+	var ratios []string
+	app.PostWait(func() {
+		sp = NewSplitter(app.Desktop().Root(), wm.Rectangle{Size: wm.Size{Width: 21, Height: 10}}, true)
+		sp.SetMinPaneSize(3)
+		sp.OnResize(func(r float64) { ratios = append(ratios, fmt.Sprint(r)) })
+	})
+	state := func() interface{} {
+		return fmt.Sprint(sp.dragging, sp.First().Size().Width, sp.Divider().Position().X, sp.Second().Size().Width)
+	}
+	if g, e := query(app, state), "false 10 10 10"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	press(t, app, s, 10, 3, "true 10 10 10", state)
+	s.InjectMouse(14, 3, tcell.Button1, 0)
+	waitFor(t, app, "true 14 14 6", state)
+	s.InjectMouse(14, 3, tcell.ButtonNone, 0)
+	waitFor(t, app, "false 14 14 6", state)
+
+	// The second pane keeps its minimum size.
+	press(t, app, s, 14, 3, "true 14 14 6", state)
+	s.InjectMouse(30, 3, tcell.Button1, 0)
+	s.InjectMouse(30, 3, tcell.ButtonNone, 0)
+	waitFor(t, app, "false 17 17 3", state)
+	if g, e := query(app, func() interface{} { return strings.Join(ratios, " ") }), "0.7 0.85"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	// Resizing the splitter keeps the ratio.
+	if g, e := query(app, func() interface{} {
+		sp.SetSize(wm.Size{Width: 41, Height: 10})
+		return state()
+	}), "false 34 34 6"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestTabViewClick(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var tv *TabView
+	var w []*wm.Window
+	var selects []string
+	app.PostWait(func() {
+		tv = NewTabView(app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 30, Height: 10}}))
+		for _, v := range []string{"foo", "bar", "baz"} {
+			c := tv.NewChild(wm.Rectangle{})
+			w = append(w, c)
+			tv.AddTab(v, c)
+		}
+		tv.OnSelect(func(i int) { selects = append(selects, fmt.Sprint(i)) })
+	})
+	state := func() interface{} {
+		var a []string
+		for i := 0; i < tv.Tabs(); i++ {
+			title, w := tv.Tab(i)
+			a = append(a, fmt.Sprintf("%s %v %v", title, w.Visible(), w.Focus()))
+		}
+		return fmt.Sprintf("%d %d: %s", tv.Selected(), tv.Tabs(), strings.Join(a, " "))
+	}
+	if g, e := query(app, state), "0 3: foo true true bar false false baz false false"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	// The labels are " foo " at x 1, " bar " at x 7 and " baz " at x 13.
+	click(s, 8, 0)
+	waitFor(t, app, "1 3: foo false false bar true true baz false false", state)
+	click(s, 6, 0) // Between the labels.
+	click(s, 14, 0)
+	waitFor(t, app, "2 3: foo false false bar false false baz true true", state)
+
+	app.PostWait(func() { w[2].Close() })
+	if g, e := query(app, state), "1 2: foo false false bar true true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	app.PostWait(func() { w[0].Close() })
+	if g, e := query(app, state), "0 1: bar true true"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	if g, e := query(app, func() interface{} { return strings.Join(selects, " ") }), "1 2 1"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
+
+func TestTooltipShowHide(t *testing.T) {
+	app, s := newApp(t)
+	defer exit(t, app)
+
+	var tt *Tooltip
+	app.PostWait(func() {
+		tt = NewTooltip(app.Desktop().Root().NewChild(wm.Rectangle{Size: wm.Size{Width: 20, Height: 10}}), "tip")
+		tt.SetDelay(10 * time.Millisecond)
+	})
+	popup := func() interface{} {
+		if p := tt.popup; p != nil {
+			return fmt.Sprint(p.Position(), p.Size())
+		}
+
+		return "none"
+	}
+	s.InjectMouse(5, 5, tcell.ButtonNone, 0)
+	waitFor(t, app, "{6 6} {5 1}", popup)
+	if g, e := query(app, func() interface{} { return screenText(s, wm.Rectangle{Position: wm.Position{X: 6, Y: 6}, Size: wm.Size{Width: 5, Height: 1}}) }), " tip "; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+
+	// Leaving the window hides the tooltip.
+	s.InjectMouse(40, 15, tcell.ButtonNone, 0)
+	waitFor(t, app, "none", popup)
+
+	// So does a click.
+	s.InjectMouse(8, 3, tcell.ButtonNone, 0)
+	waitFor(t, app, "{9 4} {5 1}", popup)
+	click(s, 8, 3)
+	waitFor(t, app, "none", popup)
+
+	// Closing the window cancels a pending tooltip.
+	s.InjectMouse(9, 3, tcell.ButtonNone, 0)
+	app.PostWait(func() { tt.Close() })
+	time.Sleep(30 * time.Millisecond)
+	if g, e := query(app, func() interface{} { return fmt.Sprintf("%v %v", popup(), app.Desktop().Root().Children()) }), "none 0"; g != e {
+		t.Fatalf("got %q, expected %q", g, e)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// buttonPressDuration is how long a pressed button is shown depressed.
+const buttonPressDuration = 150 * time.Millisecond
+
+// buttonText returns the text shown by a button.
+func buttonText(label string, brackets bool) string {
+	if brackets {
+		return "[ " + label + " ]"
+	}
+
+	return label
+}
+
+// Button is a window showing a centered label, which invokes a function when
+// clicked using the primary mouse button or when <Enter> or <Space> is
+// pressed while the button is focused.
+//
+// Button methods must be called only directly from an event handler goroutine
+// or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type Button struct {
+	*wm.Window                            // Underlying window.
+	brackets   bool                       //
+	closed     bool                       //
+	isDefault  bool                       //
+	label      string                     //
+	onPress    func()                     //
+	onSetLabel *wm.OnSetStringHandlerList //
+	pressed    bool                       //
+}
+
+// NewButton creates a borderless child window of parent, positioned and sized
+// by area, showing label, and returns the resulting Button.
+//
+// NewButton must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewButton(parent *wm.Window, area wm.Rectangle, label string) *Button {
+	parent.BeginUpdate()
+	defer parent.EndUpdate()
+
+	w := parent.NewChild(area)
+	w.SetBorders(0, 0, 0, 0)
+	b := &Button{Window: w}
+	b.OnSetLabel(b.onSetLabelHandler, nil)
+	w.OnClick(b.onClickHandler, nil)
+	w.OnClickBorder(b.onClickHandler, nil)
+	w.OnClose(b.onCloseHandler, nil)
+	w.OnKey(b.onKeyHandler, nil)
+	w.OnPaintClientArea(b.onPaintClientAreaHandler, nil)
+	b.SetLabel(label)
+	return b
+}
+
+func (b *Button) onClickHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
+		return true
+	}
+
+	if button != tcell.Button1 {
+		return false
+	}
+
+	b.Press()
+	return true
+}
+
+func (b *Button) onCloseHandler(w *wm.Window, prev wm.OnCloseHandler) {
+	if prev != nil {
+		prev(w, nil)
+	}
+	b.closed = true
+	b.onSetLabel.Clear()
+}
+
+func (b *Button) onKeyHandler(w *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+	if prev != nil && prev(w, nil, key, mod, r) {
+		return true
+	}
+
+	if key == tcell.KeyEnter || key == tcell.KeyRune && r == ' ' {
+		b.Press()
+		return true
+	}
+
+	return false
+}
+
+func (b *Button) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	style := w.ClientAreaStyle()
+	if b.isDefault {
+		style.Attr ^= tcell.AttrBold
+	}
+	if b.pressed {
+		style.Attr ^= tcell.AttrReverse
+	}
+	sz := w.ClientSize()
+	if b.pressed {
+		blank := strings.Repeat(" ", sz.Width)
+		for y := 0; y < sz.Height; y++ {
+			w.Print(0, y, style, blank)
+		}
+	}
+	s := buttonText(b.label, b.brackets)
+	w.Print(alignX(AlignCenter, sz.Width, runewidth.StringWidth(s)), (sz.Height-1)/2, style, s)
+}
+
+func (b *Button) onSetLabelHandler(w *wm.Window, prev wm.OnSetStringHandler, dst *string, src string) {
+	if prev != nil {
+		panic("internal error")
+	}
+
+	*dst = src
+	b.invalidate()
+}
+
+func (b *Button) invalidate() {
+	b.InvalidateClientArea(wm.Rectangle{Position: b.Origin(), Size: b.ClientSize()})
+}
+
+func (b *Button) setPressed(v bool) {
+	if b.closed || b.pressed == v {
+		return
+	}
+
+	b.pressed = v
+	b.invalidate()
+}
+
+// ----------------------------------------------------------------------------
+
+// Brackets returns whether the label is shown enclosed in brackets.
+func (b *Button) Brackets() bool { return b.brackets }
+
+// Default returns whether b is the default button.
+func (b *Button) Default() bool { return b.isDefault }
+
+// Label returns the button label.
+func (b *Button) Label() string { return b.label }
+
+// OnPress sets the function invoked when the button is pressed, replacing
+// any previously set function. Passing nil removes it.
+func (b *Button) OnPress(f func()) { b.onPress = f }
+
+// OnSetLabel sets a handler invoked on SetLabel. When the event handler is
+// removed, finalize is called, if not nil.
+func (b *Button) OnSetLabel(h wm.OnSetStringHandler, finalize func()) {
+	wm.AddOnSetStringHandler(&b.onSetLabel, h, finalize)
+}
+
+// Press shows the button depressed for a short time and invokes the function
+// set by OnPress, if any.
+func (b *Button) Press() {
+	b.setPressed(true)
+	wm.App.AfterFunc(buttonPressDuration, func() { b.setPressed(false) })
+	if f := b.onPress; f != nil {
+		f()
+	}
+}
+
+// RemoveOnSetLabel undoes the most recent OnSetLabel call. The function will
+// panic if there is no handler set.
+func (b *Button) RemoveOnSetLabel() { wm.RemoveOnSetStringHandler(&b.onSetLabel) }
+
+// SetBrackets sets whether the label is shown enclosed in brackets, like
+// "[ OK ]".
+func (b *Button) SetBrackets(v bool) {
+	if b.brackets == v {
+		return
+	}
+
+	b.brackets = v
+	b.invalidate()
+}
+
+// SetDefault sets whether b is the default button. The label of the default
+// button is shown in bold.
+func (b *Button) SetDefault(v bool) {
+	if b.isDefault == v {
+		return
+	}
+
+	b.isDefault = v
+	b.invalidate()
+}
+
+// SetLabel sets the button label.
+func (b *Button) SetLabel(s string) { b.onSetLabel.Handle(b.Window, &b.label, s) }
@@ -0,0 +1,244 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// runesColumn returns the column of the rune at index i of s.
+func runesColumn(s []rune, i int) (col int) {
+	for _, r := range s[:i] {
+		col += runewidth.RuneWidth(r)
+	}
+	return col
+}
+
+// runesIndex returns the index of the rune of s displayed at column col or
+// len(s) if col is past the end of s.
+func runesIndex(s []rune, col int) int {
+	x := 0
+	for i, r := range s {
+		if x += runewidth.RuneWidth(r); x > col {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// Entry is a single line text editor.
+//
+// Entry methods must be called only directly from an event handler goroutine
+// or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type Entry struct {
+	*wm.Window                            // Underlying window.
+	caret      int                        // Index into runes.
+	onChange   func(string)               //
+	onSetText  *wm.OnSetStringHandlerList //
+	runes      []rune                     //
+	text       string                     //
+}
+
+// NewEntry creates a borderless child window of parent, positioned and sized
+// by area, editing text, and returns the resulting Entry. The caret is placed
+// at the end of text.
+//
+// NewEntry must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewEntry(parent *wm.Window, area wm.Rectangle, text string) *Entry {
+	parent.BeginUpdate()
+	defer parent.EndUpdate()
+
+	w := parent.NewChild(area)
+	w.SetBorders(0, 0, 0, 0)
+	e := &Entry{Window: w}
+	e.OnSetText(e.onSetTextHandler, nil)
+	w.OnClick(e.onClickHandler, nil)
+	w.OnClose(e.onCloseHandler, nil)
+	w.OnKey(e.onKeyHandler, nil)
+	w.OnPaintClientArea(e.onPaintClientAreaHandler, nil)
+	w.OnSetClientSize(e.onSetClientSizeHandler, nil)
+	w.OnSetFocus(e.onSetFocusHandler, nil)
+	e.SetText(text)
+	return e
+}
+
+func (e *Entry) onClickHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
+		return true
+	}
+
+	if button != tcell.Button1 {
+		return false
+	}
+
+	e.SetCaret(runesIndex(e.runes, winPos.X))
+	return true
+}
+
+func (e *Entry) onCloseHandler(w *wm.Window, prev wm.OnCloseHandler) {
+	if prev != nil {
+		prev(w, nil)
+	}
+	e.onSetText.Clear()
+}
+
+func (e *Entry) onKeyHandler(w *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+	if prev != nil && prev(w, nil, key, mod, r) {
+		return true
+	}
+
+	switch key {
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if e.caret > 0 {
+			e.edit(e.caret-1, e.caret, nil)
+		}
+	case tcell.KeyDelete:
+		if e.caret < len(e.runes) {
+			e.edit(e.caret, e.caret+1, nil)
+		}
+	case tcell.KeyEnd:
+		e.SetCaret(len(e.runes))
+	case tcell.KeyHome:
+		e.SetCaret(0)
+	case tcell.KeyLeft:
+		e.SetCaret(e.caret - 1)
+	case tcell.KeyRight:
+		e.SetCaret(e.caret + 1)
+	case tcell.KeyRune:
+		if r < ' ' || mod&(tcell.ModAlt|tcell.ModCtrl) != 0 {
+			return false
+		}
+
+		e.edit(e.caret, e.caret, []rune{r})
+	default:
+		return false
+	}
+	return true
+}
+
+func (e *Entry) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	style := w.ClientAreaStyle()
+	w.Print(0, 0, style, e.text)
+	if !w.Focus() {
+		return
+	}
+
+	r := ' '
+	if e.caret < len(e.runes) {
+		r = e.runes[e.caret]
+	}
+	style.Attr ^= tcell.AttrReverse
+	w.SetCell(runesColumn(e.runes, e.caret), 0, r, nil, style.TCellStyle())
+}
+
+func (e *Entry) onSetClientSizeHandler(w *wm.Window, prev wm.OnSetSizeHandler, dst *wm.Size, src wm.Size) {
+	if prev != nil {
+		prev(w, nil, dst, src)
+	}
+	e.scroll()
+}
+
+func (e *Entry) onSetFocusHandler(w *wm.Window, prev wm.OnSetBoolHandler, dst *bool, src bool) {
+	if prev != nil {
+		prev(w, nil, dst, src)
+	}
+	e.invalidate()
+}
+
+func (e *Entry) onSetTextHandler(w *wm.Window, prev wm.OnSetStringHandler, dst *string, src string) {
+	if prev != nil {
+		panic("internal error")
+	}
+
+	*dst = src
+	e.runes = []rune(src)
+	e.caret = len(e.runes)
+	e.scroll()
+	e.invalidate()
+	if f := e.onChange; f != nil {
+		f(src)
+	}
+}
+
+// edit replaces runes in [from, to) by s and places the caret after s.
+func (e *Entry) edit(from, to int, s []rune) {
+	a := append(append(append([]rune(nil), e.runes[:from]...), s...), e.runes[to:]...)
+	e.BeginUpdate()
+	e.SetText(string(a))
+	e.SetCaret(from + len(s))
+	e.EndUpdate()
+}
+
+func (e *Entry) invalidate() {
+	e.InvalidateClientArea(wm.Rectangle{Position: e.Origin(), Size: e.ClientSize()})
+}
+
+// scroll makes the caret visible.
+func (e *Entry) scroll() {
+	width := e.ClientSize().Width
+	if width <= 0 {
+		return
+	}
+
+	col := runesColumn(e.runes, e.caret)
+	o := e.Origin()
+	switch {
+	case col < o.X:
+		o.X = col
+	case col >= o.X+width:
+		o.X = col - width + 1
+	}
+	e.SetOrigin(o)
+}
+
+// ----------------------------------------------------------------------------
+
+// Caret returns the index of the rune at which the caret is positioned.
+func (e *Entry) Caret() int { return e.caret }
+
+// OnChange sets the function invoked when the text changes, replacing any
+// previously set function. Passing nil removes it.
+func (e *Entry) OnChange(f func(string)) { e.onChange = f }
+
+// OnSetText sets a handler invoked on SetText. When the event handler is
+// removed, finalize is called, if not nil.
+func (e *Entry) OnSetText(h wm.OnSetStringHandler, finalize func()) {
+	wm.AddOnSetStringHandler(&e.onSetText, h, finalize)
+}
+
+// RemoveOnSetText undoes the most recent OnSetText call. The function will
+// panic if there is no handler set.
+func (e *Entry) RemoveOnSetText() { wm.RemoveOnSetStringHandler(&e.onSetText) }
+
+// SetCaret positions the caret before the rune at index i. Values of i
+// outside of the text are clamped.
+func (e *Entry) SetCaret(i int) {
+	i = mathutil.Min(mathutil.Max(i, 0), len(e.runes))
+	if e.caret == i {
+		return
+	}
+
+	e.BeginUpdate()
+	e.caret = i
+	e.scroll()
+	e.invalidate()
+	e.EndUpdate()
+}
+
+// SetText sets the edited text and places the caret at its end.
+func (e *Entry) SetText(s string) { e.onSetText.Handle(e.Window, &e.text, s) }
+
+// Text returns the edited text.
+func (e *Entry) Text() string { return e.text }
@@ -0,0 +1,131 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"strings"
+
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/mattn/go-runewidth"
+)
+
+// Alignment determines the horizontal placement of text.
+type Alignment int
+
+// Values of Alignment.
+const (
+	AlignLeft Alignment = iota
+	AlignCenter
+	AlignRight
+)
+
+// alignX returns the x coordinate of text of width n aligned within width.
+func alignX(a Alignment, width, n int) int {
+	switch a {
+	case AlignCenter:
+		return mathutil.Max(0, (width-n)/2)
+	case AlignRight:
+		return mathutil.Max(0, width-n)
+	default:
+		return 0
+	}
+}
+
+// Label displays static, possibly multi line, text. The text is painted using
+// the client area style of the underlying window.
+//
+// Label methods must be called only directly from an event handler goroutine
+// or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type Label struct {
+	*wm.Window                            // Underlying window.
+	alignment  Alignment                  //
+	lines      []string                   //
+	onSetText  *wm.OnSetStringHandlerList //
+	text       string                     //
+}
+
+// NewLabel creates a borderless child window of parent, positioned and sized
+// by area, showing text, and returns the resulting Label. The client area
+// style of the label is inherited from parent.
+//
+// NewLabel must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewLabel(parent *wm.Window, area wm.Rectangle, text string) *Label {
+	parent.BeginUpdate()
+	defer parent.EndUpdate()
+
+	w := parent.NewChild(area)
+	w.SetBorders(0, 0, 0, 0)
+	w.SetClientAreaStyle(parent.ClientAreaStyle())
+	l := &Label{Window: w}
+	l.OnSetText(l.onSetTextHandler, nil)
+	w.OnClose(l.onCloseHandler, nil)
+	w.OnPaintClientArea(l.onPaintClientAreaHandler, nil)
+	l.SetText(text)
+	return l
+}
+
+func (l *Label) onCloseHandler(w *wm.Window, prev wm.OnCloseHandler) {
+	if prev != nil {
+		prev(w, nil)
+	}
+	l.onSetText.Clear()
+}
+
+func (l *Label) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	width := w.ClientSize().Width
+	style := w.ClientAreaStyle()
+	for y, s := range l.lines {
+		w.Print(alignX(l.alignment, width, runewidth.StringWidth(s)), y, style, s)
+	}
+}
+
+func (l *Label) onSetTextHandler(w *wm.Window, prev wm.OnSetStringHandler, dst *string, src string) {
+	if prev != nil {
+		panic("internal error")
+	}
+
+	*dst = src
+	l.lines = strings.Split(src, "\n")
+	w.InvalidateClientArea(wm.Rectangle{Position: w.Origin(), Size: w.ClientSize()})
+}
+
+// ----------------------------------------------------------------------------
+
+// Alignment returns the alignment of the label text.
+func (l *Label) Alignment() Alignment { return l.alignment }
+
+// OnSetText sets a handler invoked on SetText. When the event handler is
+// removed, finalize is called, if not nil.
+func (l *Label) OnSetText(h wm.OnSetStringHandler, finalize func()) {
+	wm.AddOnSetStringHandler(&l.onSetText, h, finalize)
+}
+
+// RemoveOnSetText undoes the most recent OnSetText call. The function will
+// panic if there is no handler set.
+func (l *Label) RemoveOnSetText() { wm.RemoveOnSetStringHandler(&l.onSetText) }
+
+// SetAlignment sets the alignment of the label text.
+func (l *Label) SetAlignment(a Alignment) {
+	if l.alignment == a {
+		return
+	}
+
+	l.alignment = a
+	l.InvalidateClientArea(wm.Rectangle{Position: l.Origin(), Size: l.ClientSize()})
+}
+
+// SetText sets the label text. Lines are separated by '\n'.
+func (l *Label) SetText(s string) { l.onSetText.Handle(l.Window, &l.text, s) }
+
+// Text returns the label text.
+func (l *Label) Text() string { return l.text }
@@ -0,0 +1,196 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"strings"
+
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// Listbox shows a list of items, one per line, and allows to select one of
+// them. It scrolls its content using the embedded View.
+//
+// Listbox methods must be called only directly from an event handler
+// goroutine or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type Listbox struct {
+	*View              // Underlying view.
+	items    []string  //
+	maxWidth int       // Width of the widest item.
+	onSelect func(int) //
+	selected int       // -1 if there are no items.
+}
+
+// NewListbox configures w to show items and returns the resulting Listbox.
+// The first item, if any, is selected.
+//
+// NewListbox must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewListbox(w *wm.Window, items []string) *Listbox {
+	l := &Listbox{selected: -1}
+	l.View = NewView(w, l)
+	w.OnClick(l.onClickHandler, nil)
+	w.OnKey(l.onKeyHandler, nil)
+	w.OnPaintClientArea(l.onPaintClientAreaHandler, nil)
+	l.SetItems(items)
+	return l
+}
+
+// Metrics implements Meter.
+func (l *Listbox) Metrics(viewport wm.Rectangle) wm.Size {
+	return wm.Size{Width: l.maxWidth, Height: len(l.items)}
+}
+
+func (l *Listbox) onClickHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
+		return true
+	}
+
+	if button != tcell.Button1 {
+		return false
+	}
+
+	if i := winPos.Y; i >= 0 && i < len(l.items) {
+		l.Select(i)
+	}
+	return true
+}
+
+func (l *Listbox) onKeyHandler(w *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+	if prev != nil && prev(w, nil, key, mod, r) {
+		return true
+	}
+
+	page := mathutil.Max(1, l.ClientSize().Height)
+	switch key {
+	case tcell.KeyDown:
+		l.Select(l.selected + 1)
+	case tcell.KeyEnd:
+		l.Select(len(l.items) - 1)
+	case tcell.KeyHome:
+		l.Select(0)
+	case tcell.KeyPgDn:
+		l.Select(l.selected + page)
+	case tcell.KeyPgUp:
+		l.Select(l.selected - page)
+	case tcell.KeyUp:
+		l.Select(l.selected - 1)
+	default:
+		return false
+	}
+	return true
+}
+
+func (l *Listbox) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	cpY := w.ClientPosition().Y
+	width := mathutil.Max(l.maxWidth, w.Origin().X+w.ClientSize().Width)
+	for i := 0; i < ctx.Height; i++ {
+		y := ctx.Y - cpY + i
+		if y >= len(l.items) {
+			break
+		}
+
+		style := w.ClientAreaStyle()
+		if y == l.selected {
+			style.Attr ^= tcell.AttrReverse
+			w.Print(0, y, style, strings.Repeat(" ", width))
+		}
+		w.Print(0, y, style, l.items[y])
+	}
+}
+
+func (l *Listbox) invalidateItem(i int) {
+	if i < 0 {
+		return
+	}
+
+	l.InvalidateClientArea(wm.Rectangle{Position: wm.Position{X: l.Origin().X, Y: i}, Size: wm.Size{Width: l.ClientSize().Width, Height: 1}})
+}
+
+// scroll makes the selected item visible.
+func (l *Listbox) scroll() {
+	h := l.ClientSize().Height
+	if h <= 0 || l.selected < 0 {
+		return
+	}
+
+	o := l.Origin()
+	switch y := l.selected; {
+	case y < o.Y:
+		o.Y = y
+	case y >= o.Y+h:
+		o.Y = y - h + 1
+	}
+	l.SetOrigin(o)
+}
+
+// ----------------------------------------------------------------------------
+
+// Items returns the items of the listbox.
+func (l *Listbox) Items() []string { return l.items }
+
+// OnSelect sets the function invoked when the selected item changes,
+// replacing any previously set function. Passing nil removes it.
+func (l *Listbox) OnSelect(f func(int)) { l.onSelect = f }
+
+// Select selects the item at index i and scrolls the view to make it visible.
+// Values of i outside of the items are clamped.
+func (l *Listbox) Select(i int) {
+	if len(l.items) == 0 {
+		return
+	}
+
+	i = mathutil.Min(mathutil.Max(i, 0), len(l.items)-1)
+	if l.selected == i {
+		return
+	}
+
+	l.BeginUpdate()
+	l.invalidateItem(l.selected)
+	l.selected = i
+	l.invalidateItem(i)
+	l.scroll()
+	l.EndUpdate()
+	if f := l.onSelect; f != nil {
+		f(i)
+	}
+}
+
+// Selected returns the index of the selected item or -1 if there are no
+// items.
+func (l *Listbox) Selected() int { return l.selected }
+
+// SetItems sets the items of the listbox. The selected index is kept, if
+// possible.
+func (l *Listbox) SetItems(items []string) {
+	l.BeginUpdate()
+	l.items = items
+	l.maxWidth = 0
+	for _, v := range items {
+		l.maxWidth = mathutil.Max(l.maxWidth, runewidth.StringWidth(v))
+	}
+	l.updateScrollBars()
+	l.InvalidateClientArea(wm.Rectangle{Position: l.Origin(), Size: l.ClientSize()})
+	sel := l.selected
+	switch n := len(items); {
+	case n == 0:
+		l.selected = -1
+	case sel >= n:
+		l.selected = -1
+		l.Select(n - 1)
+	case sel < 0:
+		l.Select(0)
+	}
+	l.EndUpdate()
+}
@@ -0,0 +1,421 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"strings"
+
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// screenArea returns the area of w in screen coordinates.
+func screenArea(w *wm.Window) wm.Rectangle {
+	pos := w.Position()
+	for p := w.Parent(); p != nil; p = p.Parent() {
+		pos = pos.Add(p.ClientPosition()).Sub(p.Origin()).Add(p.Position())
+	}
+	return wm.Rectangle{Position: pos, Size: w.Size()}
+}
+
+// menuWidth returns the client area width of a menu showing items.
+func menuWidth(items []MenuItem) int {
+	var label, accel int
+	for _, v := range items {
+		label = mathutil.Max(label, runewidth.StringWidth(v.Label))
+		accel = mathutil.Max(accel, runewidth.StringWidth(v.Accelerator))
+	}
+	if accel != 0 {
+		accel += 2
+	}
+	return label + accel + 2
+}
+
+// MenuItem describes an item of a Menu.
+type MenuItem struct {
+	Accelerator string // Shown right aligned, eg. "Ctrl+Q". Informational only.
+	Action      func() // Invoked when the item is selected, if not nil.
+	Label       string //
+}
+
+// Menu is a popup window showing a list of selectable items. The menu closes
+// when an item is selected, when <Esc> is pressed or when a mouse button is
+// clicked outside of the menu.
+//
+// Menu methods must be called only directly from an event handler goroutine
+// or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type Menu struct {
+	*wm.Window              // Underlying window.
+	bar          *MenuBar   // Menu bar which opened the menu, if any.
+	closed       bool       //
+	focus0       *wm.Window // Window focused before the menu was opened.
+	items        []MenuItem //
+	removeFilter func()     // Removes onMouseFilter.
+	selected     int        //
+}
+
+// NewMenu creates a child window of parent at pos showing items, focuses it
+// and returns the resulting Menu.
+//
+// NewMenu must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewMenu(parent *wm.Window, pos wm.Position, items []MenuItem) *Menu {
+	return newMenu(parent, pos, items, nil)
+}
+
+func newMenu(parent *wm.Window, pos wm.Position, items []MenuItem, bar *MenuBar) *Menu {
+	parent.BeginUpdate()
+	defer parent.EndUpdate()
+
+	w := parent.NewChild(wm.Rectangle{Position: pos})
+	w.SetClientSize(wm.Size{Width: menuWidth(items), Height: len(items)})
+	m := &Menu{
+		Window: w,
+		bar:    bar,
+		focus0: w.Desktop().FocusedWindow(),
+		items:  items,
+	}
+	w.OnClick(m.onClickHandler, nil)
+	w.OnClose(m.onCloseHandler, nil)
+	w.OnKey(m.onKeyHandler, nil)
+	w.OnPaintClientArea(m.onPaintClientAreaHandler, nil)
+	m.removeFilter = wm.App.OnMouseCancelable(m.onMouseFilter, nil)
+	w.SetFocus(true)
+	return m
+}
+
+func (m *Menu) onClickHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
+		return true
+	}
+
+	if button != tcell.Button1 {
+		return false
+	}
+
+	if i := winPos.Y; i >= 0 && i < len(m.items) {
+		m.Select(i)
+	}
+	return true
+}
+
+func (m *Menu) onCloseHandler(w *wm.Window, prev wm.OnCloseHandler) {
+	if prev != nil {
+		prev(w, nil)
+	}
+	m.closed = true
+	m.removeFilter()
+	if b := m.bar; b != nil && b.menu == m {
+		b.menu = nil
+		b.invalidate()
+	}
+	if f := m.focus0; f != nil && w.Focus() {
+		f.SetFocus(true)
+	}
+}
+
+func (m *Menu) onKeyHandler(w *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+	if prev != nil && prev(w, nil, key, mod, r) {
+		return true
+	}
+
+	n := len(m.items)
+	switch key {
+	case tcell.KeyDown:
+		if n != 0 {
+			m.setSelected((m.selected + 1) % n)
+		}
+	case tcell.KeyEnter:
+		if n != 0 {
+			m.Select(m.selected)
+		}
+	case tcell.KeyEscape:
+		m.Close()
+	case tcell.KeyLeft, tcell.KeyRight:
+		if m.bar == nil {
+			return false
+		}
+
+		delta := 1
+		if key == tcell.KeyLeft {
+			delta = -1
+		}
+		m.bar.Open(m.bar.opened + delta)
+	case tcell.KeyUp:
+		if n != 0 {
+			m.setSelected((m.selected + n - 1) % n)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// onMouseFilter closes the menu on a click outside of it. The click is
+// consumed unless it hits the menu bar which opened the menu.
+func (m *Menu) onMouseFilter(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if screenPos.In(screenArea(m.Window)) {
+		return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+	}
+
+	if m.bar != nil && screenPos.In(screenArea(m.bar.Window)) {
+		return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+	}
+
+	m.Close()
+	return true
+}
+
+func (m *Menu) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	width := w.ClientSize().Width
+	for i, v := range m.items {
+		style := w.ClientAreaStyle()
+		if i == m.selected {
+			style.Attr ^= tcell.AttrReverse
+		}
+		w.Print(0, i, style, strings.Repeat(" ", width))
+		w.Print(1, i, style, v.Label)
+		if s := v.Accelerator; s != "" {
+			w.Print(width-1-runewidth.StringWidth(s), i, style, s)
+		}
+	}
+}
+
+func (m *Menu) setSelected(i int) {
+	if m.selected == i {
+		return
+	}
+
+	m.BeginUpdate()
+	m.InvalidateClientArea(wm.Rectangle{Position: wm.Position{Y: m.selected}, Size: wm.Size{Width: m.ClientSize().Width, Height: 1}})
+	m.selected = i
+	m.InvalidateClientArea(wm.Rectangle{Position: wm.Position{Y: m.selected}, Size: wm.Size{Width: m.ClientSize().Width, Height: 1}})
+	m.EndUpdate()
+}
+
+// ----------------------------------------------------------------------------
+
+// Close closes the menu, if not already closed.
+func (m *Menu) Close() {
+	if !m.closed {
+		m.ForceClose()
+	}
+}
+
+// Select closes the menu and invokes the action of the item at index i.
+func (m *Menu) Select(i int) {
+	m.setSelected(i)
+	m.Close()
+	if f := m.items[i].Action; f != nil {
+		f()
+	}
+}
+
+// Selected returns the index of the highlighted item.
+func (m *Menu) Selected() int { return m.selected }
+
+// menuBarLayout returns the x coordinates of titles.
+func menuBarLayout(titles []string) []int {
+	r := make([]int, len(titles))
+	x := 1
+	for i, v := range titles {
+		r[i] = x
+		x += runewidth.StringWidth(v) + 2
+	}
+	return r
+}
+
+// MenuBar is a single line window pinned to the top of the client area of its
+// parent. It shows the titles of menus, which open when a title is clicked or
+// when <Enter> or <Down> is pressed while the menu bar is focused. <Left> and
+// <Right> move between the menus.
+//
+// MenuBar methods must be called only directly from an event handler
+// goroutine or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type MenuBar struct {
+	*wm.Window              // Underlying window.
+	closed     bool         //
+	items      [][]MenuItem //
+	menu       *Menu        // Currently open menu, if any.
+	opened     int          // Index of the menu opened last or highlighted.
+	titles     []string     //
+}
+
+// NewMenuBar creates a borderless, full width child window of parent, one
+// line high and pinned to the top edge of the parent's client area, and
+// returns the resulting MenuBar.
+//
+// NewMenuBar must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewMenuBar(parent *wm.Window) *MenuBar {
+	parent.BeginUpdate()
+	defer parent.EndUpdate()
+
+	w := parent.NewChild(wm.Rectangle{})
+	w.SetBorders(0, 0, 0, 0)
+	b := &MenuBar{Window: w}
+	w.OnClick(b.onClickHandler, nil)
+	w.OnClose(b.onCloseHandler, nil)
+	w.OnKey(b.onKeyHandler, nil)
+	w.OnPaintClientArea(b.onPaintClientAreaHandler, nil)
+	w.OnSetFocus(b.onSetFocusHandler, nil)
+	parent.OnSetClientSize(b.onSetParentClientSizeHandler, nil)
+	b.place()
+	return b
+}
+
+func (b *MenuBar) onClickHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
+		return true
+	}
+
+	if button != tcell.Button1 {
+		return false
+	}
+
+	x := winPos.X
+	for i, v := range menuBarLayout(b.titles) {
+		if x >= v-1 && x < v+runewidth.StringWidth(b.titles[i])+1 {
+			if b.menu != nil && b.opened == i {
+				b.menu.Close()
+				break
+			}
+
+			b.Open(i)
+			break
+		}
+	}
+	return true
+}
+
+func (b *MenuBar) onCloseHandler(w *wm.Window, prev wm.OnCloseHandler) {
+	if prev != nil {
+		prev(w, nil)
+	}
+	b.closed = true
+	if b.menu != nil {
+		b.menu.Close()
+	}
+}
+
+func (b *MenuBar) onKeyHandler(w *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+	if prev != nil && prev(w, nil, key, mod, r) {
+		return true
+	}
+
+	n := len(b.titles)
+	if n == 0 {
+		return false
+	}
+
+	switch key {
+	case tcell.KeyDown, tcell.KeyEnter:
+		b.Open(b.opened)
+	case tcell.KeyLeft:
+		b.setOpened((b.opened + n - 1) % n)
+	case tcell.KeyRight:
+		b.setOpened((b.opened + 1) % n)
+	default:
+		return false
+	}
+	return true
+}
+
+func (b *MenuBar) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	for i, x := range menuBarLayout(b.titles) {
+		style := w.ClientAreaStyle()
+		if i == b.opened && (b.menu != nil || w.Focus()) {
+			style.Attr ^= tcell.AttrReverse
+		}
+		w.Printf(x-1, 0, style, " %s ", b.titles[i])
+	}
+}
+
+func (b *MenuBar) onSetFocusHandler(w *wm.Window, prev wm.OnSetBoolHandler, dst *bool, src bool) {
+	if prev != nil {
+		prev(w, nil, dst, src)
+	}
+	b.invalidate()
+}
+
+func (b *MenuBar) onSetParentClientSizeHandler(w *wm.Window, prev wm.OnSetSizeHandler, dst *wm.Size, src wm.Size) {
+	if prev != nil {
+		prev(w, nil, dst, src)
+	}
+	if !b.closed {
+		b.place()
+	}
+}
+
+func (b *MenuBar) invalidate() {
+	b.InvalidateClientArea(wm.Rectangle{Position: b.Origin(), Size: b.ClientSize()})
+}
+
+// place pins b to the top of its parent.
+func (b *MenuBar) place() {
+	p := b.Parent()
+	b.SetRectangle(wm.Rectangle{Position: p.Origin(), Size: wm.Size{Width: p.ClientSize().Width, Height: 1}})
+}
+
+func (b *MenuBar) setOpened(i int) {
+	if b.opened == i {
+		return
+	}
+
+	b.opened = i
+	b.invalidate()
+}
+
+// ----------------------------------------------------------------------------
+
+// AddMenu appends a menu titled title showing items and returns its index.
+func (b *MenuBar) AddMenu(title string, items []MenuItem) int {
+	b.titles = append(b.titles, title)
+	b.items = append(b.items, items)
+	b.invalidate()
+	return len(b.titles) - 1
+}
+
+// Menu returns the currently open menu or nil if there is none.
+func (b *MenuBar) Menu() *Menu { return b.menu }
+
+// Open opens the menu at index i, closing the currently open menu, if any.
+// The index wraps around.
+func (b *MenuBar) Open(i int) {
+	n := len(b.titles)
+	if n == 0 {
+		return
+	}
+
+	i = (i%n + n) % n
+	b.BeginUpdate()
+	focus0 := b.Desktop().FocusedWindow()
+	if m := b.menu; m != nil {
+		focus0 = m.focus0
+		m.focus0 = nil
+		m.Close()
+	}
+	b.setOpened(i)
+	p := b.Parent()
+	pos := b.Position().Add(wm.Position{X: menuBarLayout(b.titles)[i] - 1, Y: 1})
+	b.menu = newMenu(p, pos, b.items[i], b)
+	b.menu.focus0 = focus0
+	b.invalidate()
+	b.EndUpdate()
+}
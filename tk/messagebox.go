@@ -0,0 +1,173 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"strings"
+
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// wrapText splits s into lines not wider than width, breaking lines at spaces
+// where possible. Existing line breaks are preserved.
+func wrapText(s string, width int) (r []string) {
+	width = mathutil.Max(width, 1)
+	for _, para := range strings.Split(s, "\n") {
+		var line []rune
+		lineWidth := 0
+		for _, word := range strings.Fields(para) {
+			if lineWidth != 0 {
+				switch {
+				case lineWidth+1+runewidth.StringWidth(word) <= width:
+					line = append(line, ' ')
+					lineWidth++
+				default:
+					r = append(r, string(line))
+					line, lineWidth = nil, 0
+				}
+			}
+			for _, c := range word {
+				w := runewidth.RuneWidth(c)
+				if lineWidth+w > width && lineWidth != 0 {
+					r = append(r, string(line))
+					line, lineWidth = nil, 0
+				}
+				line = append(line, c)
+				lineWidth += w
+			}
+		}
+		r = append(r, string(line))
+	}
+	return r
+}
+
+// MessageBox shows a modal dialog centered on the root window of d. The
+// dialog displays message and a row of buttons labeled by buttons. Pressing
+// a button closes the dialog and invokes onResult, if not nil, with the index
+// of the button. Pressing <Esc> or closing the dialog in any other way is
+// equivalent to pressing the last button. If there are no buttons the result
+// is -1.
+//
+// While the dialog is shown, key events are delivered only to the dialog and
+// mouse clicks and drags outside of the dialog are ignored.
+//
+// MessageBox must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func MessageBox(d *wm.Desktop, title, message string, buttons []string, onResult func(int)) {
+	const pad = 1 // Horizontal padding of the client area.
+
+	root := d.Root()
+	csz := root.ClientSize()
+	buttonsWidth := -2
+	for _, v := range buttons {
+		buttonsWidth += runewidth.StringWidth(buttonText(v, true)) + 2
+	}
+	lines := wrapText(message, mathutil.Max(buttonsWidth, csz.Width*2/3-2-2*pad))
+	msgWidth := 0
+	for _, v := range lines {
+		msgWidth = mathutil.Max(msgWidth, runewidth.StringWidth(v))
+	}
+	width := mathutil.Max(mathutil.Max(msgWidth, buttonsWidth), runewidth.StringWidth(title)+2) + 2*pad
+	height := len(lines) + 3 // Blank line above and below the message, buttons.
+
+	root.BeginUpdate()
+	defer root.EndUpdate()
+
+	w := root.NewChild(wm.Rectangle{})
+	w.SetTitle(title)
+	w.SetCloseButton(true)
+	w.SetClientSize(wm.Size{Width: width, Height: height})
+	sz := w.Size()
+	w.SetPosition(wm.Position{X: (csz.Width - sz.Width) / 2, Y: (csz.Height - sz.Height) / 2}.Add(root.Origin()))
+	NewLabel(w, wm.Rectangle{Position: wm.Position{X: pad, Y: 1}, Size: wm.Size{Width: width - 2*pad, Height: len(lines)}}, strings.Join(lines, "\n")).SetAlignment(AlignCenter)
+
+	result := len(buttons) - 1
+	var a []*Button
+	x := (width - buttonsWidth) / 2
+	for i, v := range buttons {
+		i := i
+		bw := runewidth.StringWidth(buttonText(v, true))
+		b := NewButton(w, wm.Rectangle{Position: wm.Position{X: x, Y: height - 1}, Size: wm.Size{Width: bw, Height: 1}}, v)
+		b.SetBrackets(true)
+		b.OnPress(func() {
+			result = i
+			w.ForceClose()
+		})
+		a = append(a, b)
+		x += bw + 2
+	}
+
+	inside := func(u *wm.Window) bool {
+		for ; u != nil; u = u.Parent() {
+			if u == w {
+				return true
+			}
+		}
+		return false
+	}
+	focus := func(delta int) {
+		if len(a) == 0 {
+			return
+		}
+
+		i := 0
+		for j, b := range a {
+			if b.Focus() {
+				i = j
+			}
+		}
+		a[(i+delta+len(a))%len(a)].SetFocus(true)
+	}
+
+	removeKey := wm.App.OnKeyCancelable(func(_ *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+		switch key {
+		case tcell.KeyEscape:
+			w.ForceClose()
+			return true
+		case tcell.KeyTab, tcell.KeyRight:
+			focus(1)
+			return true
+		case tcell.KeyBacktab, tcell.KeyLeft:
+			focus(-1)
+			return true
+		}
+
+		if !inside(d.FocusedWindow()) {
+			return true
+		}
+
+		return prev != nil && prev(nil, nil, key, mod, r)
+	}, nil)
+	removeMouse := wm.App.OnMouseCancelable(func(u *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+		area := wm.Rectangle{Position: w.Position().Add(root.ClientPosition()).Sub(root.Origin()), Size: w.Size()}
+		if !screenPos.In(area) {
+			return true
+		}
+
+		return prev != nil && prev(u, nil, button, screenPos, winPos, mods)
+	}, nil)
+	w.OnClose(func(u *wm.Window, prev wm.OnCloseHandler) {
+		if prev != nil {
+			prev(u, nil)
+		}
+		removeMouse()
+		removeKey()
+		if onResult != nil {
+			onResult(result)
+		}
+	}, nil)
+
+	switch {
+	case len(a) != 0:
+		a[0].SetDefault(true)
+		a[0].SetFocus(true)
+	default:
+		w.SetFocus(true)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+)
+
+// progressFill returns how many of n cells are filled for value.
+func progressFill(value float64, n int) int {
+	return mathutil.Min(n, int(value*float64(n)))
+}
+
+// progressLabel returns the percentage label for value.
+func progressLabel(value float64) string { return fmt.Sprintf("%d%%", int(value*100)) }
+
+// ProgressBar shows the progress of an operation. A progress bar having client
+// area width 1 and height greater than 1 is vertical and it fills from the
+// bottom up. Otherwise it's horizontal and it fills from the left.
+//
+// ProgressBar methods must be called only directly from an event handler
+// goroutine or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type ProgressBar struct {
+	*wm.Window          // Underlying window.
+	percentage bool     //
+	style      wm.Style //
+	value      float64  // In [0, 1].
+}
+
+// NewProgressBar creates a borderless child window of parent, positioned and
+// sized by area, and returns the resulting ProgressBar. The style of the
+// progress bar is initially the client area style of parent.
+//
+// NewProgressBar must be called only directly from an event handler goroutine
+// or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewProgressBar(parent *wm.Window, area wm.Rectangle) *ProgressBar {
+	parent.BeginUpdate()
+	defer parent.EndUpdate()
+
+	w := parent.NewChild(area)
+	w.SetBorders(0, 0, 0, 0)
+	p := &ProgressBar{Window: w, style: parent.ClientAreaStyle()}
+	w.OnPaintClientArea(p.onPaintClientAreaHandler, nil)
+	return p
+}
+
+func (p *ProgressBar) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	sz := w.ClientSize()
+	style := p.style.TCellStyle()
+	switch {
+	case p.isVertical():
+		n := progressFill(p.value, sz.Height)
+		for y := 0; y < sz.Height; y++ {
+			r := tcell.RuneCkBoard
+			if y >= sz.Height-n {
+				r = tcell.RuneBlock
+			}
+			w.SetCell(0, y, r, nil, style)
+		}
+	default:
+		n := progressFill(p.value, sz.Width)
+		for y := 0; y < sz.Height; y++ {
+			for x := 0; x < sz.Width; x++ {
+				r := tcell.RuneCkBoard
+				if x < n {
+					r = tcell.RuneBlock
+				}
+				w.SetCell(x, y, r, nil, style)
+			}
+		}
+		if p.percentage {
+			s := progressLabel(p.value)
+			w.Print(alignX(AlignCenter, sz.Width, len(s)), (sz.Height-1)/2, p.style, s)
+		}
+	}
+}
+
+// invalidateRange invalidates cells [from, to) along the axis of p.
+func (p *ProgressBar) invalidateRange(from, to int) {
+	if from > to {
+		from, to = to, from
+	}
+	sz := p.ClientSize()
+	var area wm.Rectangle
+	switch {
+	case p.isVertical():
+		area = wm.Rectangle{Position: wm.Position{Y: sz.Height - to}, Size: wm.Size{Width: 1, Height: to - from}}
+	default:
+		area = wm.Rectangle{Position: wm.Position{X: from}, Size: wm.Size{Width: to - from, Height: sz.Height}}
+	}
+	area.Position = area.Add(p.Origin())
+	p.InvalidateClientArea(area)
+}
+
+func (p *ProgressBar) isVertical() bool {
+	sz := p.ClientSize()
+	return sz.Width == 1 && sz.Height > 1
+}
+
+// ----------------------------------------------------------------------------
+
+// Percentage returns whether the percentage label is shown.
+func (p *ProgressBar) Percentage() bool { return p.percentage }
+
+// SetPercentage sets whether a percentage label is shown centered on a
+// horizontal progress bar.
+func (p *ProgressBar) SetPercentage(v bool) {
+	if p.percentage == v {
+		return
+	}
+
+	p.percentage = v
+	p.InvalidateClientArea(wm.Rectangle{Position: p.Origin(), Size: p.ClientSize()})
+}
+
+// SetStyle sets the style of the progress bar.
+func (p *ProgressBar) SetStyle(s wm.Style) {
+	if p.style == s {
+		return
+	}
+
+	p.style = s
+	p.InvalidateClientArea(wm.Rectangle{Position: p.Origin(), Size: p.ClientSize()})
+}
+
+// SetValue sets the progress value. Values outside of [0, 1] are clamped.
+// Only the cells affected by the change are repainted.
+func (p *ProgressBar) SetValue(v float64) {
+	v = math.Max(0, math.Min(v, 1))
+	if p.value == v {
+		return
+	}
+
+	sz := p.ClientSize()
+	n := sz.Width
+	if p.isVertical() {
+		n = sz.Height
+	}
+	old := p.value
+	p.BeginUpdate()
+	p.value = v
+	if a, b := progressFill(old, n), progressFill(v, n); a != b {
+		p.invalidateRange(a, b)
+	}
+	if p.percentage && !p.isVertical() {
+		if s, t := progressLabel(old), progressLabel(v); s != t {
+			w := mathutil.Max(len(s), len(t))
+			x := alignX(AlignCenter, sz.Width, w)
+			p.invalidateRange(x-1, x+w+1)
+		}
+	}
+	p.EndUpdate()
+}
+
+// Style returns the style of the progress bar.
+func (p *ProgressBar) Style() wm.Style { return p.style }
+
+// Value returns the progress value.
+func (p *ProgressBar) Value() float64 { return p.value }
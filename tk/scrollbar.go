@@ -10,6 +10,51 @@ import (
 	"github.com/gdamore/tcell"
 )
 
+// handleGeometry returns the position and size of the handle of a scrollbar
+// with track cells, sans arrows, for a view showing viewportSize cells of
+// contentSize cells starting at origin. The handle is at least minSize cells
+// big, if the track has room for that. The handle touches the start of the
+// track if and only if origin is zero and it touches the end of the track if
+// and only if the view shows the end of the content, provided the track has
+// room for that.
+func handleGeometry(origin, viewportSize, contentSize, track, minSize int) (pos, size int) {
+	if track <= 0 || contentSize <= 0 {
+		return 0, 0
+	}
+
+	visible := mathutil.Max(0, mathutil.Min(viewportSize, contentSize-origin))
+	size = mathutil.Min(track, mathutil.Max(mathutil.Max(1, minSize), visible*track/contentSize))
+	free := track - size
+	last := contentSize - viewportSize // Origin showing the end of the content.
+	switch {
+	case origin <= 0 || free == 0:
+		return 0, size
+	case origin >= last:
+		return free, size
+	}
+
+	pos = (origin*free + last/2) / last
+	if free >= 2 {
+		pos = mathutil.Max(1, mathutil.Min(pos, free-1))
+	}
+	return pos, size
+}
+
+// handleOrigin is the inverse of handleGeometry. It returns the view origin
+// for the handle of size cells at pos.
+func handleOrigin(pos, size, viewportSize, contentSize, track int) int {
+	free := track - size
+	last := contentSize - viewportSize
+	switch {
+	case free <= 0 || last <= 0 || pos <= 0:
+		return 0
+	case pos >= free:
+		return last
+	}
+
+	return (pos*last + free/2) / free
+}
+
 // Scrollbar represents an UI element used to show that a View content
 // overflows its window and provide visual feedback of the position of its
 // viewport.
@@ -19,10 +64,12 @@ import (
 // wm.Application.PostWait.
 type Scrollbar struct {
 	dragHandlePos0       int                          //
-	dragScreenPos0       wm.Position                  //
 	draggingHandle       bool                         //
 	handlePos            int                          //
 	handleSize           int                          //
+	jumpToClick          bool                         //
+	jumping              bool                         // Handle position is being set by a jump to click.
+	minHandleSize        int                          //
 	onClickDecrement     *wm.OnMouseHandlerList       //
 	onClickDecrementPage *wm.OnMouseHandlerList       //
 	onClickIncrement     *wm.OnMouseHandlerList       //
@@ -36,12 +83,13 @@ type Scrollbar struct {
 	position             wm.Position                  //
 	size                 wm.Size                      //
 	style                wm.Style                     //
+	view                 [3]int                       // Last SetView arguments.
 	w                    *wm.Window                   //
 }
 
 // NewScrollbar returns a newly created Scrollbar.
 func NewScrollbar(w *wm.Window) *Scrollbar {
-	s := &Scrollbar{w: w}
+	s := &Scrollbar{minHandleSize: 1, w: w}
 	s.OnPaint(s.onPaintHandler, nil)
 	s.OnSetHandlePosition(s.onSetHandlePosHandler, nil)
 	s.OnSetHandleSize(s.onSetHandleSizeHandler, nil)
@@ -50,7 +98,10 @@ func NewScrollbar(w *wm.Window) *Scrollbar {
 	s.OnSetStyle(s.onSetStyleHandler, nil)
 	w.OnClickBorder(s.onClickBorderHandler, nil)
 	w.OnClose(s.onCloseHandler, nil)
-	w.OnDragBorder(s.onDragBorderHandler, nil)
+	w.OnDragEnd(s.onDragEndHandler, nil)
+	w.OnDragMove(s.onDragMoveHandler, nil)
+	w.OnDragStart(s.onDragStartHandler, nil)
+	w.OnMouseMoveBorder(s.onMouseMoveBorderHandler, nil)
 	return s
 }
 
@@ -84,7 +135,7 @@ func (s *Scrollbar) place(w *wm.Window, winPos wm.Position) int {
 	switch {
 	case s.isVertical():
 		area := w.BorderRightArea()
-		if !area.Clip(wm.Rectangle{Position: wm.Position{X: area.X + pos.X, Y: area.Y + pos.Y}, Size: sz}) || !winPos.In(area) {
+		if !area.Clip(wm.Rectangle{Position: area.Add(pos), Size: sz}) || !winPos.In(area) {
 			return -1
 		}
 
@@ -102,7 +153,7 @@ func (s *Scrollbar) place(w *wm.Window, winPos wm.Position) int {
 		}
 	default:
 		area := w.BorderBottomArea()
-		if !area.Clip(wm.Rectangle{Position: wm.Position{X: area.X + pos.X, Y: area.Y + pos.Y}, Size: sz}) || !winPos.In(area) {
+		if !area.Clip(wm.Rectangle{Position: area.Add(pos), Size: sz}) || !winPos.In(area) {
 			return -1
 		}
 
@@ -121,41 +172,48 @@ func (s *Scrollbar) place(w *wm.Window, winPos wm.Position) int {
 	}
 }
 
-func (s *Scrollbar) onMouseMoveHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
-	if s.draggingHandle {
-		switch {
-		case s.isVertical():
-			dy := screenPos.Y - s.dragScreenPos0.Y
-			s.SetHandlePosition(s.dragHandlePos0 + dy)
-		default:
-			dx := screenPos.X - s.dragScreenPos0.X
-			s.SetHandlePosition(s.dragHandlePos0 + dx)
-		}
-		return true
+func (s *Scrollbar) onDragEndHandler(w *wm.Window, prev wm.OnDragHandler, button tcell.ButtonMask, screenPos0, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if !s.draggingHandle {
+		return prev != nil && prev(w, nil, button, screenPos0, screenPos, winPos, mods)
 	}
 
-	return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+	s.draggingHandle = false
+	s.w.BringToFront()
+	s.w.SetFocus(true)
+	return true
 }
 
-func (s *Scrollbar) onDropHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
-	if s.draggingHandle {
-		r := s.w.Desktop().Root()
-		r.RemoveOnDrop()
-		r.RemoveOnMouseMove()
-		s.w.BringToFront()
-		s.w.SetFocus(true)
-		if w := s.w; w != r {
-			w.RemoveOnDrop()
-			w.RemoveOnMouseMove()
-		}
-		s.draggingHandle = false
+func (s *Scrollbar) onDragMoveHandler(w *wm.Window, prev wm.OnDragHandler, button tcell.ButtonMask, screenPos0, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if !s.draggingHandle {
+		return prev != nil && prev(w, nil, button, screenPos0, screenPos, winPos, mods)
+	}
+
+	switch {
+	case s.isVertical():
+		s.SetHandlePosition(s.dragHandlePos0 + screenPos.Y - screenPos0.Y)
+	default:
+		s.SetHandlePosition(s.dragHandlePos0 + screenPos.X - screenPos0.X)
+	}
+	return true
+}
+
+func (s *Scrollbar) onDragStartHandler(w *wm.Window, prev wm.OnDragHandler, button tcell.ButtonMask, screenPos0, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos0, screenPos, winPos, mods) {
 		return true
 	}
 
-	return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+	if button != tcell.Button1 || mods != 0 || s.place(w, winPos) != scrollbarHandle {
+		return false
+	}
+
+	s.draggingHandle = true
+	s.dragHandlePos0 = s.HandlePosition()
+	s.w.BringToFront()
+	s.w.SetFocus(true)
+	return true
 }
 
-func (s *Scrollbar) onDragBorderHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+func (s *Scrollbar) onClickBorderHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
 	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
 		return true
 	}
@@ -164,45 +222,66 @@ func (s *Scrollbar) onDragBorderHandler(w *wm.Window, prev wm.OnMouseHandler, bu
 		return false
 	}
 
-	switch s.place(w, winPos) {
-	case scrollbarHandle:
-		s.draggingHandle = true
-		r := s.w.Desktop().Root()
-		r.OnDrop(s.onDropHandler, nil)
-		r.OnMouseMove(s.onMouseMoveHandler, nil)
-		s.dragHandlePos0 = s.HandlePosition()
-		s.dragScreenPos0 = screenPos
-		if w := s.w; w != r {
-			w.OnDrop(s.onDropHandler, nil)
-			w.OnMouseMove(s.onMouseMoveHandler, nil)
+	switch place := s.place(w, winPos); place {
+	case decrementArrow:
+		return s.onClickDecrement.Handle(w, button, screenPos, winPos, mods)
+	case decrementPage, incrementPage:
+		if s.jumpToClick {
+			s.jump(winPos)
+			return true
 		}
-		s.w.BringToFront()
-		s.w.SetFocus(true)
-		return true
+
+		if place == decrementPage {
+			return s.onClickDecrementPage.Handle(w, button, screenPos, winPos, mods)
+		}
+
+		return s.onClickIncrementPage.Handle(w, button, screenPos, winPos, mods)
+	case incrementArrow:
+		return s.onClickIncrement.Handle(w, button, screenPos, winPos, mods)
 	default:
 		return false
 	}
+}
 
+// jump moves the handle so its center lands at winPos.
+func (s *Scrollbar) jump(winPos wm.Position) {
+	n := s.size.Width - 2 // Sans arrows.
+	t := winPos.X - s.position.X - 1
+	if s.isVertical() {
+		n = s.size.Height - 2
+		t = winPos.Y - s.position.Y - 1
+	}
+	if t < 0 || t >= n {
+		return
+	}
+
+	s.jumping = true
+	s.SetHandlePosition(t - s.HandleSize()/2)
+	s.jumping = false
 }
 
-func (s *Scrollbar) onClickBorderHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+// onMouseMoveBorderHandler pages the scrollbar on mouse wheel events over it.
+// If no page handler consumes the event, the handle is moved by its size.
+func (s *Scrollbar) onMouseMoveBorderHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
 	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
 		return true
 	}
 
-	if button != tcell.Button1 || mods != 0 {
+	if s.place(w, winPos) < 0 {
 		return false
 	}
 
-	switch s.place(w, winPos) {
-	case decrementArrow:
-		return s.onClickDecrement.Handle(w, button, screenPos, winPos, mods)
-	case decrementPage:
-		return s.onClickDecrementPage.Handle(w, button, screenPos, winPos, mods)
-	case incrementPage:
-		return s.onClickIncrementPage.Handle(w, button, screenPos, winPos, mods)
-	case incrementArrow:
-		return s.onClickIncrement.Handle(w, button, screenPos, winPos, mods)
+	switch button {
+	case tcell.WheelUp, tcell.WheelLeft:
+		if !s.onClickDecrementPage.Handle(w, button, screenPos, winPos, mods) {
+			s.SetHandlePosition(s.HandlePosition() - mathutil.Max(1, s.HandleSize()))
+		}
+		return true
+	case tcell.WheelDown, tcell.WheelRight:
+		if !s.onClickIncrementPage.Handle(w, button, screenPos, winPos, mods) {
+			s.SetHandlePosition(s.HandlePosition() + mathutil.Max(1, s.HandleSize()))
+		}
+		return true
 	default:
 		return false
 	}
@@ -322,6 +401,13 @@ func (s *Scrollbar) HandlePosition() int { return s.handlePos }
 // HandleSize returns the size of the scrollbar handle.
 func (s *Scrollbar) HandleSize() int { return s.handleSize }
 
+// JumpToClick reports whether clicking the scrollbar trough moves the handle
+// to the clicked position.
+func (s *Scrollbar) JumpToClick() bool { return s.jumpToClick }
+
+// MinHandleSize returns the minimum size of the handle.
+func (s *Scrollbar) MinHandleSize() int { return s.minHandleSize }
+
 // OnClickIncrement sets a handler invokend on clicking the right arrow of a
 // horizontal scrollbar or the down arrow of a vertical scrollbar. When the
 // event handler is removed, finalize is called, if not nil.
@@ -438,6 +524,28 @@ func (s *Scrollbar) RemoveOnSetStyle() { wm.RemoveOnSetStyleHandler(&s.onSetStyl
 // SetPosition sets the scrollbar position.
 func (s *Scrollbar) SetPosition(v wm.Position) { s.onSetPosition.Handle(s.w, &s.position, v) }
 
+// SetJumpToClick sets whether clicking the scrollbar trough moves the handle
+// so its center lands at the clicked position instead of paging by one
+// viewport. The OnClickDecrementPage and OnClickIncrementPage handlers are
+// not invoked in this mode.
+func (s *Scrollbar) SetJumpToClick(v bool) { s.jumpToClick = v }
+
+// SetMinHandleSize sets the minimum size of the handle, which is 1 by default.
+// A bigger handle is easier to see and drag when the content is much bigger
+// than the view. The handle is never bigger than the scrollbar track. Values
+// less than 1 are handled like 1.
+func (s *Scrollbar) SetMinHandleSize(n int) {
+	n = mathutil.Max(1, n)
+	if s.minHandleSize == n {
+		return
+	}
+
+	s.minHandleSize = n
+	if s.view[2] > 0 {
+		s.SetView(s.view[0], s.view[1], s.view[2])
+	}
+}
+
 // SetSize sets the scrollbar size.
 func (s *Scrollbar) SetSize(v wm.Size) { s.onSetSize.Handle(s.w, &s.size, v) }
 
@@ -459,6 +567,8 @@ func (s *Scrollbar) SetView(origin, viewportSize, contentSize int) {
 		panic("Scrollbar.SetView: invalid origin")
 	}
 
+	s.view = [3]int{origin, viewportSize, contentSize}
+
 	if contentSize < 1 { // Unknown content size.
 		s.SetHandlePosition(0)
 		s.SetHandleSize(0)
@@ -466,15 +576,12 @@ func (s *Scrollbar) SetView(origin, viewportSize, contentSize int) {
 		return
 	}
 
-	clip := wm.NewRectangle(-origin, 0, contentSize, 0)
-	clip.Clip(wm.NewRectangle(0, 0, viewportSize, 0))
-
-	scrollbarSize := s.size.Width - 2 // Sans arrows.
+	track := s.size.Width - 2 // Sans arrows.
 	if s.isVertical() {
-		scrollbarSize = s.size.Height - 2
+		track = s.size.Height - 2
 	}
-	handlePos := mathutil.Min(scrollbarSize-1, (origin*scrollbarSize+contentSize/2)/contentSize)
-	handleSize := mathutil.Max(1, clip.Width*scrollbarSize/contentSize)
+	handlePos, handleSize := handleGeometry(origin, viewportSize, contentSize, track, s.minHandleSize)
+	s.SetHandleSize(0) // Avoid clamping the position by the old size.
 	s.SetHandlePosition(handlePos)
 	s.SetHandleSize(handleSize)
 	s.w.Invalidate(s.w.Area())
@@ -0,0 +1,266 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"math"
+
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+)
+
+// splitExtent returns the size of the first pane of a splitter along its axis
+// of size total, of which one cell is taken by the divider. Both panes are at
+// least min cells big, if possible.
+func splitExtent(total, min int, ratio float64) int {
+	avail := total - 1
+	if avail <= 0 {
+		return 0
+	}
+
+	if avail < 2*min {
+		return avail / 2
+	}
+
+	e := int(ratio*float64(avail) + 0.5)
+	return mathutil.Min(mathutil.Max(e, min), avail-min)
+}
+
+// borderless removes the borders of w and returns it.
+func borderless(w *wm.Window) *wm.Window {
+	w.SetBorders(0, 0, 0, 0)
+	return w
+}
+
+// Splitter manages two panes, child windows separated by a divider, which can
+// be dragged by mouse to change the split ratio. The panes are placed side by
+// side when the divider is vertical, otherwise one above another.
+//
+// Splitter methods must be called only directly from an event handler
+// goroutine or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type Splitter struct {
+	*wm.Window                 // Underlying window.
+	divider      *wm.Window    //
+	dividerStyle wm.Style      //
+	dragExtent0  int           // First pane size on drag event.
+	dragPos0     wm.Position   // Mouse screen position on drag event.
+	dragging     bool          //
+	first        *wm.Window    //
+	minPaneSize  int           //
+	onResize     func(float64) //
+	ratio        float64       // In [0, 1].
+	second       *wm.Window    //
+	vertical     bool          // Vertical divider.
+}
+
+// NewSplitter creates a borderless child window of parent, positioned and
+// sized by area, and returns the resulting Splitter. The splitter has two
+// borderless panes of equal size, see First and Second, separated by a
+// vertical divider if vertical is true or by a horizontal divider otherwise.
+// The divider style is initially the border style of parent and the minimum
+// pane size is 1.
+//
+// Resizing the splitter keeps the split ratio.
+//
+// NewSplitter must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewSplitter(parent *wm.Window, area wm.Rectangle, vertical bool) *Splitter {
+	parent.BeginUpdate()
+	defer parent.EndUpdate()
+
+	w := borderless(parent.NewChild(area))
+	s := &Splitter{
+		Window:       w,
+		dividerStyle: parent.BorderStyle(),
+		minPaneSize:  1,
+		ratio:        0.5,
+		vertical:     vertical,
+	}
+	s.first = borderless(w.NewChild(wm.Rectangle{}))
+	s.second = borderless(w.NewChild(wm.Rectangle{}))
+	s.divider = borderless(w.NewChild(wm.Rectangle{}))
+	s.divider.OnDrag(s.onDragHandler, nil)
+	s.divider.OnDrop(s.onDropHandler, nil)
+	s.divider.OnMouseMove(s.onMouseMoveHandler, nil)
+	s.divider.OnPaintClientArea(s.onPaintDividerHandler, nil)
+	w.OnSetClientSize(s.onSetClientSizeHandler, nil)
+	s.layout()
+	return s
+}
+
+func (s *Splitter) onDragHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
+		return true
+	}
+
+	if button != tcell.Button1 || mods != 0 {
+		return false
+	}
+
+	s.dragging = true
+	s.dragExtent0 = splitExtent(s.axisSize(), s.minPaneSize, s.ratio)
+	s.dragPos0 = screenPos
+	w.SetFocus(true)
+	return true
+}
+
+func (s *Splitter) onDropHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if !s.dragging {
+		return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+	}
+
+	s.drag(screenPos)
+	s.dragging = false
+	return true
+}
+
+func (s *Splitter) onMouseMoveHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if !s.dragging {
+		return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+	}
+
+	s.drag(screenPos)
+	return true
+}
+
+func (s *Splitter) onPaintDividerHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	r := tcell.RuneHLine
+	if s.vertical {
+		r = tcell.RuneVLine
+	}
+	style := s.dividerStyle.TCellStyle()
+	sz := w.ClientSize()
+	for y := 0; y < sz.Height; y++ {
+		for x := 0; x < sz.Width; x++ {
+			w.SetCell(x, y, r, nil, style)
+		}
+	}
+}
+
+func (s *Splitter) onSetClientSizeHandler(w *wm.Window, prev wm.OnSetSizeHandler, dst *wm.Size, src wm.Size) {
+	if prev != nil {
+		prev(w, nil, dst, src)
+	}
+	s.layout()
+}
+
+// axisSize returns the client area size of s along the axis of the panes.
+func (s *Splitter) axisSize() int {
+	sz := s.ClientSize()
+	if s.vertical {
+		return sz.Width
+	}
+
+	return sz.Height
+}
+
+// drag moves the divider according to the mouse being at screenPos.
+func (s *Splitter) drag(screenPos wm.Position) {
+	d := screenPos.Y - s.dragPos0.Y
+	if s.vertical {
+		d = screenPos.X - s.dragPos0.X
+	}
+	avail := s.axisSize() - 1
+	if avail <= 0 {
+		return
+	}
+
+	e := mathutil.Min(mathutil.Max(s.dragExtent0+d, s.minPaneSize), avail-s.minPaneSize)
+	s.SetRatio(float64(e) / float64(avail))
+}
+
+// layout sets the geometry of the panes and the divider.
+func (s *Splitter) layout() {
+	sz := s.ClientSize()
+	o := s.Origin()
+	e := splitExtent(s.axisSize(), s.minPaneSize, s.ratio)
+	s.BeginUpdate()
+	switch {
+	case s.vertical:
+		s.first.SetRectangle(wm.Rectangle{Position: o, Size: wm.Size{Width: e, Height: sz.Height}})
+		s.divider.SetRectangle(wm.Rectangle{Position: wm.Position{X: o.X + e, Y: o.Y}, Size: wm.Size{Width: mathutil.Min(1, sz.Width), Height: sz.Height}})
+		s.second.SetRectangle(wm.Rectangle{Position: wm.Position{X: o.X + e + 1, Y: o.Y}, Size: wm.Size{Width: mathutil.Max(0, sz.Width-e-1), Height: sz.Height}})
+	default:
+		s.first.SetRectangle(wm.Rectangle{Position: o, Size: wm.Size{Width: sz.Width, Height: e}})
+		s.divider.SetRectangle(wm.Rectangle{Position: wm.Position{X: o.X, Y: o.Y + e}, Size: wm.Size{Width: sz.Width, Height: mathutil.Min(1, sz.Height)}})
+		s.second.SetRectangle(wm.Rectangle{Position: wm.Position{X: o.X, Y: o.Y + e + 1}, Size: wm.Size{Width: sz.Width, Height: mathutil.Max(0, sz.Height-e-1)}})
+	}
+	s.EndUpdate()
+}
+
+// ----------------------------------------------------------------------------
+
+// Divider returns the divider window.
+func (s *Splitter) Divider() *wm.Window { return s.divider }
+
+// DividerStyle returns the style of the divider.
+func (s *Splitter) DividerStyle() wm.Style { return s.dividerStyle }
+
+// First returns the left or top pane.
+func (s *Splitter) First() *wm.Window { return s.first }
+
+// MinPaneSize returns the minimum size of the panes along the axis of the
+// splitter.
+func (s *Splitter) MinPaneSize() int { return s.minPaneSize }
+
+// OnResize sets the function invoked when the split ratio changes, replacing
+// any previously set function. Passing nil removes it.
+func (s *Splitter) OnResize(f func(float64)) { s.onResize = f }
+
+// Ratio returns the split ratio, the size of the first pane relative to the
+// space shared by both panes.
+func (s *Splitter) Ratio() float64 { return s.ratio }
+
+// Second returns the right or bottom pane.
+func (s *Splitter) Second() *wm.Window { return s.second }
+
+// SetDividerStyle sets the style of the divider.
+func (s *Splitter) SetDividerStyle(v wm.Style) {
+	if s.dividerStyle == v {
+		return
+	}
+
+	s.dividerStyle = v
+	s.divider.InvalidateClientArea(wm.Rectangle{Position: s.divider.Origin(), Size: s.divider.ClientSize()})
+}
+
+// SetMinPaneSize sets the minimum size of the panes along the axis of the
+// splitter. The limit is not enforced if the splitter is too small to satisfy
+// it for both panes. Negative values are handled like zero.
+func (s *Splitter) SetMinPaneSize(n int) {
+	n = mathutil.Max(n, 0)
+	if s.minPaneSize == n {
+		return
+	}
+
+	s.minPaneSize = n
+	s.layout()
+}
+
+// SetRatio sets the split ratio, the size of the first pane relative to the
+// space shared by both panes. Values outside of [0, 1] are clamped. The
+// resulting pane sizes respect the minimum pane size.
+func (s *Splitter) SetRatio(v float64) {
+	v = math.Max(0, math.Min(v, 1))
+	if s.ratio == v {
+		return
+	}
+
+	s.ratio = v
+	s.layout()
+	if f := s.onResize; f != nil {
+		f(v)
+	}
+}
+
+// Vertical returns whether the divider is vertical.
+func (s *Splitter) Vertical() bool { return s.vertical }
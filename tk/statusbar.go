@@ -0,0 +1,133 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"github.com/cznic/wm"
+	"github.com/mattn/go-runewidth"
+)
+
+type statusSegment struct {
+	align Alignment
+	text  string
+}
+
+// statusLayout returns the x coordinates of segs within width. Left aligned
+// segments are placed from the left edge, right aligned segments from the
+// right edge. Adjacent segments are separated by 3 cells.
+func statusLayout(width int, segs []statusSegment) []int {
+	r := make([]int, len(segs))
+	left, right := 1, width-1
+	for i, v := range segs {
+		if v.align != AlignRight {
+			r[i] = left
+			left += runewidth.StringWidth(v.text) + 3
+		}
+	}
+	for i := len(segs) - 1; i >= 0; i-- {
+		if v := segs[i]; v.align == AlignRight {
+			right -= runewidth.StringWidth(v.text)
+			r[i] = right
+			right -= 3
+		}
+	}
+	return r
+}
+
+// StatusBar is a single line window pinned to the bottom of the client area
+// of its parent. It shows a number of text segments.
+//
+// StatusBar methods must be called only directly from an event handler
+// goroutine or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type StatusBar struct {
+	*wm.Window                          // Underlying window.
+	removeParentHandler func()          // Removes the parent's OnSetClientSize handler.
+	segments            []statusSegment //
+}
+
+// NewStatusBar creates a borderless, full width child window of parent, one
+// line high and pinned to the bottom edge of the parent's client area, and
+// returns the resulting StatusBar. The status bar follows changes of the
+// parent's client area size.
+//
+// NewStatusBar must be called only directly from an event handler goroutine
+// or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewStatusBar(parent *wm.Window) *StatusBar {
+	parent.BeginUpdate()
+	defer parent.EndUpdate()
+
+	w := parent.NewChild(wm.Rectangle{})
+	w.SetBorders(0, 0, 0, 0)
+	s := &StatusBar{Window: w}
+	w.OnClose(s.onCloseHandler, nil)
+	w.OnPaintClientArea(s.onPaintClientAreaHandler, nil)
+	s.removeParentHandler = parent.OnSetClientSizeCancelable(s.onSetParentClientSizeHandler, nil)
+	s.place()
+	return s
+}
+
+func (s *StatusBar) onCloseHandler(w *wm.Window, prev wm.OnCloseHandler) {
+	if prev != nil {
+		prev(w, nil)
+	}
+	s.removeParentHandler()
+}
+
+func (s *StatusBar) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	style := w.ClientAreaStyle()
+	for i, x := range statusLayout(w.ClientSize().Width, s.segments) {
+		w.Print(x, 0, style, s.segments[i].text)
+	}
+}
+
+func (s *StatusBar) onSetParentClientSizeHandler(w *wm.Window, prev wm.OnSetSizeHandler, dst *wm.Size, src wm.Size) {
+	if prev != nil {
+		prev(w, nil, dst, src)
+	}
+	s.place()
+}
+
+// place pins s to the bottom of its parent.
+func (s *StatusBar) place() {
+	p := s.Parent()
+	sz := p.ClientSize()
+	s.SetRectangle(wm.Rectangle{Position: wm.Position{Y: sz.Height - 1}.Add(p.Origin()), Size: wm.Size{Width: sz.Width, Height: 1}})
+}
+
+func (s *StatusBar) invalidate() {
+	s.InvalidateClientArea(wm.Rectangle{Position: s.Origin(), Size: s.ClientSize()})
+}
+
+// ----------------------------------------------------------------------------
+
+// AddSegment appends a segment showing text aligned using a and returns its
+// index. AlignCenter is handled like AlignLeft.
+func (s *StatusBar) AddSegment(text string, a Alignment) int {
+	s.segments = append(s.segments, statusSegment{a, text})
+	s.invalidate()
+	return len(s.segments) - 1
+}
+
+// Segment returns the text of the segment at index.
+func (s *StatusBar) Segment(index int) string { return s.segments[index].text }
+
+// Segments returns the number of segments.
+func (s *StatusBar) Segments() int { return len(s.segments) }
+
+// SetSegment sets the text of the segment at index.
+func (s *StatusBar) SetSegment(index int, text string) {
+	if s.segments[index].text == text {
+		return
+	}
+
+	s.segments[index].text = text
+	s.invalidate()
+}
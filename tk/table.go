@@ -0,0 +1,255 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"strings"
+
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// TableColumn describes a column of a Table.
+type TableColumn struct {
+	Align Alignment // Alignment of the title and the cells.
+	Title string    // Shown in the header.
+	Width int       // Cells wider than Width are truncated.
+}
+
+// tableColumnX returns the x coordinates of cols and the total width.
+func tableColumnX(cols []TableColumn) (r []int, width int) {
+	r = make([]int, len(cols))
+	for i, v := range cols {
+		r[i] = width
+		width += mathutil.Max(0, v.Width)
+	}
+	return r, width
+}
+
+// tableCellX returns the x offset of s within column c.
+func tableCellX(c TableColumn, s string) int {
+	return alignX(c.Align, c.Width, mathutil.Min(runewidth.StringWidth(s), c.Width))
+}
+
+// Table shows rows of text cells arranged in columns below a header showing
+// the column titles. The header stays at the top of the client area while
+// the rows scroll using the embedded View.
+//
+// Table methods must be called only directly from an event handler goroutine
+// or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type Table struct {
+	*View                  // Underlying view.
+	columns  []TableColumn //
+	onSelect func(int)     //
+	rows     [][]string    //
+	selected int           // -1 if there are no rows.
+	width    int           // Sum of the column widths.
+}
+
+// NewTable configures w to show a table with columns and returns the
+// resulting Table. The table has initially no rows.
+//
+// NewTable must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewTable(w *wm.Window, columns []TableColumn) *Table {
+	t := &Table{selected: -1}
+	t.View = NewView(w, t)
+	w.OnClick(t.onClickHandler, nil)
+	w.OnKey(t.onKeyHandler, nil)
+	w.OnPaintClientArea(t.onPaintClientAreaHandler, nil)
+	t.SetColumns(columns)
+	return t
+}
+
+// Metrics implements Meter. The height includes the header line.
+func (t *Table) Metrics(viewport wm.Rectangle) wm.Size {
+	return wm.Size{Width: t.width, Height: len(t.rows) + 1}
+}
+
+func (t *Table) onClickHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
+		return true
+	}
+
+	if button != tcell.Button1 {
+		return false
+	}
+
+	// The header covers the top line of the viewport.
+	if y := winPos.Y; y > w.Origin().Y {
+		if i := y - 1; i < len(t.rows) {
+			t.Select(i)
+		}
+	}
+	return true
+}
+
+func (t *Table) onKeyHandler(w *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+	if prev != nil && prev(w, nil, key, mod, r) {
+		return true
+	}
+
+	page := mathutil.Max(1, t.ClientSize().Height-1)
+	switch key {
+	case tcell.KeyDown:
+		t.Select(t.selected + 1)
+	case tcell.KeyEnd:
+		t.Select(len(t.rows) - 1)
+	case tcell.KeyHome:
+		t.Select(0)
+	case tcell.KeyPgDn:
+		t.Select(t.selected + page)
+	case tcell.KeyPgUp:
+		t.Select(t.selected - page)
+	case tcell.KeyUp:
+		t.Select(t.selected - 1)
+	default:
+		return false
+	}
+	return true
+}
+
+// onPaintClientAreaHandler paints the header at the top line of the viewport
+// and the rows below it. Row i is shown at line i+1 of the content, the row
+// at the top line of the viewport is covered by the header.
+func (t *Table) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	cpY := w.ClientPosition().Y
+	top := w.Origin().Y
+	width := mathutil.Max(t.width, w.Origin().X+w.ClientSize().Width)
+	xs, _ := tableColumnX(t.columns)
+	for i := 0; i < ctx.Height; i++ {
+		y := ctx.Y - cpY + i
+		style := w.ClientAreaStyle()
+		if y == top {
+			style.Attr ^= tcell.AttrBold
+			for j, c := range t.columns {
+				x := tableCellX(c, c.Title)
+				w.PrintfWidth(xs[j]+x, y, c.Width-x, style, "%s", c.Title)
+			}
+			continue
+		}
+
+		row := y - 1
+		if row >= len(t.rows) {
+			break
+		}
+
+		if row == t.selected {
+			style.Attr ^= tcell.AttrReverse
+			w.Print(0, y, style, strings.Repeat(" ", width))
+		}
+		for j, s := range t.rows[row] {
+			if j >= len(t.columns) {
+				break
+			}
+
+			c := t.columns[j]
+			x := tableCellX(c, s)
+			w.PrintfWidth(xs[j]+x, y, c.Width-x, style, "%s", s)
+		}
+	}
+}
+
+func (t *Table) invalidateRow(i int) {
+	if i < 0 {
+		return
+	}
+
+	t.InvalidateClientArea(wm.Rectangle{Position: wm.Position{X: t.Origin().X, Y: i + 1}, Size: wm.Size{Width: t.ClientSize().Width, Height: 1}})
+}
+
+// scroll makes the selected row visible below the header.
+func (t *Table) scroll() {
+	h := t.ClientSize().Height - 1
+	if h <= 0 || t.selected < 0 {
+		return
+	}
+
+	o := t.Origin()
+	switch y := t.selected; {
+	case y < o.Y:
+		o.Y = y
+	case y >= o.Y+h:
+		o.Y = y - h + 1
+	}
+	t.SetOrigin(o)
+}
+
+// ----------------------------------------------------------------------------
+
+// Columns returns the columns of the table.
+func (t *Table) Columns() []TableColumn { return t.columns }
+
+// OnSelect sets the function invoked when the selected row changes,
+// replacing any previously set function. Passing nil removes it.
+func (t *Table) OnSelect(f func(int)) { t.onSelect = f }
+
+// Rows returns the rows of the table.
+func (t *Table) Rows() [][]string { return t.rows }
+
+// Select selects the row at index i and scrolls the view to make it visible.
+// Values of i outside of the rows are clamped.
+func (t *Table) Select(i int) {
+	if len(t.rows) == 0 {
+		return
+	}
+
+	i = mathutil.Min(mathutil.Max(i, 0), len(t.rows)-1)
+	if t.selected == i {
+		return
+	}
+
+	t.BeginUpdate()
+	t.invalidateRow(t.selected)
+	t.selected = i
+	t.invalidateRow(i)
+	t.scroll()
+	t.EndUpdate()
+	if f := t.onSelect; f != nil {
+		f(i)
+	}
+}
+
+// Selected returns the index of the selected row or -1 if there are no rows.
+func (t *Table) Selected() int { return t.selected }
+
+// SetColumns sets the columns of the table.
+func (t *Table) SetColumns(columns []TableColumn) {
+	t.BeginUpdate()
+	t.columns = columns
+	_, t.width = tableColumnX(columns)
+	t.updateScrollBars()
+	t.InvalidateClientArea(wm.Rectangle{Position: t.Origin(), Size: t.ClientSize()})
+	t.EndUpdate()
+}
+
+// SetRows sets the rows of the table. Row cells are matched to the columns by
+// index, cells without a column are not shown. The selected index is kept,
+// if possible.
+func (t *Table) SetRows(rows [][]string) {
+	t.BeginUpdate()
+	t.rows = rows
+	t.updateScrollBars()
+	t.InvalidateClientArea(wm.Rectangle{Position: t.Origin(), Size: t.ClientSize()})
+	sel := t.selected
+	switch n := len(rows); {
+	case n == 0:
+		t.selected = -1
+	case sel >= n:
+		t.selected = -1
+		t.Select(n - 1)
+	case sel < 0:
+		t.Select(0)
+	}
+	t.EndUpdate()
+}
@@ -0,0 +1,214 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// tabLayout returns the x coordinates of the labels of titles shown on the top
+// border. A label is the title padded by a space on both sides, adjacent
+// labels are separated by one cell.
+func tabLayout(titles []string) []int {
+	r := make([]int, len(titles))
+	x := 1
+	for i, v := range titles {
+		r[i] = x
+		x += runewidth.StringWidth(v) + 3
+	}
+	return r
+}
+
+// tabAt returns the index of the label of titles at x or -1 if there's no
+// such label.
+func tabAt(titles []string, x int) int {
+	for i, v := range tabLayout(titles) {
+		if x >= v && x < v+runewidth.StringWidth(titles[i])+2 {
+			return i
+		}
+	}
+	return -1
+}
+
+type tab struct {
+	title string
+	w     *wm.Window
+}
+
+// TabView is a window showing one of its child windows, the tabs, at a time.
+// The tab labels are shown on the top border and clicking a label selects the
+// tab. The label of the selected tab is highlighted. The window of a TabView
+// should have no title as it would overlap the labels.
+//
+// TabView methods must be called only directly from an event handler goroutine
+// or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type TabView struct {
+	*wm.Window           // Underlying window.
+	onSelect   func(int) //
+	selected   int       // -1 if there are no tabs.
+	tabs       []tab     //
+}
+
+// NewTabView configures w to show tabs and returns the resulting TabView.
+//
+// NewTabView must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewTabView(w *wm.Window) *TabView {
+	t := &TabView{Window: w, selected: -1}
+	w.OnClickBorder(t.onClickBorderHandler, nil)
+	w.OnPaintBorderTop(t.onPaintBorderTopHandler, nil)
+	w.OnSetClientSize(t.onSetClientSizeHandler, nil)
+	return t
+}
+
+func (t *TabView) onClickBorderHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if button == tcell.Button1 && winPos.Y == 0 {
+		if i := tabAt(t.titles(), winPos.X); i >= 0 {
+			t.Select(i)
+			return true
+		}
+	}
+
+	return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+}
+
+func (t *TabView) onPaintBorderTopHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	titles := t.titles()
+	for i, x := range tabLayout(titles) {
+		style := w.BorderStyle()
+		if i == t.selected {
+			style.Attr ^= tcell.AttrReverse
+		}
+		w.Printf(x, 0, style, " %s ", titles[i])
+	}
+}
+
+func (t *TabView) onSetClientSizeHandler(w *wm.Window, prev wm.OnSetSizeHandler, dst *wm.Size, src wm.Size) {
+	if prev != nil {
+		prev(w, nil, dst, src)
+	}
+	for _, v := range t.tabs {
+		t.place(v.w)
+	}
+}
+
+// invalidateLabels invalidates the top border.
+func (t *TabView) invalidateLabels() { t.Invalidate(t.BorderTopArea()) }
+
+// place makes w fill the client area of t.
+func (t *TabView) place(w *wm.Window) {
+	w.SetRectangle(wm.Rectangle{Position: t.Origin(), Size: t.ClientSize()})
+}
+
+// remove removes the tab showing w.
+func (t *TabView) remove(w *wm.Window) {
+	for i, v := range t.tabs {
+		if v.w != w {
+			continue
+		}
+
+		t.BeginUpdate()
+		t.tabs = append(t.tabs[:i], t.tabs[i+1:]...)
+		switch {
+		case len(t.tabs) == 0:
+			t.selected = -1
+		case i < t.selected:
+			t.selected--
+		case i == t.selected:
+			t.selected = -1
+			if i == len(t.tabs) {
+				i--
+			}
+			t.Select(i)
+		}
+		t.invalidateLabels()
+		t.EndUpdate()
+		return
+	}
+}
+
+func (t *TabView) titles() []string {
+	r := make([]string, len(t.tabs))
+	for i, v := range t.tabs {
+		r[i] = v.title
+	}
+	return r
+}
+
+// ----------------------------------------------------------------------------
+
+// AddTab appends a tab labeled title showing w, which must be a child window
+// of t, and returns its index. The window is made to fill the client area of
+// t and it's hidden unless it's the first tab, which gets selected. Closing
+// the window removes the tab.
+func (t *TabView) AddTab(title string, w *wm.Window) int {
+	t.BeginUpdate()
+	t.tabs = append(t.tabs, tab{title, w})
+	t.place(w)
+	w.OnClose(func(w *wm.Window, prev wm.OnCloseHandler) {
+		if prev != nil {
+			prev(w, nil)
+		}
+		t.remove(w)
+	}, nil)
+	n := len(t.tabs) - 1
+	switch {
+	case t.selected < 0:
+		t.Select(n)
+	default:
+		w.SetVisible(false)
+	}
+	t.invalidateLabels()
+	t.EndUpdate()
+	return n
+}
+
+// OnSelect sets the function invoked when the selected tab changes, replacing
+// any previously set function. Passing nil removes it.
+func (t *TabView) OnSelect(f func(int)) { t.onSelect = f }
+
+// Select shows the window of the tab at index i, brings it to front and
+// focuses it. Other tab windows are hidden. Values of i outside of the tabs
+// are ignored.
+func (t *TabView) Select(i int) {
+	if i < 0 || i >= len(t.tabs) {
+		return
+	}
+
+	t.BeginUpdate()
+	for j, v := range t.tabs {
+		if j != i {
+			v.w.SetVisible(false)
+		}
+	}
+	w := t.tabs[i].w
+	w.SetVisible(true)
+	w.BringToFront()
+	w.SetFocus(true)
+	changed := t.selected != i
+	t.selected = i
+	t.invalidateLabels()
+	t.EndUpdate()
+	if f := t.onSelect; changed && f != nil {
+		f(i)
+	}
+}
+
+// Selected returns the index of the selected tab or -1 if there are no tabs.
+func (t *TabView) Selected() int { return t.selected }
+
+// Tab returns the title and the window of the tab at index i.
+func (t *TabView) Tab(i int) (string, *wm.Window) { return t.tabs[i].title, t.tabs[i].w }
+
+// Tabs returns the number of tabs.
+func (t *TabView) Tabs() int { return len(t.tabs) }
@@ -0,0 +1,304 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+)
+
+// TextArea is a multi line text editor. It scrolls its content using the
+// embedded View.
+//
+// TextArea methods must be called only directly from an event handler
+// goroutine or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type TextArea struct {
+	*View                         // Underlying view.
+	caret    wm.Position          // X is an index into lines[Y].
+	lines    [][]rune             // Never empty.
+	maxWidth int                  // Width of the widest line.
+	onChange func(lines []string) //
+}
+
+// NewTextArea configures w to edit lines and returns the resulting TextArea.
+//
+// NewTextArea must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewTextArea(w *wm.Window, lines []string) *TextArea {
+	t := &TextArea{lines: [][]rune{nil}}
+	t.View = NewView(w, t)
+	w.OnClick(t.onClickHandler, nil)
+	w.OnKey(t.onKeyHandler, nil)
+	w.OnPaintClientArea(t.onPaintClientAreaHandler, nil)
+	w.OnSetFocus(t.onSetFocusHandler, nil)
+	t.SetLines(lines)
+	return t
+}
+
+// Metrics implements Meter. The reported width includes room for the caret
+// past the end of the widest line.
+func (t *TextArea) Metrics(viewport wm.Rectangle) wm.Size {
+	return wm.Size{Width: t.maxWidth + 1, Height: len(t.lines)}
+}
+
+func (t *TextArea) onClickHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
+		return true
+	}
+
+	if button != tcell.Button1 {
+		return false
+	}
+
+	y := mathutil.Min(mathutil.Max(winPos.Y, 0), len(t.lines)-1)
+	t.SetCaret(wm.Position{X: runesIndex(t.lines[y], winPos.X), Y: y})
+	return true
+}
+
+func (t *TextArea) onKeyHandler(w *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+	if prev != nil && prev(w, nil, key, mod, r) {
+		return true
+	}
+
+	c := t.caret
+	line := t.lines[c.Y]
+	switch key {
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		switch {
+		case c.X > 0:
+			t.edit(c, wm.Position{X: c.X - 1, Y: c.Y}, line[:c.X-1], line[c.X:])
+		case c.Y > 0:
+			above := t.lines[c.Y-1]
+			t.join(c.Y-1, wm.Position{X: len(above), Y: c.Y - 1})
+		}
+	case tcell.KeyDelete:
+		switch {
+		case c.X < len(line):
+			t.edit(c, c, line[:c.X], line[c.X+1:])
+		case c.Y < len(t.lines)-1:
+			t.join(c.Y, c)
+		}
+	case tcell.KeyDown:
+		t.moveVertically(1)
+	case tcell.KeyEnd:
+		if mod&tcell.ModCtrl != 0 {
+			t.End()
+			n := len(t.lines) - 1
+			t.SetCaret(wm.Position{X: len(t.lines[n]), Y: n})
+			break
+		}
+
+		t.SetCaret(wm.Position{X: len(line), Y: c.Y})
+	case tcell.KeyEnter:
+		t.split()
+	case tcell.KeyHome:
+		if mod&tcell.ModCtrl != 0 {
+			t.Home()
+			t.SetCaret(wm.Position{})
+			break
+		}
+
+		t.SetCaret(wm.Position{Y: c.Y})
+	case tcell.KeyLeft:
+		switch {
+		case c.X > 0:
+			t.SetCaret(wm.Position{X: c.X - 1, Y: c.Y})
+		case c.Y > 0:
+			t.SetCaret(wm.Position{X: len(t.lines[c.Y-1]), Y: c.Y - 1})
+		}
+	case tcell.KeyPgDn:
+		t.PageDown()
+		t.moveVertically(t.ClientSize().Height)
+	case tcell.KeyPgUp:
+		t.PageUp()
+		t.moveVertically(-t.ClientSize().Height)
+	case tcell.KeyRight:
+		switch {
+		case c.X < len(line):
+			t.SetCaret(wm.Position{X: c.X + 1, Y: c.Y})
+		case c.Y < len(t.lines)-1:
+			t.SetCaret(wm.Position{Y: c.Y + 1})
+		}
+	case tcell.KeyRune:
+		if r < ' ' || mod&(tcell.ModAlt|tcell.ModCtrl) != 0 {
+			return false
+		}
+
+		t.edit(c, wm.Position{X: c.X + 1, Y: c.Y}, line[:c.X], []rune{r}, line[c.X:])
+	case tcell.KeyUp:
+		t.moveVertically(-1)
+	default:
+		return false
+	}
+	return true
+}
+
+func (t *TextArea) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	style := w.ClientAreaStyle()
+	cpY := w.ClientPosition().Y
+	for i := 0; i < ctx.Height; i++ {
+		y := ctx.Y - cpY + i
+		if y >= len(t.lines) {
+			break
+		}
+
+		w.Print(0, y, style, string(t.lines[y]))
+	}
+	if !w.Focus() {
+		return
+	}
+
+	line := t.lines[t.caret.Y]
+	r := ' '
+	if t.caret.X < len(line) {
+		r = line[t.caret.X]
+	}
+	style.Attr ^= tcell.AttrReverse
+	w.SetCell(runesColumn(line, t.caret.X), t.caret.Y, r, nil, style.TCellStyle())
+}
+
+func (t *TextArea) onSetFocusHandler(w *wm.Window, prev wm.OnSetBoolHandler, dst *bool, src bool) {
+	if prev != nil {
+		prev(w, nil, dst, src)
+	}
+	t.invalidate()
+}
+
+// edit replaces the line at the caret by the concatenation of parts and moves
+// the caret to caret.
+func (t *TextArea) edit(c, caret wm.Position, parts ...[]rune) {
+	var line []rune
+	for _, v := range parts {
+		line = append(line, v...)
+	}
+	t.lines[c.Y] = line
+	t.changed(caret)
+}
+
+// join joins lines y and y+1 and moves the caret to caret.
+func (t *TextArea) join(y int, caret wm.Position) {
+	line := append(append([]rune(nil), t.lines[y]...), t.lines[y+1]...)
+	t.lines = append(t.lines[:y], append([][]rune{line}, t.lines[y+2:]...)...)
+	t.changed(caret)
+}
+
+// split splits the line at the caret.
+func (t *TextArea) split() {
+	c := t.caret
+	line := t.lines[c.Y]
+	head := append([]rune(nil), line[:c.X]...)
+	tail := append([]rune(nil), line[c.X:]...)
+	t.lines = append(t.lines[:c.Y], append([][]rune{head, tail}, t.lines[c.Y+1:]...)...)
+	t.changed(wm.Position{Y: c.Y + 1})
+}
+
+func (t *TextArea) changed(caret wm.Position) {
+	t.BeginUpdate()
+	t.measure()
+	t.updateScrollBars()
+	t.invalidate()
+	t.caret = wm.Position{X: -1}
+	t.SetCaret(caret)
+	t.EndUpdate()
+	if f := t.onChange; f != nil {
+		f(t.Lines())
+	}
+}
+
+func (t *TextArea) invalidate() {
+	t.InvalidateClientArea(wm.Rectangle{Position: t.Origin(), Size: t.ClientSize()})
+}
+
+func (t *TextArea) measure() {
+	t.maxWidth = 0
+	for _, v := range t.lines {
+		t.maxWidth = mathutil.Max(t.maxWidth, runesColumn(v, len(v)))
+	}
+}
+
+// moveVertically moves the caret by dy lines trying to keep its column.
+func (t *TextArea) moveVertically(dy int) {
+	c := t.caret
+	col := runesColumn(t.lines[c.Y], c.X)
+	y := mathutil.Min(mathutil.Max(c.Y+dy, 0), len(t.lines)-1)
+	t.SetCaret(wm.Position{X: runesIndex(t.lines[y], col), Y: y})
+}
+
+// scroll makes the caret visible.
+func (t *TextArea) scroll() {
+	sz := t.ClientSize()
+	if sz.IsZero() {
+		return
+	}
+
+	col := runesColumn(t.lines[t.caret.Y], t.caret.X)
+	o := t.Origin()
+	switch {
+	case col < o.X:
+		o.X = col
+	case col >= o.X+sz.Width:
+		o.X = col - sz.Width + 1
+	}
+	switch y := t.caret.Y; {
+	case y < o.Y:
+		o.Y = y
+	case y >= o.Y+sz.Height:
+		o.Y = y - sz.Height + 1
+	}
+	t.SetOrigin(o)
+}
+
+// ----------------------------------------------------------------------------
+
+// Caret returns the position of the caret. Caret().Y is the line index and
+// Caret().X is the index of the rune in that line.
+func (t *TextArea) Caret() wm.Position { return t.caret }
+
+// Lines returns the edited text.
+func (t *TextArea) Lines() []string {
+	r := make([]string, len(t.lines))
+	for i, v := range t.lines {
+		r[i] = string(v)
+	}
+	return r
+}
+
+// OnChange sets the function invoked when the text changes, replacing any
+// previously set function. Passing nil removes it.
+func (t *TextArea) OnChange(f func(lines []string)) { t.onChange = f }
+
+// SetCaret moves the caret to p. Values outside of the text are clamped.
+func (t *TextArea) SetCaret(p wm.Position) {
+	p.Y = mathutil.Min(mathutil.Max(p.Y, 0), len(t.lines)-1)
+	p.X = mathutil.Min(mathutil.Max(p.X, 0), len(t.lines[p.Y]))
+	if t.caret == p {
+		return
+	}
+
+	t.BeginUpdate()
+	t.caret = p
+	t.scroll()
+	t.invalidate()
+	t.EndUpdate()
+}
+
+// SetLines sets the edited text and moves the caret to its beginning.
+func (t *TextArea) SetLines(lines []string) {
+	t.lines = t.lines[:0]
+	for _, v := range lines {
+		t.lines = append(t.lines, []rune(v))
+	}
+	if len(t.lines) == 0 {
+		t.lines = append(t.lines, nil)
+	}
+	t.changed(wm.Position{})
+}
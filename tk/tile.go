@@ -0,0 +1,137 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+)
+
+// tileSpans partitions total into n spans of sizes differing by at most one
+// and returns their sizes. Any remainder is distributed to the first spans.
+func tileSpans(total, n int) []int {
+	if n <= 0 {
+		return nil
+	}
+
+	total = mathutil.Max(total, 0)
+	r := make([]int, n)
+	for i := range r {
+		r[i] = total / n
+		if i < total%n {
+			r[i]++
+		}
+	}
+	return r
+}
+
+// tileChildren returns the visible windows of children which are still child
+// windows of parent.
+func tileChildren(parent *wm.Window, children []*wm.Window) (r []*wm.Window) {
+	m := map[*wm.Window]bool{}
+	for _, v := range parent.ChildList() {
+		m[v] = true
+	}
+	for _, v := range children {
+		if m[v] && v.Visible() {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// tile partitions the client area of parent into a rows by cols grid and
+// places children into its cells in row major order. Non positive rows or cols
+// are computed from the number of children.
+func tile(parent *wm.Window, rows, cols int, children []*wm.Window) {
+	children = tileChildren(parent, children)
+	n := len(children)
+	if n == 0 {
+		return
+	}
+
+	switch {
+	case rows <= 0 && cols <= 0:
+		return
+	case rows <= 0:
+		rows = (n + cols - 1) / cols
+	case cols <= 0:
+		cols = (n + rows - 1) / rows
+	}
+
+	sz := parent.ClientSize()
+	o := parent.Origin()
+	heights := tileSpans(sz.Height, rows)
+	widths := tileSpans(sz.Width, cols)
+	parent.BeginUpdate()
+	y := o.Y
+	for row, h := range heights {
+		x := o.X
+		for col, w := range widths {
+			i := row*cols + col
+			if i >= n {
+				break
+			}
+
+			c := children[i]
+			c.SetRectangle(wm.Rectangle{Position: wm.Position{X: x, Y: y}, Size: wm.Size{Width: w, Height: h}})
+			x += w
+		}
+		y += h
+	}
+	parent.EndUpdate()
+}
+
+// setTile lays out children using tile and repeats that whenever the client
+// area size of parent changes.
+func setTile(parent *wm.Window, rows, cols int, children []*wm.Window) {
+	children = append([]*wm.Window(nil), children...)
+	tile(parent, rows, cols, children)
+	parent.OnSetClientSize(func(w *wm.Window, prev wm.OnSetSizeHandler, dst *wm.Size, src wm.Size) {
+		if prev != nil {
+			prev(w, nil, dst, src)
+		}
+		tile(parent, rows, cols, children)
+	}, nil)
+}
+
+// ----------------------------------------------------------------------------
+
+// Grid partitions the client area of parent into a grid of rows by cols
+// equally sized cells and sets the position and size of the child windows of
+// parent in children to fill the cells in row major order. Cell sizes differ
+// by at most one, any remainder goes to the first rows and columns. Hidden
+// and closed windows are skipped, windows not fitting into the grid are left
+// untouched.
+//
+// The layout is computed again whenever the client area size of parent
+// changes. Every call of Grid, TileHorizontal or TileVertical installs a new
+// OnSetClientSize handler of parent.
+//
+// Grid must be called only directly from an event handler goroutine or from
+// a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func Grid(parent *wm.Window, rows, cols int, children []*wm.Window) {
+	if rows <= 0 || cols <= 0 {
+		return
+	}
+
+	setTile(parent, rows, cols, children)
+}
+
+// TileHorizontal places the child windows of parent in children side by side,
+// from left to right, so they fill the client area of parent. It's otherwise
+// like Grid with one row and as many columns as there are visible children.
+func TileHorizontal(parent *wm.Window, children []*wm.Window) {
+	setTile(parent, 1, 0, children)
+}
+
+// TileVertical places the child windows of parent in children one above
+// another, from top to bottom, so they fill the client area of parent. It's
+// otherwise like Grid with one column and as many rows as there are visible
+// children.
+func TileVertical(parent *wm.Window, children []*wm.Window) {
+	setTile(parent, 0, 1, children)
+}
@@ -0,0 +1,173 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"time"
+
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// DefaultTooltipDelay is the initial delay of a Tooltip.
+const DefaultTooltipDelay = 750 * time.Millisecond
+
+// tooltipPosition returns the position of a tooltip of size sz shown for the
+// mouse at p within an area of size area. The tooltip is placed below and to
+// the right of the mouse, but it's moved to stay within the area.
+func tooltipPosition(p wm.Position, sz, area wm.Size) wm.Position {
+	r := wm.Position{X: p.X + 1, Y: p.Y + 1}
+	if r.Y+sz.Height > area.Height {
+		r.Y = p.Y - sz.Height
+	}
+	r.X = mathutil.Max(0, mathutil.Min(r.X, area.Width-sz.Width))
+	r.Y = mathutil.Max(0, r.Y)
+	return r
+}
+
+// Tooltip shows a text in a small borderless window near the mouse after the
+// mouse rests over a window for a while. The text is removed when the mouse
+// leaves the window or when the window is clicked.
+//
+// Tooltip methods must be called only directly from an event handler
+// goroutine or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type Tooltip struct {
+	*wm.Window               // Window having the tooltip.
+	closed     bool          //
+	delay      time.Duration //
+	mouse      wm.Position   // Last mouse screen position over the window.
+	popup      *wm.Window    // Shown tooltip, if any.
+	text       string        //
+	timer      *wm.Timer     //
+}
+
+// NewTooltip sets the tooltip of w to text and returns the resulting Tooltip.
+// The tooltip is shown after the mouse rests over w for DefaultTooltipDelay.
+//
+// NewTooltip must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewTooltip(w *wm.Window, text string) *Tooltip {
+	t := &Tooltip{Window: w, delay: DefaultTooltipDelay, text: text}
+	w.OnClick(t.onClickHandler, nil)
+	w.OnClickBorder(t.onClickHandler, nil)
+	w.OnClose(t.onCloseHandler, nil)
+	w.OnDrag(t.onClickHandler, nil)
+	w.OnDragBorder(t.onClickHandler, nil)
+	w.OnMouseEnter(t.onMouseMoveHandler, nil)
+	w.OnMouseLeave(t.onMouseLeaveHandler, nil)
+	w.OnMouseMove(t.onMouseMoveHandler, nil)
+	w.OnMouseMoveBorder(t.onMouseMoveHandler, nil)
+	return t
+}
+
+func (t *Tooltip) onClickHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	t.Hide()
+	return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+}
+
+func (t *Tooltip) onCloseHandler(w *wm.Window, prev wm.OnCloseHandler) {
+	if prev != nil {
+		prev(w, nil)
+	}
+	t.Hide()
+	t.closed = true
+}
+
+func (t *Tooltip) onMouseLeaveHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	t.Hide()
+	return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+}
+
+// onMouseMoveHandler restarts the delay unless the tooltip is already shown.
+func (t *Tooltip) onMouseMoveHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	t.mouse = screenPos
+	if t.popup == nil && t.text != "" && button&(tcell.WheelUp|tcell.WheelDown|tcell.WheelLeft|tcell.WheelRight) == 0 {
+		t.stopTimer()
+		t.timer = wm.App.AfterFunc(t.delay, t.show)
+	}
+	return prev != nil && prev(w, nil, button, screenPos, winPos, mods)
+}
+
+// show pops up the tooltip window near the mouse.
+func (t *Tooltip) show() {
+	t.timer = nil
+	if t.closed || t.popup != nil || t.text == "" {
+		return
+	}
+
+	r := t.Desktop().Root()
+	sz := wm.Size{Width: runewidth.StringWidth(t.text) + 2, Height: 1}
+	p := tooltipPosition(t.mouse.Sub(r.ClientPosition()), sz, r.ClientSize()).Add(r.Origin())
+	r.BeginUpdate()
+	t.popup = borderless(r.NewChild(wm.Rectangle{}))
+	t.popup.SetRectangle(wm.Rectangle{Position: p, Size: sz}) // A window is never smaller than its borders.
+	style := t.ClientAreaStyle()
+	style.Attr ^= tcell.AttrReverse
+	t.popup.SetClientAreaStyle(style)
+	t.popup.OnPaintClientArea(t.onPaintClientAreaHandler, nil)
+	r.EndUpdate()
+}
+
+func (t *Tooltip) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	w.Print(1, 0, w.ClientAreaStyle(), t.text)
+}
+
+func (t *Tooltip) stopTimer() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// ----------------------------------------------------------------------------
+
+// Delay returns how long the mouse must rest over the window before the
+// tooltip is shown.
+func (t *Tooltip) Delay() time.Duration { return t.delay }
+
+// Hide removes the tooltip, if shown, and cancels any pending showing of it.
+func (t *Tooltip) Hide() {
+	t.stopTimer()
+	if p := t.popup; p != nil {
+		t.popup = nil
+		p.ForceClose()
+	}
+}
+
+// SetDelay sets how long the mouse must rest over the window before the
+// tooltip is shown. Non positive values are handled like a one millisecond
+// delay.
+func (t *Tooltip) SetDelay(d time.Duration) {
+	if d <= 0 {
+		d = time.Millisecond
+	}
+	t.delay = d
+}
+
+// SetText sets the tooltip text. Setting an empty text disables the tooltip.
+func (t *Tooltip) SetText(s string) {
+	if t.text == s {
+		return
+	}
+
+	t.text = s
+	if t.popup != nil {
+		t.Hide()
+		if s != "" {
+			t.show()
+		}
+	}
+}
+
+// Text returns the tooltip text.
+func (t *Tooltip) Text() string { return t.text }
@@ -0,0 +1,364 @@
+// Copyright 2016 The WM Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tk
+
+import (
+	"strings"
+
+	"github.com/cznic/mathutil"
+	"github.com/cznic/wm"
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+)
+
+// TreeNode is a node of a tree shown by a TreeView.
+type TreeNode struct {
+	Children []*TreeNode // Child nodes, if any.
+	Label    string      // Text shown.
+	expanded bool        //
+}
+
+// Expanded returns whether the children of n are shown.
+func (n *TreeNode) Expanded() bool { return n.expanded }
+
+// treeRow is a visible row of a TreeView.
+type treeRow struct {
+	last   []bool    // Whether the node and its ancestors, except the root, are the last child.
+	node   *TreeNode //
+	parent int       // Row index of the parent node, -1 for the root.
+}
+
+// treeRows returns the rows of the expanded part of the tree rooted at n.
+func treeRows(n *TreeNode) (r []treeRow) {
+	if n == nil {
+		return nil
+	}
+
+	var f func(*TreeNode, int, []bool)
+	f = func(n *TreeNode, parent int, last []bool) {
+		i := len(r)
+		r = append(r, treeRow{last, n, parent})
+		if !n.expanded {
+			return
+		}
+
+		for j, v := range n.Children {
+			f(v, i, append(last[:len(last):len(last)], j == len(n.Children)-1))
+		}
+	}
+	f(n, -1, nil)
+	return r
+}
+
+// treeIndicator returns the expand indicator of n.
+func treeIndicator(n *TreeNode) string {
+	switch {
+	case len(n.Children) == 0:
+		return ""
+	case n.expanded:
+		return "[-]"
+	default:
+		return "[+]"
+	}
+}
+
+// treeIndent returns the indentation guides of r.
+func treeIndent(r treeRow) string {
+	if len(r.last) == 0 {
+		return ""
+	}
+
+	var a []string
+	for _, v := range r.last[:len(r.last)-1] {
+		switch {
+		case v:
+			a = append(a, "  ")
+		default:
+			a = append(a, "│ ")
+		}
+	}
+	switch {
+	case r.last[len(r.last)-1]:
+		a = append(a, "└─")
+	default:
+		a = append(a, "├─")
+	}
+	return strings.Join(a, "")
+}
+
+// treeText returns the text shown for r.
+func treeText(r treeRow) string {
+	s := treeIndent(r) + treeIndicator(r.node)
+	if s == "" {
+		return r.node.Label
+	}
+
+	return s + " " + r.node.Label
+}
+
+// TreeView shows a tree of nodes, one visible node per line. Nodes having
+// children can be expanded and collapsed by clicking their [+]/[-] indicator
+// or using the Right and Left keys. It scrolls its content using the embedded
+// View.
+//
+// TreeView methods must be called only directly from an event handler
+// goroutine or from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+type TreeView struct {
+	*View                    // Underlying view.
+	maxWidth int             // Width of the widest row.
+	onSelect func(*TreeNode) //
+	root     *TreeNode       //
+	rows     []treeRow       // Visible rows.
+	selected int             // -1 if there are no rows.
+}
+
+// NewTreeView configures w to show the tree rooted at root and returns the
+// resulting TreeView. The root node, if any, is selected.
+//
+// NewTreeView must be called only directly from an event handler goroutine or
+// from a function that was enqueued using wm.Application.Post or
+// wm.Application.PostWait.
+func NewTreeView(w *wm.Window, root *TreeNode) *TreeView {
+	t := &TreeView{selected: -1}
+	t.View = NewView(w, t)
+	w.OnClick(t.onClickHandler, nil)
+	w.OnKey(t.onKeyHandler, nil)
+	w.OnPaintClientArea(t.onPaintClientAreaHandler, nil)
+	t.SetRoot(root)
+	return t
+}
+
+// Metrics implements Meter.
+func (t *TreeView) Metrics(viewport wm.Rectangle) wm.Size {
+	return wm.Size{Width: t.maxWidth, Height: len(t.rows)}
+}
+
+func (t *TreeView) onClickHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
+	if prev != nil && prev(w, nil, button, screenPos, winPos, mods) {
+		return true
+	}
+
+	if button != tcell.Button1 {
+		return false
+	}
+
+	if winPos.Y < 0 || winPos.Y >= len(t.rows) {
+		return true
+	}
+
+	r := t.rows[winPos.Y]
+	x := runewidth.StringWidth(treeIndent(r))
+	if len(r.node.Children) != 0 && winPos.X >= x && winPos.X < x+3 {
+		t.toggle(r.node)
+		return true
+	}
+
+	t.selectRow(winPos.Y)
+	return true
+}
+
+func (t *TreeView) onKeyHandler(w *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+	if prev != nil && prev(w, nil, key, mod, r) {
+		return true
+	}
+
+	page := mathutil.Max(1, t.ClientSize().Height)
+	switch key {
+	case tcell.KeyDown:
+		t.selectRow(t.selected + 1)
+	case tcell.KeyEnd:
+		t.selectRow(len(t.rows) - 1)
+	case tcell.KeyHome:
+		t.selectRow(0)
+	case tcell.KeyLeft:
+		if t.selected < 0 {
+			break
+		}
+
+		switch r := t.rows[t.selected]; {
+		case r.node.expanded:
+			t.Collapse(r.node)
+		case r.parent >= 0:
+			t.selectRow(r.parent)
+		}
+	case tcell.KeyPgDn:
+		t.selectRow(t.selected + page)
+	case tcell.KeyPgUp:
+		t.selectRow(t.selected - page)
+	case tcell.KeyRight:
+		if t.selected < 0 {
+			break
+		}
+
+		switch n := t.rows[t.selected].node; {
+		case len(n.Children) == 0:
+			// nop
+		case !n.expanded:
+			t.Expand(n)
+		default:
+			t.selectRow(t.selected + 1)
+		}
+	case tcell.KeyUp:
+		t.selectRow(t.selected - 1)
+	default:
+		return false
+	}
+	return true
+}
+
+func (t *TreeView) onPaintClientAreaHandler(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
+	if prev != nil {
+		prev(w, nil, ctx)
+	}
+
+	cpY := w.ClientPosition().Y
+	width := mathutil.Max(t.maxWidth, w.Origin().X+w.ClientSize().Width)
+	for i := 0; i < ctx.Height; i++ {
+		y := ctx.Y - cpY + i
+		if y >= len(t.rows) {
+			break
+		}
+
+		style := w.ClientAreaStyle()
+		if y == t.selected {
+			style.Attr ^= tcell.AttrReverse
+			w.Print(0, y, style, strings.Repeat(" ", width))
+		}
+		w.Print(0, y, style, treeText(t.rows[y]))
+	}
+}
+
+func (t *TreeView) invalidateRow(i int) {
+	if i < 0 {
+		return
+	}
+
+	t.InvalidateClientArea(wm.Rectangle{Position: wm.Position{X: t.Origin().X, Y: i}, Size: wm.Size{Width: t.ClientSize().Width, Height: 1}})
+}
+
+// scroll makes the selected row visible.
+func (t *TreeView) scroll() {
+	h := t.ClientSize().Height
+	if h <= 0 || t.selected < 0 {
+		return
+	}
+
+	o := t.Origin()
+	switch y := t.selected; {
+	case y < o.Y:
+		o.Y = y
+	case y >= o.Y+h:
+		o.Y = y - h + 1
+	}
+	t.SetOrigin(o)
+}
+
+// selectRow selects the row at index i and scrolls the view to make it
+// visible. Values of i outside of the rows are clamped.
+func (t *TreeView) selectRow(i int) {
+	if len(t.rows) == 0 {
+		return
+	}
+
+	i = mathutil.Min(mathutil.Max(i, 0), len(t.rows)-1)
+	if t.selected == i {
+		return
+	}
+
+	t.BeginUpdate()
+	t.invalidateRow(t.selected)
+	t.selected = i
+	t.invalidateRow(i)
+	t.scroll()
+	t.EndUpdate()
+	if f := t.onSelect; f != nil {
+		f(t.rows[i].node)
+	}
+}
+
+// setExpanded expands or collapses n. The selected node is kept if it remains
+// visible, otherwise its visible ancestor gets selected.
+func (t *TreeView) setExpanded(n *TreeNode, v bool) {
+	if n.expanded == v || len(n.Children) == 0 {
+		n.expanded = v
+		return
+	}
+
+	var sel *TreeNode
+	if t.selected >= 0 {
+		sel = t.rows[t.selected].node
+	}
+	n.expanded = v
+	t.BeginUpdate()
+	t.update()
+	t.selected = -1
+	for i, r := range t.rows {
+		if r.node == sel {
+			t.selected = i
+			break
+		}
+	}
+	if t.selected < 0 {
+		for i, r := range t.rows {
+			if r.node == n {
+				t.selectRow(i)
+				break
+			}
+		}
+	}
+	t.scroll()
+	t.EndUpdate()
+}
+
+func (t *TreeView) toggle(n *TreeNode) { t.setExpanded(n, !n.expanded) }
+
+// update recomputes the visible rows and invalidates the client area.
+func (t *TreeView) update() {
+	t.rows = treeRows(t.root)
+	t.maxWidth = 0
+	for _, v := range t.rows {
+		t.maxWidth = mathutil.Max(t.maxWidth, runewidth.StringWidth(treeText(v)))
+	}
+	t.updateScrollBars()
+	t.InvalidateClientArea(wm.Rectangle{Position: t.Origin(), Size: t.ClientSize()})
+}
+
+// ----------------------------------------------------------------------------
+
+// Collapse hides the children of n. If the selected node gets hidden, n is
+// selected instead.
+func (t *TreeView) Collapse(n *TreeNode) { t.setExpanded(n, false) }
+
+// Expand shows the children of n. The children are visible only when all the
+// ancestors of n are expanded as well.
+func (t *TreeView) Expand(n *TreeNode) { t.setExpanded(n, true) }
+
+// OnSelect sets the function invoked when the selected node changes,
+// replacing any previously set function. Passing nil removes it.
+func (t *TreeView) OnSelect(f func(*TreeNode)) { t.onSelect = f }
+
+// Root returns the root node of the tree.
+func (t *TreeView) Root() *TreeNode { return t.root }
+
+// Selected returns the selected node or nil if there are no nodes.
+func (t *TreeView) Selected() *TreeNode {
+	if t.selected < 0 {
+		return nil
+	}
+
+	return t.rows[t.selected].node
+}
+
+// SetRoot sets the tree shown by the tree view. The root node, if not nil, is
+// selected.
+func (t *TreeView) SetRoot(n *TreeNode) {
+	t.BeginUpdate()
+	t.root = n
+	t.selected = -1
+	t.update()
+	t.selectRow(0)
+	t.EndUpdate()
+}
@@ -5,9 +5,13 @@
 package tk
 
 import (
+	"time"
+	"unicode/utf8"
+
 	"github.com/cznic/mathutil"
 	"github.com/cznic/wm"
 	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
 )
 
 // Meter provides metrics of content displayed in the client area of a window.
@@ -20,6 +24,103 @@ type Meter interface {
 	Metrics(viewport wm.Rectangle) wm.Size
 }
 
+// lineWidth returns the display width of b, expanding tabs to multiples of
+// tabWidth. Tabs are not expanded if tabWidth < 1.
+func lineWidth(b []byte, tabWidth int) (n int) {
+	for len(b) != 0 {
+		r, sz := utf8.DecodeRune(b)
+		b = b[sz:]
+		switch {
+		case r == '\t' && tabWidth > 0:
+			n += tabWidth - n%tabWidth
+		default:
+			n += runewidth.RuneWidth(r)
+		}
+	}
+	return n
+}
+
+type lineMeter wm.Size
+
+// NewLineMeter returns a Meter of text content consisting of lines. The
+// display width of the widest line, with tabs expanded to multiples of
+// tabWidth, and the number of lines are computed once by NewLineMeter.
+func NewLineMeter(lines [][]byte, tabWidth int) Meter {
+	m := lineMeter{Height: len(lines)}
+	for _, v := range lines {
+		m.Width = mathutil.Max(m.Width, lineWidth(v, tabWidth))
+	}
+	return m
+}
+
+// Metrics implements Meter.
+func (m lineMeter) Metrics(viewport wm.Rectangle) wm.Size { return wm.Size(m) }
+
+// wrapLine splits b into lines not wider than width cells. Tabs are expanded
+// to spaces up to the next multiple of tabWidth, unless tabWidth < 1.
+func wrapLine(b []byte, width, tabWidth int) (r [][]byte) {
+	width = mathutil.Max(width, 1)
+	var line []byte
+	col, x := 0, 0 // Column in b, column in line.
+	add := func(c []byte, w int) {
+		if x+w > width && x != 0 {
+			r = append(r, line)
+			line, x = nil, 0
+		}
+		line = append(line, c...)
+		x += w
+		col += w
+	}
+	for len(b) != 0 {
+		c, sz := utf8.DecodeRune(b)
+		switch {
+		case c == '\t' && tabWidth > 0:
+			for n := tabWidth - col%tabWidth; n > 0; n-- {
+				add([]byte{' '}, 1)
+			}
+		default:
+			add(b[:sz], runewidth.RuneWidth(c))
+		}
+		b = b[sz:]
+	}
+	return append(r, line)
+}
+
+// WrapMeter is a Meter of text content consisting of lines wrapped at the
+// viewport width. Use it together with View.SetWrap.
+type WrapMeter struct {
+	lines    [][]byte
+	tabWidth int
+	width    int      // Width of the cached wrapped lines.
+	wrapped  [][]byte // Cached wrapped lines.
+}
+
+// NewWrapMeter returns a newly created WrapMeter of lines. Tabs are expanded
+// to spaces up to the next multiple of tabWidth, unless tabWidth < 1.
+func NewWrapMeter(lines [][]byte, tabWidth int) *WrapMeter {
+	return &WrapMeter{lines: lines, tabWidth: tabWidth, width: -1}
+}
+
+// Metrics implements Meter.
+func (m *WrapMeter) Metrics(viewport wm.Rectangle) wm.Size {
+	return wm.Size{Width: viewport.Width, Height: len(m.Wrap(viewport.Width))}
+}
+
+// Wrap returns the content lines wrapped at width. The result of the most
+// recent call is cached.
+func (m *WrapMeter) Wrap(width int) [][]byte {
+	if width == m.width {
+		return m.wrapped
+	}
+
+	m.width = width
+	m.wrapped = nil
+	for _, v := range m.lines {
+		m.wrapped = append(m.wrapped, wrapLine(v, width, m.tabWidth)...)
+	}
+	return m.wrapped
+}
+
 // View displays content possibly overflowing the size of its client area.
 //
 // View methods must be called only directly from an event handler goroutine or
@@ -27,6 +128,12 @@ type Meter interface {
 // wm.Application.PostWait.
 type View struct {
 	*wm.Window     // Underlying window.
+	autoHide       time.Duration
+	autoHideTimer  *wm.Timer
+	closed         bool
+	follow         bool
+	following      bool // Follow mode is enabled and the view shows the end of the content.
+	hidden         bool // Scrollbars are auto hidden.
 	hs             *Scrollbar
 	hsEnabled      bool
 	hsShown        bool
@@ -38,6 +145,8 @@ type View struct {
 	vs             *Scrollbar
 	vsEnabled      bool
 	vsShown        bool
+	wheelStep      int
+	wrap           bool
 }
 
 // NewView configures w to show scrollbars when content, measured using the
@@ -48,8 +157,12 @@ type View struct {
 // a function that was enqueued using wm.Application.Post or
 // wm.Application.PostWait.
 func NewView(w *wm.Window, meter Meter) *View {
+	style := w.Style().Scrollbar
+	if style.IsZero() {
+		style = wm.Style{Background: tcell.ColorSilver, Foreground: tcell.ColorBlack}
+	}
 	vs := NewScrollbar(w)
-	vs.SetStyle(wm.Style{Background: tcell.ColorSilver, Foreground: tcell.ColorBlack})
+	vs.SetStyle(style)
 	hs := NewScrollbar(w)
 	hs.SetStyle(vs.Style())
 	v := &View{
@@ -59,6 +172,7 @@ func NewView(w *wm.Window, meter Meter) *View {
 		meter:     meter,
 		vs:        vs,
 		vsEnabled: true,
+		wheelStep: 1,
 	}
 	hs.OnClickDecrement(v.onClickDecrementHS, nil)
 	hs.OnClickDecrementPage(v.onClickDecrementHSPage, nil)
@@ -87,6 +201,32 @@ func (v *View) onCloseHandler(w *wm.Window, prev wm.OnCloseHandler) {
 	}
 	v.onSetHSEnabled.Clear()
 	v.onSetVSEnabled.Clear()
+	v.closed = true
+	if t := v.autoHideTimer; t != nil {
+		t.Stop()
+		v.autoHideTimer = nil
+	}
+}
+
+// activity shows auto hidden scrollbars and restarts the auto hide timer.
+func (v *View) activity() {
+	if v.updating || v.autoHide == 0 {
+		return
+	}
+
+	if t := v.autoHideTimer; t != nil {
+		t.Stop()
+	}
+	v.autoHideTimer = wm.App.AfterFunc(v.autoHide, func() {
+		if v.closed {
+			return
+		}
+
+		v.autoHideTimer = nil
+		v.hidden = true
+		v.updateScrollBars()
+	})
+	v.hidden = false
 }
 
 func (v *View) onMouseMoveHandler(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
@@ -94,30 +234,31 @@ func (v *View) onMouseMoveHandler(w *wm.Window, prev wm.OnMouseHandler, button t
 		return true
 	}
 
+	if mods&tcell.ModCtrl != 0 {
+		switch button {
+		case tcell.WheelUp:
+			button = tcell.WheelLeft
+		case tcell.WheelDown:
+			button = tcell.WheelRight
+		}
+	}
+
+	o := v.Origin()
 	switch button {
 	case tcell.WheelLeft:
-		o := v.Origin()
-		o.X = mathutil.Max(0, o.X-1)
-		v.SetOrigin(o)
-		return true
+		o.X = mathutil.Max(0, o.X-v.wheelStep)
 	case tcell.WheelRight:
-		o := v.Origin()
-		o.X++
-		v.SetOrigin(o)
-		return true
+		o.X += v.wheelStep
 	case tcell.WheelUp:
-		o := v.Origin()
-		o.Y = mathutil.Max(0, o.Y-1)
-		v.SetOrigin(o)
-		return true
+		o.Y = mathutil.Max(0, o.Y-v.wheelStep)
 	case tcell.WheelDown:
-		o := v.Origin()
-		o.Y++
-		v.SetOrigin(o)
-		return true
+		o.Y += v.wheelStep
 	default:
 		return false
 	}
+
+	v.SetOrigin(o)
+	return true
 }
 
 func (v *View) onClickDecrementHSPage(w *wm.Window, prev wm.OnMouseHandler, button tcell.ButtonMask, screenPos, winPos wm.Position, mods tcell.ModMask) bool {
@@ -125,9 +266,7 @@ func (v *View) onClickDecrementHSPage(w *wm.Window, prev wm.OnMouseHandler, butt
 		return false
 	}
 
-	o := v.Origin()
-	o.X = mathutil.Max(0, o.X-v.ClientSize().Width)
-	v.SetOrigin(o)
+	v.PageLeft()
 	return true
 }
 
@@ -136,9 +275,7 @@ func (v *View) onClickIncrementHSPage(w *wm.Window, prev wm.OnMouseHandler, butt
 		return false
 	}
 
-	o := v.Origin()
-	o.X += v.ClientSize().Width
-	v.SetOrigin(o)
+	v.PageRight()
 	return true
 }
 
@@ -148,7 +285,7 @@ func (v *View) onSetHandlePositionHS(w *wm.Window, prev wm.OnSetIntHandler, dst
 		src = *dst
 	}
 
-	if !v.hs.draggingHandle || v.updating {
+	if !v.hs.draggingHandle && !v.hs.jumping || v.updating {
 		return
 	}
 
@@ -156,12 +293,7 @@ func (v *View) onSetHandlePositionHS(w *wm.Window, prev wm.OnSetIntHandler, dst
 		return
 	}
 
-	if src+v.hs.HandleSize() == v.hs.Size().Width-2 {
-		v.SetOrigin(wm.Position{X: v.metrics.Width - v.ClientArea().Width, Y: v.Origin().Y})
-		return
-	}
-
-	x := (2*v.metrics.Width*src - v.metrics.Width) / (2*v.hs.Size().Width - 4)
+	x := handleOrigin(src, v.hs.HandleSize(), v.ClientArea().Width, v.metrics.Width, v.hs.Size().Width-2)
 	v.SetOrigin(wm.Position{X: x, Y: v.Origin().Y})
 }
 
@@ -171,7 +303,7 @@ func (v *View) onSetHandlePositionVS(w *wm.Window, prev wm.OnSetIntHandler, dst
 		src = *dst
 	}
 
-	if !v.vs.draggingHandle || v.updating {
+	if !v.vs.draggingHandle && !v.vs.jumping || v.updating {
 		return
 	}
 
@@ -179,12 +311,7 @@ func (v *View) onSetHandlePositionVS(w *wm.Window, prev wm.OnSetIntHandler, dst
 		return
 	}
 
-	if src+v.vs.HandleSize() == v.vs.Size().Height-2 {
-		v.SetOrigin(wm.Position{X: v.Origin().X, Y: v.metrics.Height - v.ClientArea().Height})
-		return
-	}
-
-	y := (2*v.metrics.Height*src - v.metrics.Height) / (2*v.vs.Size().Height - 4)
+	y := handleOrigin(src, v.vs.HandleSize(), v.ClientArea().Height, v.metrics.Height, v.vs.Size().Height-2)
 	v.SetOrigin(wm.Position{X: v.Origin().X, Y: y})
 }
 
@@ -265,6 +392,9 @@ func (v *View) onPaintBorderBottomHandler(w *wm.Window, prev wm.OnPaintHandler,
 }
 
 func (v *View) onSetOriginHandler(w *wm.Window, prev wm.OnSetPositionHandler, dst *wm.Position, src wm.Position) {
+	if v.wrap {
+		src.X = 0
+	}
 	if w := v.metrics.Width; w >= 0 {
 		src.X = mathutil.Max(0, mathutil.Min(src.X, w-v.ClientSize().Width))
 	}
@@ -277,6 +407,10 @@ func (v *View) onSetOriginHandler(w *wm.Window, prev wm.OnSetPositionHandler, ds
 		src = *dst
 	}
 	*dst = src
+	if v.follow && !v.updating {
+		v.following = v.metrics.Height < 0 || src.Y >= v.metrics.Height-v.ClientSize().Height
+	}
+	v.activity()
 	v.updateScrollBars()
 }
 
@@ -285,6 +419,7 @@ func (v *View) onSetClientSizeHandler(w *wm.Window, prev wm.OnSetSizeHandler, ds
 		prev(w, nil, dst, src)
 	}
 	*dst = src
+	v.activity()
 	v.updateScrollBars()
 }
 
@@ -314,6 +449,20 @@ func checkVS(sz wm.Size, viewport wm.Rectangle) bool {
 	return viewport.Height >= 2 && (viewport.Y != 0 && sz.Height > 0 || sz.Height > viewport.Height || sz.Height < 0)
 }
 
+// fitContent returns the client size fitting content of size metrics, capped
+// by max. Negative, ie. unknown, metrics dimensions keep the respective
+// dimension of current.
+func fitContent(metrics, current, max wm.Size) wm.Size {
+	r := current
+	if metrics.Width >= 0 {
+		r.Width = mathutil.Min(metrics.Width, max.Width)
+	}
+	if metrics.Height >= 0 {
+		r.Height = mathutil.Min(metrics.Height, max.Height)
+	}
+	return r
+}
+
 func (v *View) updateScrollBars() {
 	if v.updating {
 		return
@@ -333,12 +482,25 @@ func (v *View) updateScrollBars() {
 	viewport.Position = v.Origin()
 	v.metrics = v.meter.Metrics(viewport)
 	var showHS, showVS bool
-	if showHS = v.hsEnabled && checkHS(v.metrics, viewport); showHS {
-		viewport.Height--
-		showVS = v.vsEnabled && checkVS(v.metrics, viewport)
-	} else if showVS = v.vsEnabled && checkVS(v.metrics, viewport); showVS {
-		viewport.Width--
-		showHS = v.hsEnabled && checkHS(v.metrics, viewport)
+	switch {
+	case v.wrap:
+		// The wrapped content height depends on the viewport width.
+		if showVS = v.vsEnabled && !v.hidden && checkVS(v.metrics, viewport); showVS {
+			viewport.Width--
+			v.metrics = v.meter.Metrics(viewport)
+		}
+	default:
+		if showHS = v.hsEnabled && checkHS(v.metrics, viewport); showHS {
+			viewport.Height--
+			showVS = v.vsEnabled && checkVS(v.metrics, viewport)
+		} else if showVS = v.vsEnabled && checkVS(v.metrics, viewport); showVS {
+			viewport.Width--
+			showHS = v.hsEnabled && checkHS(v.metrics, viewport)
+		}
+	}
+
+	if v.hidden {
+		showHS, showVS = false, false
 	}
 
 	if showHS {
@@ -363,6 +525,9 @@ func (v *View) updateScrollBars() {
 	v.hsShown = showHS
 	v.vsShown = showVS
 	v.updating = false
+	if v.Following() && v.metrics.Height >= 0 && v.Origin().Y < v.metrics.Height-v.ClientSize().Height {
+		v.End()
+	}
 }
 
 // ----------------------------------------------------------------------------
@@ -407,6 +572,26 @@ func (v *View) OnSetVerticalScrollbarEnabled(h wm.OnSetBoolHandler, finalize fun
 // handler set.
 func (v *View) RemoveOnSetVerticalScrollbarEnabled() { wm.RemoveOnSetBoolHandler(&v.onSetVSEnabled) }
 
+// Following reports whether the view is in follow mode and shows the end of
+// its content.
+func (v *View) Following() bool { return v.follow && v.following }
+
+// SetFollow sets the follow mode of the view. In follow mode the view scrolls
+// to the end of its content whenever the content grows, like tail -f.
+// Scrolling up stops following, scrolling back to the end resumes it.
+// Enabling follow mode scrolls to the end of the content.
+func (v *View) SetFollow(b bool) {
+	if v.follow == b {
+		return
+	}
+
+	v.follow = b
+	v.following = b
+	if b {
+		v.End()
+	}
+}
+
 // Home makes the view show the beginning of its content.
 func (v *View) Home() { v.SetOrigin(wm.Position{}) }
 
@@ -417,6 +602,17 @@ func (v *View) End() {
 	}
 }
 
+// LineEnd makes the view show the right edge of its content. LineEnd does
+// nothing if the content width is unknown.
+func (v *View) LineEnd() {
+	if w := v.metrics.Width; w >= 0 {
+		v.SetOrigin(wm.Position{X: w - v.ClientSize().Width, Y: v.Origin().Y})
+	}
+}
+
+// LineHome makes the view show the left edge of its content.
+func (v *View) LineHome() { v.SetOrigin(wm.Position{Y: v.Origin().Y}) }
+
 // PageDown makes the view show the next page of content.
 func (v *View) PageDown() {
 	o := v.Origin()
@@ -424,9 +620,114 @@ func (v *View) PageDown() {
 	v.SetOrigin(o)
 }
 
+// PageLeft makes the view show the previous page of content to the left.
+func (v *View) PageLeft() {
+	o := v.Origin()
+	o.X = mathutil.Max(0, o.X-v.ClientSize().Width)
+	v.SetOrigin(o)
+}
+
+// PageRight makes the view show the next page of content to the right.
+func (v *View) PageRight() {
+	o := v.Origin()
+	o.X += v.ClientSize().Width
+	v.SetOrigin(o)
+}
+
 // PageUp makes the view show the previous page of content.
 func (v *View) PageUp() {
 	o := v.Origin()
 	o.Y -= v.ClientSize().Height
 	v.SetOrigin(o)
 }
+
+// JumpToClick reports whether clicking the trough of the view scrollbars
+// scrolls to the clicked position.
+func (v *View) JumpToClick() bool { return v.vs.JumpToClick() }
+
+// ScrollbarAutoHide returns the duration after which the scrollbars are
+// hidden if the view is not scrolled or resized. Zero means the scrollbars
+// are never auto hidden.
+func (v *View) ScrollbarAutoHide() time.Duration { return v.autoHide }
+
+// SetScrollbarAutoHide sets the duration after the last scroll or resize of
+// the view after which the scrollbars are hidden. The scrollbars are shown
+// again on the next scroll or resize. Zero disables auto hiding.
+func (v *View) SetScrollbarAutoHide(d time.Duration) {
+	if v.autoHide == d {
+		return
+	}
+
+	v.autoHide = d
+	if d == 0 {
+		if t := v.autoHideTimer; t != nil {
+			t.Stop()
+			v.autoHideTimer = nil
+		}
+		if v.hidden {
+			v.hidden = false
+			v.updateScrollBars()
+		}
+		return
+	}
+
+	v.activity()
+}
+
+// SetWrap sets whether the view wraps its content at the client area width
+// instead of scrolling horizontally. In wrap mode the horizontal scrollbar is
+// not shown, the horizontal origin is always zero and the meter is expected to
+// report the wrapped content height for the viewport width, see WrapMeter.
+func (v *View) SetWrap(b bool) {
+	if v.wrap == b {
+		return
+	}
+
+	v.wrap = b
+	switch {
+	case b:
+		v.LineHome()
+	default:
+		v.updateScrollBars()
+	}
+}
+
+// SizeToContent resizes the window of the view so that its client area fits
+// the content, as reported by the meter for a viewport of size max, but it
+// does not exceed max. Content bigger than max is scrolled as usual. A
+// dimension the meter reports as unknown is left unchanged.
+func (v *View) SizeToContent(max wm.Size) {
+	cur := v.ClientSize()
+	bw := v.BorderLeft() + v.BorderRight()
+	bh := v.BorderTop() + v.BorderBottom()
+	if v.vsShown {
+		cur.Width++
+		bw--
+	}
+	if v.hsShown {
+		cur.Height++
+		bh--
+	}
+	m := v.meter.Metrics(wm.Rectangle{Position: v.Origin(), Size: max})
+	sz := fitContent(m, cur, max)
+	v.SetSize(wm.Size{Width: sz.Width + bw, Height: sz.Height + bh})
+}
+
+// SetWheelStep sets the number of lines or columns the view scrolls per mouse
+// wheel notch. Values less than 1 are treated as 1. The default step is 1.
+// Turning the vertical wheel while holding <Ctrl> scrolls horizontally.
+func (v *View) SetWheelStep(n int) { v.wheelStep = mathutil.Max(1, n) }
+
+// Wrap reports whether the view wraps its content.
+func (v *View) Wrap() bool { return v.wrap }
+
+// WheelStep returns the number of lines or columns the view scrolls per mouse
+// wheel notch.
+func (v *View) WheelStep() int { return v.wheelStep }
+
+// SetJumpToClick sets whether clicking the trough of the view scrollbars
+// scrolls to the clicked position instead of paging by one viewport.
+func (v *View) SetJumpToClick(b bool) {
+	v.hs.SetJumpToClick(b)
+	v.vs.SetJumpToClick(b)
+}
@@ -17,7 +17,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/cznic/mathutil"
 	"github.com/cznic/wm"
 	"github.com/cznic/wm/internal/demoapp"
 	"github.com/cznic/wm/tk"
@@ -46,19 +45,6 @@ func newWindow(parent *wm.Window, x, y int, title string, src []byte) {
 		src = src[:len(src)-1]
 	}
 	a := bytes.Split([]byte(src), nl)
-	max := -1
-	for _, v := range a {
-		var x int
-		for _, c := range v {
-			switch c {
-			case '\t':
-				x += 8 - x%8
-			default:
-				x++
-			}
-		}
-		max = mathutil.Max(max, x)
-	}
 	c.OnPaintClientArea(
 		func(w *wm.Window, prev wm.OnPaintHandler, ctx wm.PaintContext) {
 			if prev != nil {
@@ -76,7 +62,7 @@ func newWindow(parent *wm.Window, x, y int, title string, src []byte) {
 		},
 		nil,
 	)
-	v := tk.NewView(c, meter{max, len(a)})
+	v := tk.NewView(c, tk.NewLineMeter(a, 8))
 	c.OnKey(
 		func(w *wm.Window, prev wm.OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
 			if prev != nil && prev(w, nil, key, mod, r) {
@@ -118,7 +104,7 @@ func setup(d *wm.Desktop) {
 				prev(w, nil, ctx)
 			}
 
-			w.Printf(0, 0, w.ClientAreaStyle(), "%s", help)
+			w.Print(0, 0, w.ClientAreaStyle(), help)
 		}, nil,
 	)
 	app.OnKey(
@@ -209,10 +195,6 @@ func setup(d *wm.Desktop) {
 	}
 }
 
-type meter wm.Size
-
-func (m meter) Metrics(_ wm.Rectangle) wm.Size { return wm.Size(m) }
-
 func main() {
 	flag.Parse()
 	rand.Seed(time.Now().UnixNano())
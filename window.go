@@ -6,6 +6,7 @@ package wm
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cznic/mathutil"
@@ -30,6 +31,43 @@ const (
 	dragLRC
 )
 
+// TitleAlignment determines the horizontal placement of a window title.
+type TitleAlignment int
+
+// Values of TitleAlignment.
+const (
+	TitleLeft TitleAlignment = iota
+	TitleCenter
+	TitleRight
+)
+
+// Edge selects a window edge or corner.
+type Edge int
+
+// Values of Edge.
+const (
+	EdgeLeft Edge = 1 << iota
+	EdgeRight
+	EdgeTop
+	EdgeBottom
+
+	EdgeBottomLeft  = EdgeBottom | EdgeLeft
+	EdgeBottomRight = EdgeBottom | EdgeRight
+	EdgeTopLeft     = EdgeTop | EdgeLeft
+	EdgeTopRight    = EdgeTop | EdgeRight
+)
+
+// dragEdges maps the resizing drag states to edges.
+var dragEdges = [...]Edge{
+	dragRightSize:  EdgeRight,
+	dragLeftSize:   EdgeLeft,
+	dragBottomSize: EdgeBottom,
+	dragULC:        EdgeTopLeft,
+	dragURC:        EdgeTopRight,
+	dragLLC:        EdgeBottomLeft,
+	dragLRC:        EdgeBottomRight,
+}
+
 // Window represents a rectangular area of a screen. A window can have borders
 // on all of its sides and a title.
 //
@@ -41,12 +79,20 @@ type Window struct {
 	borderLeft           int                          // Width.
 	borderRight          int                          // Width.
 	borderTop            int                          // Height.
+	buffer               [][]Cell                     // Client area back buffer. Non nil if buffered.
+	buffering            bool                         // Client area paint goes to buffer.
 	children             []*Window                    // In z-order.
+	clickBubbling        bool                         // Pass unhandled clicks to the parent.
 	clientArea           Rectangle                    // In window coordinates, excludes any borders.
 	closeButton          bool                         // Enable.
+	closing              bool                         // ForceClose in progress.
+	confine              bool                         // Keep within the parent client area.
+	confineFully         bool                         // Confine the whole window, not only the title.
 	ctx                  PaintContext                 // Valid during painting.
 	desktop              *Desktop                     // Which Desktop this window belongs to. Never changes.
 	dragScreenPos0       Position                     // Mouse screen position on drag event.
+	dragSource           *Window                      // Root window only. Window accepting the drag in progress.
+	dragSourcePos0       Position                     // Root window only. Mouse screen position on drag start.
 	dragState            int                          // One of the drag{Pos,RightSize,...} constants,
 	dragWinPos0          Position                     // Window position on drag event.
 	dragWinSize0         Size                         // Window size on drag event.
@@ -54,18 +100,38 @@ type Window struct {
 	dragWindowPos        Position                     // In parent window coordinates.
 	focus                bool                         // Whether this window has focus.
 	focusedWindow        *Window                      // Root window only.
+	hoveredWindow        *Window                      // Root window only. Window under the mouse.
+	keyboardMode         bool                         // BeginKeyboardMove/Resize in progress.
+	maxSize              Size                         // Zero Width or Height means unbounded.
+	maximized            bool                         //
+	minSize              Size                         //
+	minimized            bool                         //
+	noCloseFocus         bool                         // Closing does not transfer the focus.
+	noInput              bool                         // Does not receive mouse, key and focus events.
+	noMove               bool                         // Not movable by the user.
+	noRepaintOnResize    bool                         // Size change invalidates only the borders and the exposed or vacated area.
+	noResize             bool                         // Not resizable by the user.
+	noTitleMaximize      bool                         // Double click on the title does not maximize/restore.
 	onClearBorders       *OnPaintHandlerList          //
 	onClearClientArea    *OnPaintHandlerList          //
 	onClick              *OnMouseHandlerList          //
 	onClickBorder        *OnMouseHandlerList          //
 	onClose              *onCloseHandlerList          //
+	onCloseQuery         *onCloseQueryHandlerList     //
+	onContextClick       *OnMouseHandlerList          //
 	onDoubleClick        *OnMouseHandlerList          //
 	onDoubleClickBorder  *OnMouseHandlerList          //
 	onDrag               *OnMouseHandlerList          //
 	onDragBorder         *OnMouseHandlerList          //
+	onDragEnd            *OnDragHandlerList           //
+	onDragMove           *OnDragHandlerList           //
+	onDragStart          *OnDragHandlerList           //
 	onDrop               *OnMouseHandlerList          //
 	onKey                *onKeyHandlerList            //
+	onMouseEnter         *OnMouseHandlerList          //
+	onMouseLeave         *OnMouseHandlerList          //
 	onMouseMove          *OnMouseHandlerList          //
+	onMouseMoveBorder    *OnMouseHandlerList          //
 	onPaintBorderBottom  *OnPaintHandlerList          //
 	onPaintBorderLeft    *OnPaintHandlerList          //
 	onPaintBorderRight   *OnPaintHandlerList          //
@@ -73,7 +139,7 @@ type Window struct {
 	onPaintChildren      *OnPaintHandlerList          //
 	onPaintClientArea    *OnPaintHandlerList          //
 	onPaintTitle         *OnPaintHandlerList          //
-	onSetBorderBotom     *OnSetIntHandlerList         //
+	onSetBorderBottom    *OnSetIntHandlerList         //
 	onSetBorderLeft      *OnSetIntHandlerList         //
 	onSetBorderRight     *OnSetIntHandlerList         //
 	onSetBorderStyle     *OnSetStyleHandlerList       //
@@ -82,33 +148,51 @@ type Window struct {
 	onSetClientSize      *OnSetSizeHandlerList        //
 	onSetCloseButton     *OnSetBoolHandlerList        //
 	onSetFocus           *OnSetBoolHandlerList        //
-	onSetFocusedWindow   *onSetWindowHandlerList      // Root window only.
+	onSetFocusedWindow   *OnSetWindowHandlerList      // Root window only.
+	onSetMinimized       *OnSetBoolHandlerList        //
 	onSetOrigin          *OnSetPositionHandlerList    //
 	onSetPosition        *OnSetPositionHandlerList    //
-	onSetSelection       *onSetRectangleHandlerList   // Root window only.
+	onSetSelection       *OnSetRectangleHandlerList   // Root window only.
 	onSetSize            *OnSetSizeHandlerList        //
-	onSetStyle           *onSetWindowStyleHandlerList //
-	onSetTitle           *onSetStringHandlerList      //
+	onSetStyle           *OnSetWindowStyleHandlerList //
+	onSetTitle           *OnSetStringHandlerList      //
+	onSetVisible         *OnSetBoolHandlerList        //
 	parent               *Window                      // Nil for root window.
 	position             Position                     // In parent window coordinates.
 	rendered             time.Duration                //
+	repaintOnFocus       bool                         // Invalidate whole window on focus change.
+	resizing             bool                         // onSetSizeHandler in progress.
+	restoreArea          Rectangle                    // Geometry to restore, in parent window coordinates.
+	rootClip             Rectangle                    // Cached by rootTransform.
+	rootGeometry         uint64                       // Desktop.geometry of the cached rootTransform.
+	rootOffset           Position                     // Cached by rootTransform.
+	rootVisible          bool                         // Cached by rootTransform.
 	selection            Rectangle                    // Root window only.
+	settingBorders       bool                         // SetBorders in progress, the client size is set once.
+	settingRectangle     bool                         // SetRectangle in progress, the areas are invalidated once.
 	size                 Size                         //
 	style                WindowStyle                  //
+	tabIndex             int                          // Keyboard traversal order, negative values are skipped.
 	title                string                       //
+	titleAlignment       TitleAlignment               //
+	titleEllipsis        bool                         // Truncate title to fit.
 	view                 Position                     // Viewport origin.
+	visible              bool                         //
 }
 
 func newWindow(desktop *Desktop, parent *Window, style WindowStyle) *Window {
 	w := &Window{
-		desktop: desktop,
-		parent:  parent,
-		style:   style,
+		desktop:       desktop,
+		parent:        parent,
+		style:         style,
+		titleEllipsis: true,
+		visible:       true,
 	}
 	AddOnPaintHandler(&w.onClearBorders, w.onClearBordersHandler, nil)
 	AddOnPaintHandler(&w.onClearClientArea, w.onClearClientAreaHandler, nil)
 	AddOnPaintHandler(&w.onPaintChildren, w.onPaintChildrenHandler, nil)
 	w.OnClickBorder(w.onClickBorderHandler, nil)
+	w.OnDoubleClickBorder(w.onDoubleClickBorderHandler, nil)
 	w.OnDragBorder(w.onDragBorderHandler, nil)
 	w.OnPaintBorderBottom(w.onPaintBorderBottomHandler, nil)
 	w.OnPaintBorderLeft(w.onPaintBorderLeftHandler, nil)
@@ -124,20 +208,51 @@ func newWindow(desktop *Desktop, parent *Window, style WindowStyle) *Window {
 	w.OnSetClientSize(w.onSetClientSizeHandler, nil)
 	w.OnSetCloseButton(w.onSetCloseButtonHandler, nil)
 	w.OnSetFocus(w.onSetFocusHandler, nil)
+	w.OnSetMinimized(w.onSetMinimizedHandler, nil)
 	w.OnSetOrigin(w.onSetOriginHandler, nil)
 	w.OnSetPosition(w.onSetPositionHandler, nil)
 	w.OnSetSize(w.onSetSizeHandler, nil)
 	w.OnSetStyle(w.onSetStyleHandler, nil)
 	w.OnSetTitle(w.onSetTitleHandler, nil)
+	w.OnSetVisible(w.onSetVisibleHandler, nil)
 	return w
 }
 
 func (w *Window) setCell(p Position, mainc rune, combc []rune, style tcell.Style) {
-	if !w.ctx.origin.add(p).In(w.ctx.Rectangle) {
+	q := w.ctx.origin.add(p)
+	if !q.In(w.ctx.Rectangle) {
+		return
+	}
+
+	// A double width rune having its right half outside of the painted area
+	// would overlap the neighbor cell, paint a placeholder instead.
+	if runewidth.RuneWidth(mainc) == 2 && !q.add(Position{1, 0}).In(Rectangle{w.ctx.origin.add(w.ctx.view), w.ctx.size}) {
+		mainc, combc = ' ', nil
+	}
+
+	p = p.add(w.ctx.origin).sub(w.ctx.view)
+	if w == App.capture {
+		if p.Y >= 0 && p.Y < len(App.cells) && p.X >= 0 && p.X < len(App.cells[p.Y]) {
+			App.cells[p.Y][p.X] = Cell{mainc, append([]rune(nil), combc...), style}
+		}
 		return
 	}
 
-	p = p.add(w.position).add(w.ctx.origin).sub(w.ctx.view)
+	if w.buffering {
+		if p = p.sub(w.clientArea.Position); p.Y >= 0 && p.Y < len(w.buffer) && p.X >= 0 && p.X < len(w.buffer[p.Y]) {
+			c := &w.buffer[p.Y][p.X]
+			*c = Cell{mainc, append(c.Combc[:0], combc...), style}
+		}
+		return
+	}
+
+	w.emitCell(p, mainc, combc, style)
+}
+
+// emitCell passes the cell at p, in window coordinates, to the parent window
+// or to the screen.
+func (w *Window) emitCell(p Position, mainc rune, combc []rune, style tcell.Style) {
+	p = p.add(w.position)
 	switch w := w.Parent(); w {
 	case nil:
 		App.setCell(p, mainc, combc, style)
@@ -146,17 +261,208 @@ func (w *Window) setCell(p Position, mainc rune, combc []rune, style tcell.Style
 	}
 }
 
+// clientToScreen converts p, using the same coordinates as Printf in an
+// OnPaintClientArea handler, to a screen position. The result is false if the
+// position is not visible.
+func (w *Window) clientToScreen(p Position) (Position, bool) {
+	p = p.add(w.ClientPosition()).sub(w.Origin())
+	if !p.In(w.clientArea) {
+		return p, false
+	}
+
+	for {
+		if !w.Visible() {
+			return p, false
+		}
+
+		p = p.add(w.position)
+		parent := w.Parent()
+		if parent == nil {
+			return p, true
+		}
+
+		p = p.add(parent.ClientPosition()).sub(parent.Origin())
+		if !p.In(parent.clientArea) {
+			return p, false
+		}
+
+		w = parent
+	}
+}
+
+// snapAxis returns pos adjusted so that an edge of a segment of length size,
+// starting at pos, is flush with the nearest of edges not farther than dist.
+func snapAxis(pos, size, dist int, edges []int) int {
+	r, best := pos, dist+1
+	for _, e := range edges {
+		for _, c := range []int{e, e - size} {
+			d := c - pos
+			if d < 0 {
+				d = -d
+			}
+			if d < best {
+				r, best = c, d
+			}
+		}
+	}
+	return r
+}
+
+// snap returns the position p of the dragged window w adjusted to make its
+// edges flush with the nearby edges of the parent client area or the
+// siblings. See Application.SetSnapDistance.
+func (w *Window) snap(p Position) Position {
+	dist := App.snapDistance
+	p0 := w.Parent()
+	if dist <= 0 || p0 == nil {
+		return p
+	}
+
+	o := p0.Origin()
+	sz := p0.ClientSize()
+	xs := []int{o.X, o.X + sz.Width}
+	ys := []int{o.Y, o.Y + sz.Height}
+	for _, v := range p0.children {
+		if v != w && v.Visible() {
+			xs = append(xs, v.position.X, v.position.X+v.size.Width)
+			ys = append(ys, v.position.Y, v.position.Y+v.size.Height)
+		}
+	}
+	return Position{snapAxis(p.X, w.size.Width, dist, xs), snapAxis(p.Y, w.size.Height, dist, ys)}
+}
+
+// blit writes the cells of the client area buffer within ctx to the screen.
+// Cells the screen already shows are skipped.
+func (w *Window) blit(ctx PaintContext) {
+	App.blitting = true
+	a := Rectangle{ctx.Position.sub(ctx.view).sub(ctx.origin), ctx.Size}
+	for y := a.Y; y < a.Y+a.Height; y++ {
+		row := w.buffer[y]
+		for x := a.X; x < a.X+a.Width; x++ {
+			c := row[x]
+			w.emitCell(w.clientArea.Position.add(Position{x, y}), c.Mainc, c.Combc, c.Style)
+			if runewidth.RuneWidth(c.Mainc) == 2 {
+				x++
+			}
+		}
+	}
+	App.blitting = false
+}
+
+// captured returns whether w is being rendered by RenderToCells.
+func (w *Window) captured() bool {
+	if App.capture == nil {
+		return false
+	}
+
+	for ; w != nil; w = w.Parent() {
+		if w == App.capture {
+			return true
+		}
+	}
+	return false
+}
+
 func (w *Window) onPaintTitleHandler(_ *Window, prev OnPaintHandler, _ PaintContext) {
 	if prev != nil {
 		panic("internal error")
 	}
 
-	title := w.Title()
+	if title, x := w.titleLayout(w.Title()); title != "" {
+		w.Printf(x, 0, w.titleStyle(), " %s ", title)
+	}
+}
+
+// titleLayout returns title as shown on the top border, possibly truncated,
+// and its x coordinate, including the leading space, relative to the title
+// area. The returned string is empty if there's nothing to show.
+func (w *Window) titleLayout(title string) (string, int) {
 	if title == "" {
-		return
+		return "", 0
+	}
+
+	if w.titleEllipsis {
+		if title = ellipsis(title, w.titleWidth()-2); title == "" {
+			return "", 0
+		}
+	}
+
+	x := 0
+	switch w.titleAlignment {
+	case TitleCenter:
+		x = (w.titleWidth() - runewidth.StringWidth(title) - 2) / 2
+	case TitleRight:
+		x = w.titleWidth() - runewidth.StringWidth(title) - 2
 	}
+	return title, mathutil.Max(0, x)
+}
+
+// titleArea returns the area of the top border where the title is painted, in
+// window coordinates.
+func (w *Window) titleArea() Rectangle {
+	a := w.BorderTopArea()
+	if a.IsZero() {
+		return a
+	}
+
+	a.X++
+	a.Width--
+	if w.CloseButton() {
+		a.Width -= closeButtonOffset
+	}
+	a.Height = 1
+	return a
+}
+
+// titleSpan returns the part of the title area covered by title, in window
+// coordinates. The result is zero if title is not shown.
+func (w *Window) titleSpan(title string) Rectangle {
+	a := w.titleArea()
+	title, x := w.titleLayout(title)
+	if a.IsZero() || title == "" {
+		return Rectangle{}
+	}
+
+	r := Rectangle{Position{a.X + x, a.Y}, Size{runewidth.StringWidth(title) + 2, 1}}
+	if !r.Clip(a) {
+		return Rectangle{}
+	}
+
+	return r
+}
+
+// ellipsis returns s truncated to at most width cells. If s is truncated, the
+// result ends in '…'.
+func ellipsis(s string, width int) string {
+	if runewidth.StringWidth(s) <= width {
+		return s
+	}
+
+	if width <= 0 {
+		return ""
+	}
+
+	width-- // '…'
+	n := 0
+	for i, r := range s {
+		rw := runewidth.RuneWidth(r)
+		if n+rw > width {
+			return s[:i] + "…"
+		}
+
+		n += rw
+	}
+	return s
+}
 
-	w.Printf(0, 0, w.Style().Title, " %s ", title)
+// titleWidth returns the number of cells available for the title, including
+// its surrounding spaces.
+func (w *Window) titleWidth() int {
+	n := w.size.Width - 2 // Corners.
+	if w.CloseButton() {
+		n = w.size.Width - 1 - closeButtonOffset
+	}
+	return mathutil.Max(0, n)
 }
 
 func (w *Window) onSetTitleHandler(_ *Window, prev OnSetStringHandler, dst *string, src string) {
@@ -164,8 +470,29 @@ func (w *Window) onSetTitleHandler(_ *Window, prev OnSetStringHandler, dst *stri
 		panic("internal error")
 	}
 
+	r := w.titleSpan(*dst)
 	*dst = src
-	w.Invalidate(w.BorderTopArea())
+	r.join(w.titleSpan(src))
+	switch {
+	case r.IsZero():
+		// nop
+	case r.Width >= w.titleArea().Width:
+		w.Invalidate(w.BorderTopArea())
+	default:
+		w.Invalidate(r)
+	}
+}
+
+func (w *Window) onSetVisibleHandler(_ *Window, prev OnSetBoolHandler, dst *bool, src bool) {
+	if prev != nil {
+		panic("internal error")
+	}
+
+	*dst = src
+	if !src && w.hasFocus() {
+		w.desktop.SetFocusedWindow(nil)
+	}
+	w.Parent().InvalidateClientArea(Rectangle{w.Position(), w.Size()})
 }
 
 func (w *Window) onSetCloseButtonHandler(_ *Window, prev OnSetBoolHandler, dst *bool, src bool) {
@@ -185,7 +512,7 @@ func (w *Window) onClickBorderHandler(_ *Window, prev OnMouseHandler, button tce
 	w.BringToFront()
 	w.SetFocus(true)
 	if w.CloseButton() && pos.In(w.closeButtonArea()) {
-		w.Close() //TODO CloseQuery
+		w.Close()
 		return true
 	}
 
@@ -193,6 +520,52 @@ func (w *Window) onClickBorderHandler(_ *Window, prev OnMouseHandler, button tce
 
 }
 
+func (w *Window) onDoubleClickBorderHandler(_ *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, pos Position, mods tcell.ModMask) bool {
+	if prev != nil {
+		panic("internal error")
+	}
+
+	if button != tcell.Button1 || mods != 0 || w.Parent() == nil || w.noTitleMaximize || !pos.In(w.topBorderDragMoveArea()) {
+		return false
+	}
+
+	if w.CloseButton() && pos.In(w.closeButtonArea()) {
+		return false
+	}
+
+	switch {
+	case w.Minimized(), w.Maximized():
+		w.Restore()
+	default:
+		w.Maximize()
+	}
+	return true
+}
+
+func (w *Window) onSetMinimizedHandler(_ *Window, prev OnSetBoolHandler, dst *bool, src bool) {
+	if prev != nil {
+		panic("internal error")
+	}
+
+	w.dragState = 0
+	if r := w.Desktop().Root(); r.dragWindow == w {
+		r.dragWindow = nil
+	}
+	*dst = src
+	switch {
+	case src:
+		if !w.maximized {
+			w.restoreArea = Rectangle{w.Position(), w.Size()}
+		}
+		w.SetSize(Size{w.size.Width, mathutil.Max(1, w.borderTop)})
+	case w.maximized:
+		p := w.Parent()
+		w.SetRectangle(Rectangle{p.Origin(), p.ClientSize()})
+	default:
+		w.SetRectangle(w.restoreArea)
+	}
+}
+
 func (w *Window) onDragBorderHandler(_ *Window, prev OnMouseHandler, button tcell.ButtonMask, screenPos, pos Position, mods tcell.ModMask) bool {
 	if prev != nil {
 		panic("internal error")
@@ -203,13 +576,21 @@ func (w *Window) onDragBorderHandler(_ *Window, prev OnMouseHandler, button tcel
 	}
 
 	switch {
+	case w.maximized && !w.minimized:
+		return false
 	case pos.In(w.topBorderDragMoveArea()):
+		if w.noMove {
+			return false
+		}
+
 		w.BringToFront()
 		w.SetFocus(true)
 		w.dragState = dragPos
 		w.dragScreenPos0 = screenPos
 		w.dragWinPos0 = w.position
 		return true
+	case w.minimized || w.noResize:
+		return false
 	case pos.In(w.rightBorderDragResizeArea()):
 		w.BringToFront()
 		w.SetFocus(true)
@@ -274,7 +655,7 @@ func (w *Window) onClearBordersHandler(_ *Window, prev OnPaintHandler, ctx Paint
 		panic("internal error")
 	}
 
-	style := w.Style().Border.TCellStyle()
+	style := w.borderStyle().TCellStyle()
 	if a := w.BorderTopArea(); a.Clip(ctx.Rectangle) {
 		w.clear(a, style)
 	}
@@ -294,9 +675,10 @@ func (w *Window) onPaintBorderTopHandler(_ *Window, prev OnPaintHandler, _ Paint
 		panic("internal error")
 	}
 
-	style := w.Style().Border
-	tstyle := w.Style().Border.TCellStyle()
+	style := w.borderStyle()
+	tstyle := style.TCellStyle()
 	sz := w.Size()
+	br := w.style.BorderLine.runes()
 	borderArea := w.BorderTopArea()
 	if borderArea.Width == 1 {
 		w.SetCell(borderArea.X, borderArea.Y, ' ', nil, tstyle)
@@ -307,17 +689,17 @@ func (w *Window) onPaintBorderTopHandler(_ *Window, prev OnPaintHandler, _ Paint
 		var r rune
 		switch x {
 		case 0:
-			r = tcell.RuneULCorner
+			r = br.ulc
 			if sz.Height < 2 {
 				r = ' '
 			}
 		case borderArea.Width - 1:
-			r = tcell.RuneURCorner
+			r = br.urc
 			if sz.Height < 2 {
 				r = ' '
 			}
 		default:
-			r = tcell.RuneHLine
+			r = br.h
 		}
 		w.SetCell(x, 0, r, nil, tstyle)
 	}
@@ -332,8 +714,9 @@ func (w *Window) onPaintBorderLeftHandler(_ *Window, prev OnPaintHandler, _ Pain
 		panic("internal error")
 	}
 
-	style := w.Style().Border.TCellStyle()
+	style := w.borderStyle().TCellStyle()
 	sz := w.Size()
+	br := w.style.BorderLine.runes()
 	borderArea := w.BorderLeftArea()
 	if borderArea.Height == 1 {
 		w.SetCell(borderArea.X, borderArea.Y, ' ', nil, style)
@@ -344,17 +727,17 @@ func (w *Window) onPaintBorderLeftHandler(_ *Window, prev OnPaintHandler, _ Pain
 		var r rune
 		switch y {
 		case 0:
-			r = tcell.RuneULCorner
+			r = br.ulc
 			if sz.Width < 2 {
 				r = ' '
 			}
 		case borderArea.Height - 1:
-			r = tcell.RuneLLCorner
+			r = br.llc
 			if sz.Width < 2 {
 				r = ' '
 			}
 		default:
-			r = tcell.RuneVLine
+			r = br.v
 		}
 		w.SetCell(0, y, r, nil, style)
 	}
@@ -365,8 +748,9 @@ func (w *Window) onPaintBorderRightHandler(_ *Window, prev OnPaintHandler, _ Pai
 		panic("internal error")
 	}
 
-	style := w.Style().Border.TCellStyle()
+	style := w.borderStyle().TCellStyle()
 	sz := w.Size()
+	br := w.style.BorderLine.runes()
 	borderArea := w.BorderRightArea()
 	if borderArea.Height == 1 {
 		w.SetCell(borderArea.X, borderArea.Y, ' ', nil, style)
@@ -378,17 +762,17 @@ func (w *Window) onPaintBorderRightHandler(_ *Window, prev OnPaintHandler, _ Pai
 		var r rune
 		switch y {
 		case 0:
-			r = tcell.RuneURCorner
+			r = br.urc
 			if sz.Width < 2 {
 				r = ' '
 			}
 		case borderArea.Height - 1:
-			r = tcell.RuneLRCorner
+			r = br.lrc
 			if sz.Width < 2 {
 				r = ' '
 			}
 		default:
-			r = tcell.RuneVLine
+			r = br.v
 		}
 		w.SetCell(x, y, r, nil, style)
 	}
@@ -399,8 +783,9 @@ func (w *Window) onPaintBorderBottomHandler(_ *Window, prev OnPaintHandler, _ Pa
 		panic("internal error")
 	}
 
-	style := w.Style().Border.TCellStyle()
+	style := w.borderStyle().TCellStyle()
 	sz := w.Size()
+	br := w.style.BorderLine.runes()
 	borderArea := w.BorderBottomArea()
 	if borderArea.Width == 1 {
 		w.SetCell(borderArea.X, borderArea.Y, ' ', nil, style)
@@ -412,17 +797,17 @@ func (w *Window) onPaintBorderBottomHandler(_ *Window, prev OnPaintHandler, _ Pa
 		var r rune
 		switch x {
 		case 0:
-			r = tcell.RuneLLCorner
+			r = br.llc
 			if sz.Height < 2 {
 				r = ' '
 			}
 		case borderArea.Width - 1:
-			r = tcell.RuneLRCorner
+			r = br.lrc
 			if sz.Height < 2 {
 				r = ' '
 			}
 		default:
-			r = tcell.RuneHLine
+			r = br.h
 		}
 		w.SetCell(x, y, r, nil, style)
 	}
@@ -438,7 +823,7 @@ func (w *Window) onSetSelectionHandler(_ *Window, prev OnSetRectangleHandler, ds
 	App.EndUpdate()
 }
 
-func (w *Window) setFocusedWindow(u *Window) { w.onSetFocusedWindow.handle(w, &w.focusedWindow, u) }
+func (w *Window) setFocusedWindow(u *Window) { w.onSetFocusedWindow.Handle(w, &w.focusedWindow, u) }
 
 // ATM root window only.
 func (w *Window) onSetFocusedWindowHandler(_ *Window, prev OnSetWindowHandler, dst **Window, src *Window) {
@@ -451,16 +836,91 @@ func (w *Window) onSetFocusedWindowHandler(_ *Window, prev OnSetWindowHandler, d
 	if old != nil {
 		old.SetFocus(false)
 		if old.Parent() != nil {
-			old.Invalidate(old.Area())
+			old.invalidateFocus()
 		}
 	}
 
 	if src != nil {
 		src.SetFocus(true)
 		if src.Parent() != nil {
-			src.Invalidate(src.Area())
+			src.invalidateFocus()
+		}
+	}
+}
+
+// hasFocus returns whether w or any of its descendants is focused.
+func (w *Window) hasFocus() bool {
+	f := w.desktop.FocusedWindow()
+	return f == w || w.IsAncestorOf(f)
+}
+
+// closeFocusTarget returns the window to focus after closing a focused child
+// of w, if any. That's w itself unless it's the root window or it's being
+// closed, otherwise it's the top-most visible child of the root window.
+func (w *Window) closeFocusTarget() *Window {
+	switch {
+	case w.closing:
+		return nil
+	case w.parent != nil:
+		return w
+	}
+
+	for i := len(w.children) - 1; i >= 0; i-- {
+		if c := w.children[i]; c.visible && !c.noInput && !c.closing {
+			return c
+		}
+	}
+	return nil
+}
+
+// closeQuery returns whether the OnCloseQuery handlers of w and of all its
+// descendants allow closing w. The descendants are asked first.
+func (w *Window) closeQuery() bool {
+	for _, v := range append([]*Window(nil), w.children...) {
+		if !v.closeQuery() {
+			return false
 		}
 	}
+
+	return w.onCloseQuery.handle(w)
+}
+
+// invalidateFocus invalidates the parts of w reflecting its focus state.
+func (w *Window) invalidateFocus() {
+	if w.repaintOnFocus {
+		w.Invalidate(w.Area())
+		return
+	}
+
+	w.invalidateBorders()
+}
+
+// invalidateBorders invalidates all borders of w.
+func (w *Window) invalidateBorders() {
+	w.BeginUpdate()
+	w.Invalidate(w.BorderTopArea())
+	w.Invalidate(w.BorderLeftArea())
+	w.Invalidate(w.BorderRightArea())
+	w.Invalidate(w.BorderBottomArea())
+	w.EndUpdate()
+}
+
+// borderStyle returns the style used to paint the borders of w.
+func (w *Window) borderStyle() Style {
+	if !w.focus && !w.style.BorderInactive.IsZero() {
+		return w.style.BorderInactive
+	}
+
+	return w.style.Border
+}
+
+// titleStyle returns the style used to paint the title of w.
+func (w *Window) titleStyle() Style {
+	if !w.focus && !w.style.TitleInactive.IsZero() {
+		return w.style.TitleInactive
+	}
+
+	return w.style.Title
 }
 
 func (w *Window) onSetFocusHandler(_ *Window, prev OnSetBoolHandler, dst *bool, src bool) {
@@ -468,9 +928,15 @@ func (w *Window) onSetFocusHandler(_ *Window, prev OnSetBoolHandler, dst *bool,
 		panic("internal error")
 	}
 
+	if src && !w.InputEnabled() {
+		return
+	}
+
 	*dst = src
 	d := w.desktop
-	w.style.Border.Attr ^= tcell.AttrReverse
+	if w.style.BorderInactive.IsZero() {
+		w.style.Border.Attr ^= tcell.AttrReverse
+	}
 
 	switch {
 	case src:
@@ -510,10 +976,11 @@ func (w *Window) onSetStyleHandler(_ *Window, prev OnSetWindowStyleHandler, dst
 	w.Invalidate(w.Area())
 }
 
+// clear fills area with spaces. It is used only by paint handlers.
 func (w *Window) clear(area Rectangle, style tcell.Style) {
 	for y := area.Y; y < area.Y+area.Height; y++ {
 		for x := area.X; x < area.X+area.Width; x++ {
-			w.SetCell(x, y, ' ', nil, style)
+			w.setCell(Position{x, y}, ' ', nil, style)
 		}
 	}
 }
@@ -538,6 +1005,10 @@ func (w *Window) onPaintChildrenHandler(_ *Window, prev OnPaintHandler, ctx Pain
 			break
 		}
 
+		if !c.visible {
+			continue
+		}
+
 		chPos := c.Position().add(clPos)
 		if area := (Rectangle{chPos, c.Size()}); area.Clip(ctx.Rectangle) {
 			c.paint(Rectangle{area.sub(chPos), area.Size})
@@ -552,6 +1023,7 @@ func (w *Window) onSetOriginHandler(_ *Window, prev OnSetPositionHandler, dst *P
 
 	w.Invalidate(w.ClientArea())
 	*dst = src
+	w.desktop.geometry++
 }
 
 func (w *Window) onSetPositionHandler(_ *Window, prev OnSetPositionHandler, dst *Position, src Position) {
@@ -559,9 +1031,38 @@ func (w *Window) onSetPositionHandler(_ *Window, prev OnSetPositionHandler, dst
 		panic("internal error")
 	}
 
-	w.Invalidate(w.Area())
+	if w.parent != nil && w.confine {
+		src = w.confinePosition(src)
+	}
+	if !w.settingRectangle {
+		w.Invalidate(w.Area())
+	}
 	*dst = src
-	w.Invalidate(w.Area())
+	w.desktop.geometry++
+	if !w.settingRectangle {
+		w.Invalidate(w.Area())
+	}
+}
+
+// confinePosition returns p adjusted to keep w within the client area of its
+// parent. Unless w.confineFully is set, only the top border row and at least
+// confineMinVisible cells of it are kept visible.
+func (w *Window) confinePosition(p Position) Position {
+	const confineMinVisible = 4
+
+	parent := w.Parent()
+	o := parent.Origin()
+	sz := parent.ClientSize()
+	switch {
+	case w.confineFully:
+		p.X = mathutil.Max(o.X, mathutil.Min(p.X, o.X+sz.Width-w.size.Width))
+		p.Y = mathutil.Max(o.Y, mathutil.Min(p.Y, o.Y+sz.Height-w.size.Height))
+	default:
+		n := mathutil.Min(confineMinVisible, w.size.Width)
+		p.X = mathutil.Max(o.X-w.size.Width+n, mathutil.Min(p.X, o.X+sz.Width-n))
+		p.Y = mathutil.Max(o.Y, mathutil.Min(p.Y, o.Y+sz.Height-1))
+	}
+	return p
 }
 
 func (w *Window) onSetSizeHandler(_ *Window, prev OnSetSizeHandler, dst *Size, src Size) {
@@ -571,14 +1072,59 @@ func (w *Window) onSetSizeHandler(_ *Window, prev OnSetSizeHandler, dst *Size, s
 
 	src.Width = mathutil.Max(0, src.Width)
 	src.Height = mathutil.Max(0, src.Height)
-	w.Invalidate(w.Area())
+	if w.parent != nil && !w.minimized {
+		src = w.clampSize(src)
+	}
+	old := w.size
+	if !w.settingRectangle {
+		w.invalidateResize(src)
+	}
 	*dst = src
+	w.desktop.geometry++
 	csz := Size{
 		mathutil.Max(0, src.Width-(w.borderLeft+w.borderRight)),
 		mathutil.Max(0, src.Height-(w.borderTop+w.borderBottom)),
 	}
+	w.resizing = true
 	w.SetClientSize(csz)
-	w.Invalidate(w.Area())
+	w.resizing = false
+	if !w.settingRectangle {
+		w.invalidateResize(old)
+	}
+}
+
+// invalidateResize invalidates the parts of w which differ from w having size
+// other: the area outside of other and the borders. Windows repainting on
+// resize and root windows are invalidated completely.
+func (w *Window) invalidateResize(other Size) {
+	if w.parent == nil || !w.noRepaintOnResize {
+		w.Invalidate(w.Area())
+		return
+	}
+
+	sz := w.size
+	w.BeginUpdate()
+	if n := sz.Width - other.Width; n > 0 {
+		w.Invalidate(Rectangle{Position{other.Width, 0}, Size{n, sz.Height}})
+	}
+	if n := sz.Height - other.Height; n > 0 {
+		w.Invalidate(Rectangle{Position{0, other.Height}, Size{sz.Width, n}})
+	}
+	w.invalidateBorders()
+	w.EndUpdate()
+}
+
+// clampSize returns s adjusted to the size constraints of w.
+func (w *Window) clampSize(s Size) Size {
+	s.Width = mathutil.Max(s.Width, w.minSize.Width)
+	if n := w.maxSize.Width; n > 0 {
+		s.Width = mathutil.Min(s.Width, n)
+	}
+	s.Height = mathutil.Max(s.Height, w.minSize.Height)
+	if n := w.maxSize.Height; n > 0 {
+		s.Height = mathutil.Min(s.Height, n)
+	}
+	return s
 }
 
 func (w *Window) onSetClientSizeHandler(_ *Window, prev OnSetSizeHandler, dst *Size, src Size) {
@@ -588,21 +1134,41 @@ func (w *Window) onSetClientSizeHandler(_ *Window, prev OnSetSizeHandler, dst *S
 
 	src.Width = mathutil.Max(0, src.Width)
 	src.Height = mathutil.Max(0, src.Height)
-	w.Invalidate(w.Area())
+	if w.parent == nil { // The root window size follows the application size.
+		src = Size{
+			mathutil.Max(0, w.size.Width-(w.borderLeft+w.borderRight)),
+			mathutil.Max(0, w.size.Height-(w.borderTop+w.borderBottom)),
+		}
+	}
 	*dst = src
+	w.desktop.geometry++
+	if w.buffer != nil {
+		w.buffer = newCells(src)
+	}
+	for _, c := range w.children {
+		if c.maximized && !c.minimized {
+			c.SetSize(src)
+		}
+		if c.confine {
+			c.SetPosition(c.confinePosition(c.position))
+		}
+	}
 	wsz := Size{
 		w.borderLeft + src.Width + w.borderRight,
 		w.borderTop + src.Height + w.borderBottom,
 	}
-	p := w.parent
-
-	w.SetSize(wsz)
-	if p != nil {
+	if w.minimized {
+		wsz.Height = w.size.Height
+	}
+	switch {
+	case wsz != w.size:
+		w.SetSize(wsz)
+	case !w.resizing: // The borders changed.
 		w.Invalidate(w.Area())
-		return
 	}
-
-	w.InvalidateClientArea(w.ClientArea())
+	if w.parent == nil || w.buffer != nil {
+		w.InvalidateClientArea(Rectangle{w.Origin(), src})
+	}
 }
 
 func (w *Window) onSetBorderBottomHandler(_ *Window, prev OnSetIntHandler, dst *int, src int) {
@@ -611,6 +1177,11 @@ func (w *Window) onSetBorderBottomHandler(_ *Window, prev OnSetIntHandler, dst *
 	}
 
 	*dst = src
+	w.desktop.geometry++
+	if w.settingBorders {
+		return
+	}
+
 	sz := Size{w.clientArea.Width, mathutil.Max(0, w.size.Height-(w.borderTop+w.borderBottom))}
 	w.SetClientSize(sz)
 }
@@ -622,6 +1193,11 @@ func (w *Window) onSetBorderLeftHandler(_ *Window, prev OnSetIntHandler, dst *in
 
 	*dst = src
 	w.clientArea.X = src
+	w.desktop.geometry++
+	if w.settingBorders {
+		return
+	}
+
 	sz := Size{mathutil.Max(0, w.size.Width-(w.borderLeft+w.borderRight)), w.clientArea.Height}
 	w.SetClientSize(sz)
 }
@@ -632,6 +1208,11 @@ func (w *Window) onSetBorderRightHandler(_ *Window, prev OnSetIntHandler, dst *i
 	}
 
 	*dst = src
+	w.desktop.geometry++
+	if w.settingBorders {
+		return
+	}
+
 	sz := Size{mathutil.Max(0, w.size.Width-(w.borderLeft+w.borderRight)), w.clientArea.Height}
 	w.SetClientSize(sz)
 }
@@ -643,24 +1224,15 @@ func (w *Window) onSetBorderTopHandler(_ *Window, prev OnSetIntHandler, dst *int
 
 	*dst = src
 	w.clientArea.Y = src
+	w.desktop.geometry++
+	if w.settingBorders {
+		return
+	}
+
 	sz := Size{w.clientArea.Width, mathutil.Max(0, w.size.Height-(w.borderTop+w.borderBottom))}
 	w.SetClientSize(sz)
 }
 
-func (w *Window) printCell(x, y, width int, main rune, comb []rune, style tcell.Style) (int, int) {
-	switch main {
-	case '\t':
-		return x + 8 - x%8, y
-	case '\n':
-		return 0, y + 1
-	case '\r':
-		return 0, y
-	default:
-		w.SetCell(x, y, main, comb, style)
-		return x + width, y
-	}
-}
-
 // BeginUpdate marks the start of one or more updates to w.
 //
 // Failing to properly pair BeginUpdate with a corresponding EndUpdate will
@@ -669,9 +1241,6 @@ func (w *Window) BeginUpdate() {
 	if w != nil {
 		d := w.Desktop()
 		d.updateLevel++
-		if d.updateLevel == 1 {
-			d.invalidated = Rectangle{}
-		}
 		return
 	}
 
@@ -686,14 +1255,13 @@ func (w *Window) EndUpdate() {
 	if w != nil {
 		d := w.Desktop()
 		d.updateLevel--
-		invalidated := d.invalidated
-		if d.updateLevel == 0 && !invalidated.IsZero() {
-			App.BeginUpdate()
-			r := d.Root()
-			t := time.Now()
-			r.paint(invalidated)
-			r.rendered = time.Since(t)
-			App.EndUpdate()
+		if d.updateLevel == 0 && App.frozen == 0 && len(d.invalidated) != 0 {
+			switch {
+			case App.batchPaint && !d.flushDue:
+				d.postFlush()
+			default:
+				d.flush()
+			}
 		}
 		return
 	}
@@ -704,24 +1272,82 @@ func (w *Window) EndUpdate() {
 // setSize sets the window size.
 func (w *Window) setSize(s Size) { w.onSetSize.Handle(w, &w.size, s) }
 
-func (w *Window) findEventTarget(winPos Position, clientAreaHandler, borderHandler func(*Window, Position)) (*Window, Position, func(*Window, Position)) {
-search:
-	winPos2 := winPos.add(w.view)
-	clArea := w.ClientArea()
-	if winPos.In(clArea) {
-		winPos = winPos2.sub(clArea.Position)
-		var chArea Rectangle
-		for i := len(w.children) - 1; i >= 0; i-- {
-			ch := w.children[i]
-			chArea = ch.Area()
-			chArea.Position = ch.Position()
-			if winPos.In(chArea) {
+// resizeEdge resizes w, having position pos0 and size size0, by moving the
+// edges selected by e by dx and dy. The opposite edges stay in place. The left
+// and top edges cannot be moved past the opposite edge.
+func (w *Window) resizeEdge(e Edge, pos0 Position, size0 Size, dx, dy int) {
+	sz := size0
+	switch {
+	case e&EdgeLeft != 0:
+		if dx > size0.Width {
+			dx = size0.Width - 1
+		}
+		sz.Width = mathutil.Max(1, size0.Width-dx)
+	case e&EdgeRight != 0:
+		sz.Width = mathutil.Max(1, size0.Width+dx)
+	}
+	switch {
+	case e&EdgeTop != 0:
+		if dy > size0.Height {
+			dy = size0.Height - 1
+		}
+		sz.Height = mathutil.Max(1, size0.Height-dy)
+	case e&EdgeBottom != 0:
+		sz.Height = mathutil.Max(1, size0.Height+dy)
+	}
+	w.SetSize(sz)
+	if e&(EdgeLeft|EdgeTop) == 0 {
+		return
+	}
+
+	p := pos0
+	if e&EdgeLeft != 0 {
+		p.X += size0.Width - w.size.Width
+	}
+	if e&EdgeTop != 0 {
+		p.Y += size0.Height - w.size.Height
+	}
+	w.SetPosition(p)
+}
+
+// hitTest returns the topmost visible descendant window of w at winPos, or w
+// itself, the position within the returned window and whether the position is
+// in its client area. If visit is not nil, it is called for w and for every
+// window on the path to the returned window, in that order.
+func (w *Window) hitTest(winPos Position, visit func(*Window)) (*Window, Position, bool) {
+search:
+	if visit != nil {
+		visit(w)
+	}
+	winPos2 := winPos.add(w.view)
+	clArea := w.ClientArea()
+	if winPos.In(clArea) {
+		winPos = winPos2.sub(clArea.Position)
+		var chArea Rectangle
+		for i := len(w.children) - 1; i >= 0; i-- {
+			ch := w.children[i]
+			if !ch.visible || ch.noInput {
+				continue
+			}
+
+			chArea = ch.Area()
+			chArea.Position = ch.Position()
+			if winPos.In(chArea) {
 				winPos = winPos.sub(chArea.Position)
 				w = ch
 				goto search
 			}
 		}
 
+		return w, winPos, true
+	}
+
+	return w, winPos, false
+}
+
+func (w *Window) findEventTarget(winPos Position, clientAreaHandler, borderHandler func(*Window, Position)) (*Window, Position, func(*Window, Position)) {
+	w, winPos, client := w.hitTest(winPos, nil)
+	if client {
 		return w, winPos, clientAreaHandler
 	}
 
@@ -737,14 +1363,41 @@ func (w *Window) event(winPos Position, clientAreaHandler, borderHandler func(*W
 	handler(w, pos)
 }
 
+// bubbleClick passes a click not handled by w, at winPos in the coordinates
+// of w, to the ancestors of w as long as they enable click bubbling.
+func (w *Window) bubbleClick(button tcell.ButtonMask, screenPos, winPos Position, mods tcell.ModMask) {
+	for w.clickBubbling && w.parent != nil {
+		winPos = winPos.add(w.position)
+		w = w.parent
+		if w.onClick.Handle(w, button, screenPos, winPos, mods) {
+			return
+		}
+
+		winPos = winPos.sub(w.view).add(w.ClientPosition())
+	}
+}
+
 func (w *Window) click(button tcell.ButtonMask, screenPos Position, mods tcell.ModMask) {
+	context := button == tcell.Button3 && mods == 0
 	w.event(
 		screenPos,
 		func(w *Window, winPos Position) {
-			w.onClick.Handle(w, button, screenPos, winPos, mods)
+			if context && w.onContextClick.Handle(w, button, screenPos, winPos, mods) {
+				return
+			}
+
+			if !w.onClick.Handle(w, button, screenPos, winPos, mods) {
+				w.bubbleClick(button, screenPos, winPos.sub(w.view).add(w.ClientPosition()), mods)
+			}
 		},
 		func(w *Window, winPos Position) {
-			w.onClickBorder.Handle(w, button, screenPos, winPos, mods)
+			if context && w.onContextClick.Handle(w, button, screenPos, winPos, mods) {
+				return
+			}
+
+			if !w.onClickBorder.Handle(w, button, screenPos, winPos, mods) {
+				w.bubbleClick(button, screenPos, winPos, mods)
+			}
 		},
 		true,
 	)
@@ -765,15 +1418,24 @@ func (w *Window) doubleClick(button tcell.ButtonMask, screenPos Position, mods t
 
 func (w *Window) drag(button tcell.ButtonMask, screenPos Position, mods tcell.ModMask) {
 	w.dragWindow = nil
+	w.dragSource = nil
 	w.event(
 		screenPos,
 		func(cw *Window, winPos Position) {
+			if w.startDrag(cw, button, screenPos, mods) {
+				return
+			}
+
 			if cw.onDrag.Handle(cw, button, screenPos, winPos, mods) {
 				w.dragWindow = cw
 				w.dragWindowPos = winPos
 			}
 		},
 		func(cw *Window, winPos Position) {
+			if w.startDrag(cw, button, screenPos, mods) {
+				return
+			}
+
 			if cw.onDragBorder.Handle(cw, button, screenPos, winPos, mods) {
 				w.dragWindow = cw
 				w.dragWindowPos = winPos
@@ -782,67 +1444,56 @@ func (w *Window) drag(button tcell.ButtonMask, screenPos Position, mods tcell.Mo
 		true,
 	)
 }
+
+// startDrag passes the start of a mouse drag at screenPos to the OnDragStart
+// handlers of cw. If they accept the drag, cw becomes the drag source of the
+// root window w and the result is true.
+func (w *Window) startDrag(cw *Window, button tcell.ButtonMask, screenPos Position, mods tcell.ModMask) bool {
+	if !cw.onDragStart.Handle(cw, button, screenPos, screenPos, cw.screenToWindow(screenPos), mods) {
+		return false
+	}
+
+	w.dragSource = cw
+	w.dragSourcePos0 = screenPos
+	return true
+}
+
+// applyDrag moves or resizes w according to the mouse drag of its border in
+// progress, the mouse being at screenPos. It reports whether such a drag is in
+// progress.
+func (w *Window) applyDrag(screenPos Position) bool {
+	dx := screenPos.X - w.dragScreenPos0.X
+	dy := screenPos.Y - w.dragScreenPos0.Y
+	switch ds := w.dragState; ds {
+	case dragPos:
+		w.SetPosition(w.snap(Position{w.dragWinPos0.X + dx, w.dragWinPos0.Y + dy}))
+	case dragRightSize, dragLeftSize, dragBottomSize, dragULC, dragURC, dragLLC, dragLRC:
+		w.resizeEdge(dragEdges[ds], w.dragWinPos0, w.dragWinSize0, dx, dy)
+	default:
+		return false
+	}
+	return true
+}
+
 func (w *Window) drop(button tcell.ButtonMask, screenPos Position, mods tcell.ModMask) {
 	defer func() { w.dragWindow = nil }()
 
+	if s := w.dragSource; s != nil {
+		w.dragSource = nil
+		if s.onDragEnd.Handle(s, button, w.dragSourcePos0, screenPos, s.screenToWindow(screenPos), mods) {
+			return
+		}
+	}
+
 	if fw := w.Desktop().FocusedWindow(); fw != nil && button == tcell.Button1 && mods == 0 {
-		ds := fw.dragState
+		ok := fw.applyDrag(screenPos)
 		fw.dragState = 0
-		screenPos0 := fw.dragScreenPos0
-		winPos0 := fw.dragWinPos0
-		winSize0 := fw.dragWinSize0
-		dx := screenPos.X - screenPos0.X
-		dy := screenPos.Y - screenPos0.Y
-
-		switch ds {
-		case dragPos:
-			fw.SetPosition(Position{winPos0.X + dx, winPos0.Y + dy})
-			return
-		case dragRightSize:
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width+dx), winSize0.Height})
+		switch {
+		case ok:
 			return
-		case dragLeftSize:
-			if dx > winSize0.Width {
-				dx = winSize0.Width - 1
-			}
-			fw.SetPosition(Position{winPos0.X + dx, winPos0.Y})
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width-dx), winSize0.Height})
-			return
-		case dragBottomSize:
-			fw.SetSize(Size{winSize0.Width, mathutil.Max(1, winSize0.Height+dy)})
-			return
-		case dragLRC:
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width+dx), mathutil.Max(1, winSize0.Height+dy)})
-			return
-		case dragURC:
-			if dy > winSize0.Height {
-				dy = winSize0.Height - 1
-			}
-			fw.SetPosition(Position{winPos0.X, winPos0.Y + dy})
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width+dx), mathutil.Max(1, winSize0.Height-dy)})
+		case fw == w.dragWindow:
+			fw.onDrop.Handle(fw, button, screenPos, w.dragWindowPos, mods)
 			return
-		case dragLLC:
-			if dx > winSize0.Width {
-				dx = winSize0.Width - 1
-			}
-			fw.SetPosition(Position{winPos0.X + dx, winPos0.Y})
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width-dx), mathutil.Max(1, winSize0.Height+dy)})
-			return
-		case dragULC:
-			if dx > winSize0.Width {
-				dx = winSize0.Width - 1
-			}
-			if dy > winSize0.Height {
-				dy = winSize0.Height - 1
-			}
-			fw.SetPosition(Position{winPos0.X + dx, winPos0.Y + dy})
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width-dx), mathutil.Max(1, winSize0.Height-dy)})
-			return
-		default:
-			if fw == w.dragWindow {
-				fw.onDrop.Handle(fw, button, screenPos, w.dragWindowPos, mods)
-				return
-			}
 		}
 	}
 
@@ -857,64 +1508,50 @@ func (w *Window) drop(button tcell.ButtonMask, screenPos Position, mods tcell.Mo
 		true,
 	)
 }
+
+// hover reports leaving the previously hovered window and entering the window
+// under the mouse at screenPos, if they differ.
+func (w *Window) hover(button tcell.ButtonMask, screenPos Position, mods tcell.ModMask) {
+	t, _, _ := w.hitTest(screenPos, nil)
+	old := w.hoveredWindow
+	if t == old {
+		return
+	}
+
+	w.hoveredWindow = t
+	if old != nil {
+		old.onMouseLeave.Handle(old, button, screenPos, old.screenToWindow(screenPos), mods)
+	}
+	if w.hoveredWindow == t {
+		t.onMouseEnter.Handle(t, button, screenPos, t.screenToWindow(screenPos), mods)
+	}
+}
+
+// screenToWindow converts p in screen coordinates to window coordinates.
+func (w *Window) screenToWindow(p Position) Position {
+	if w.parent == nil {
+		return p
+	}
+
+	off, _, _ := w.rootTransform()
+	return p.sub(off)
+}
+
 func (w *Window) mouseMove(button tcell.ButtonMask, screenPos Position, mods tcell.ModMask) {
+	w.hover(button, screenPos, mods)
+	if s := w.dragSource; s != nil {
+		s.onDragMove.Handle(s, button, w.dragSourcePos0, screenPos, s.screenToWindow(screenPos), mods)
+		return
+	}
+
 	if fw := w.Desktop().FocusedWindow(); fw != nil {
-		ds := fw.dragState
-		screenPos0 := fw.dragScreenPos0
-		winPos0 := fw.dragWinPos0
-		winSize0 := fw.dragWinSize0
-		dx := screenPos.X - screenPos0.X
-		dy := screenPos.Y - screenPos0.Y
-
-		switch ds {
-		case dragPos:
-			fw.SetPosition(Position{winPos0.X + dx, winPos0.Y + dy})
-			return
-		case dragRightSize:
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width+dx), winSize0.Height})
-			return
-		case dragLeftSize:
-			if dx > winSize0.Width {
-				dx = winSize0.Width - 1
-			}
-			fw.SetPosition(Position{winPos0.X + dx, winPos0.Y})
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width-dx), winSize0.Height})
-			return
-		case dragBottomSize:
-			fw.SetSize(Size{winSize0.Width, mathutil.Max(1, winSize0.Height+dy)})
-			return
-		case dragLRC:
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width+dx), mathutil.Max(1, winSize0.Height+dy)})
+		if fw.applyDrag(screenPos) {
 			return
-		case dragURC:
-			if dy > winSize0.Height {
-				dy = winSize0.Height - 1
-			}
-			fw.SetPosition(Position{winPos0.X, winPos0.Y + dy})
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width+dx), mathutil.Max(1, winSize0.Height-dy)})
-			return
-		case dragLLC:
-			if dx > winSize0.Width {
-				dx = winSize0.Width - 1
-			}
-			fw.SetPosition(Position{winPos0.X + dx, winPos0.Y})
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width-dx), mathutil.Max(1, winSize0.Height+dy)})
-			return
-		case dragULC:
-			if dx > winSize0.Width {
-				dx = winSize0.Width - 1
-			}
-			if dy > winSize0.Height {
-				dy = winSize0.Height - 1
-			}
-			fw.SetPosition(Position{winPos0.X + dx, winPos0.Y + dy})
-			fw.SetSize(Size{mathutil.Max(1, winSize0.Width-dx), mathutil.Max(1, winSize0.Height-dy)})
+		}
+
+		if fw == w.dragWindow {
+			fw.onMouseMove.Handle(fw, button, screenPos, w.dragWindowPos, mods)
 			return
-		default:
-			if fw == w.dragWindow {
-				fw.onMouseMove.Handle(fw, button, screenPos, w.dragWindowPos, mods)
-				return
-			}
 		}
 	}
 
@@ -923,176 +1560,277 @@ func (w *Window) mouseMove(button tcell.ButtonMask, screenPos Position, mods tce
 		func(w *Window, winPos Position) {
 			w.onMouseMove.Handle(w, button, screenPos, winPos, mods)
 		},
-		func(w *Window, winPos Position) {},
+		func(w *Window, winPos Position) {
+			w.onMouseMoveBorder.Handle(w, button, screenPos, winPos, mods)
+		},
 		false,
 	)
 }
 
+// rootTransform returns the offset translating coordinates of w to the
+// coordinates of the root window and the area of the root window to which the
+// client areas of the ancestors of w clip its content. The last result is
+// false if the ancestors clip all of w. The result is cached until the
+// geometry of any window of the desktop changes. w must not be a root window.
+func (w *Window) rootTransform() (Position, Rectangle, bool) {
+	d := w.desktop
+	if w.rootGeometry == d.geometry {
+		return w.rootOffset, w.rootClip, w.rootVisible
+	}
+
+	p := w.parent
+	off := w.position.add(p.ClientPosition().sub(p.Origin()))
+	clip := p.ClientArea()
+	ok := true
+	if p.parent != nil {
+		poff, pclip, pok := p.rootTransform()
+		off = off.add(poff)
+		clip.Position = clip.add(poff)
+		ok = pok && clip.Clip(pclip)
+	}
+	w.rootOffset, w.rootClip, w.rootVisible, w.rootGeometry = off, clip, ok, d.geometry
+	return off, clip, ok
+}
+
 // paint asks w to render an area.
 func (w *Window) paint(area Rectangle) {
 	d := w.Desktop()
-	if area.IsZero() || !area.Clip(Rectangle{Size: w.size}) || d != App.Desktop() {
+	if area.IsZero() || !area.Clip(Rectangle{Size: w.size}) {
 		return
 	}
 
-	if d.updateLevel != 0 {
-		for {
-			p := w.Parent()
-			if p == nil {
-				d.invalidated.join(area)
-				return
-			}
+	if w.captured() {
+		w.render(area)
+		return
+	}
 
-			area.Position = area.add(w.Position()).add(p.ClientPosition().sub(p.Origin()))
-			if !area.Clip(p.ClientArea()) {
+	if d != App.Desktop() {
+		return
+	}
+
+	if d.updateLevel != 0 || App.frozen != 0 {
+		if w.parent != nil {
+			off, clip, ok := w.rootTransform()
+			area.Position = area.add(off)
+			if !ok || !area.Clip(clip) {
 				return
 			}
-
-			w = p
 		}
+
+		d.invalidated.add(area)
+		return
 	}
 
+	w.render(area)
+}
+
+// render paints area of w, which must be already clipped to w.
+func (w *Window) render(area Rectangle) {
 	a0 := w.Area()
 	if a := a0; a.Clip(area) {
-		w.onClearBorders.Handle(w, PaintContext{a, a0.Position, Position{}})
+		w.onClearBorders.Handle(w, PaintContext{a, a0.Position, a0.Size, Position{}})
 	}
 
 	a0 = w.BorderTopArea()
 	if a := a0; a.Clip(area) {
-		w.onPaintBorderTop.Handle(w, PaintContext{a, a0.Position, Position{}})
+		w.onPaintBorderTop.Handle(w, PaintContext{a, a0.Position, a0.Size, Position{}})
 	}
 
-	if !a0.IsZero() && w.Title() != "" {
-		a0.X++
-		a0.Width--
-		if w.CloseButton() {
-			a0.Width -= closeButtonOffset
-		}
-		a0.Height = 1
+	if a0 = w.titleArea(); !a0.IsZero() && w.Title() != "" {
 		if a := a0; a.Clip(area) {
-			w.onPaintTitle.Handle(w, PaintContext{a, a0.Position, Position{}})
+			w.onPaintTitle.Handle(w, PaintContext{a, a0.Position, a0.Size, Position{}})
 		}
 	}
 
+	if w.minimized {
+		return
+	}
+
 	a0 = w.BorderLeftArea()
 	if a := a0; a.Clip(area) {
-		w.onPaintBorderLeft.Handle(w, PaintContext{a, a0.Position, Position{}})
+		w.onPaintBorderLeft.Handle(w, PaintContext{a, a0.Position, a0.Size, Position{}})
 	}
 
+	w.buffering = w.buffer != nil && App.capture == nil
 	a0 = w.ClientArea()
 	if a := a0; a.Clip(area) {
-		ctx := PaintContext{a, a0.Position, Position{}}
+		ctx := PaintContext{a, a0.Position, a0.Size, Position{}}
 		w.onClearClientArea.Handle(w, ctx)
 	}
 
 	a0 = w.ClientArea()
 	if a := a0; a.Clip(area) {
 		a.Position = a.add(w.view)
-		ctx := PaintContext{a, a0.Position, w.view}
+		ctx := PaintContext{a, a0.Position, a0.Size, w.view}
 		w.onPaintClientArea.Handle(w, ctx)
+		if w.buffering {
+			w.buffering = false
+			w.blit(ctx)
+		}
 		w.onPaintChildren.Handle(w, ctx)
 	}
+	w.buffering = false
 
 	a0 = w.BorderRightArea()
 	if a := a0; a.Clip(area) {
-		w.onPaintBorderRight.Handle(w, PaintContext{a, a0.Position, Position{}})
+		w.onPaintBorderRight.Handle(w, PaintContext{a, a0.Position, a0.Size, Position{}})
 	}
 
 	a0 = w.BorderBottomArea()
 	if a := a0; a.Clip(area) {
-		w.onPaintBorderBottom.Handle(w, PaintContext{a, a0.Position, Position{}})
+		w.onPaintBorderBottom.Handle(w, PaintContext{a, a0.Position, a0.Size, Position{}})
 	}
 }
 
-func (w *Window) print(x, y int, style tcell.Style, s string) {
+// sprintf is like fmt.Sprintf but it avoids formatting for the frequent
+// "%s" format with a single string argument.
+func sprintf(format string, arg []interface{}) string {
+	if format == "%s" && len(arg) == 1 {
+		if s, ok := arg[0].(string); ok {
+			return s
+		}
+	}
+
+	return fmt.Sprintf(format, arg...)
+}
+
+// print prints s at x, y and returns the position following the last printed
+// cell. If limit >= 0, printing stops before the first cell that would end
+// after column limit.
+func (w *Window) print(x, y int, style tcell.Style, s string, limit int) (int, int) {
 	if s == "" {
-		return
+		return x, y
 	}
 
 	if w.ctx.IsZero() { // Zero sized window or not in OnPaint.
-		return
+		return x, y
 	}
 
 	var main rune
 	var comb []rune
-	var state, width int
+	width := 0 // Zero if main and comb are not valid.
 
-	const (
-		st0         = iota // main, width, comb not valid.
-		stCheckComb        // main, width, comb valid, checking if followed by combining char(s).
-		stComb             // main, width, comb valid, collecting combining chars.
-	)
+	// flush prints the pending cell, if any, and reports whether it fit
+	// the limit.
+	flush := func() bool {
+		if width == 0 {
+			return true
+		}
+
+		if limit >= 0 && x+width > limit {
+			return false
+		}
+
+		w.SetCell(x, y, main, comb, style)
+		x += width
+		width = 0
+		return true
+	}
 
 	for _, r := range s {
-		if r == 0 {
+		switch r {
+		case 0:
+			continue
+		case '\t', '\n', '\r':
+			if !flush() {
+				return x, y
+			}
+
+			switch r {
+			case '\t':
+				x += 8 - x%8
+				if limit >= 0 && x > limit {
+					return limit, y
+				}
+			case '\n':
+				x, y = 0, y+1
+			case '\r':
+				x = 0
+			}
 			continue
 		}
 
-		switch runewidth.RuneWidth(r) {
+		switch n := runewidth.RuneWidth(r); n {
 		case 0: // Combining char.
-			switch state {
-			case st0:
-				main = ' '
-				width = 1
-				comb = append(comb[:0], r)
-				state = stComb
-			case stCheckComb:
-				comb = append(comb, r)
-				state = stComb
-			case stComb:
-				comb = append(comb, r)
-				state = stComb
-			default:
-				panic("internal error")
+			if width == 0 {
+				main, width, comb = ' ', 1, comb[:0]
 			}
-		case 1: // Normal width.
-			switch state {
-			case st0:
-				main = r
-				width = 1
-				comb = comb[:0]
-				state = stCheckComb
-			case stCheckComb:
-				x, y = w.printCell(x, y, width, main, comb, style)
-				main = r
-				width = 1
-				comb = comb[:0]
-				state = stCheckComb
-			case stComb:
-				comb = append(comb, r)
-				state = stComb
-			default:
-				panic("internal error")
+			comb = append(comb, r)
+		default:
+			if !flush() {
+				return x, y
+			}
+
+			main, width, comb = r, n, comb[:0]
+		}
+	}
+	flush()
+	return x, y
+}
+
+// wrapWords splits s into lines not wider than width columns, breaking at
+// spaces where possible. Words wider than width are broken anywhere. Tabs are
+// expanded to spaces assuming the lines start at column x. '\r' is kept in
+// the result and it restarts the width accounting of its line.
+func wrapWords(s string, x, width int) (r []string) {
+	width = mathutil.Max(width, 1)
+	for _, para := range strings.Split(s, "\n") {
+		var line, word []rune
+		col, wordWidth := 0, 0
+		newLine := func() {
+			r = append(r, strings.TrimRight(string(line), " "))
+			line, col = line[:0], 0
+		}
+		flushWord := func() {
+			if col+wordWidth > width && col != 0 {
+				newLine()
+			}
+			for _, c := range word {
+				n := runewidth.RuneWidth(c)
+				if col+n > width && col != 0 {
+					newLine()
+				}
+				line = append(line, c)
+				col += n
+			}
+			word, wordWidth = word[:0], 0
+		}
+		space := func(n int) {
+			if col+n > width {
+				if col != 0 {
+					newLine()
+				}
+				return
+			}
+
+			for ; n > 0; n-- {
+				line = append(line, ' ')
+				col++
 			}
-		case 2: // Double width.
-			switch state {
-			case st0:
-				main = r
-				width = 2
-				comb = comb[:0]
-				state = stCheckComb
-			case stCheckComb:
-				x, y = w.printCell(x, y, width, main, comb, style)
-				main = r
-				width = 2
-				comb = comb[:0]
-				state = stCheckComb
-			case stComb:
-				comb = append(comb, r)
-				state = stComb
+		}
+		for _, c := range para {
+			switch c {
+			case 0:
+				// nop
+			case ' ':
+				flushWord()
+				space(1)
+			case '\t':
+				flushWord()
+				space(8 - (x+col)%8)
+			case '\r':
+				flushWord()
+				line = append(line, c)
+				col = 0
 			default:
-				panic("internal error")
+				word = append(word, c)
+				wordWidth += runewidth.RuneWidth(c)
 			}
-		default:
-			panic("internal error")
 		}
+		flushWord()
+		r = append(r, strings.TrimRight(string(line), " "))
 	}
-	switch state {
-	case stCheckComb, stComb:
-		w.printCell(x, y, width, main, comb, style)
-	default:
-		panic(fmt.Errorf("%q: %v", s, state))
-	}
+	return r
 }
 
 func (w *Window) bringChildWindowToFront(c *Window) {
@@ -1117,6 +1855,45 @@ func (w *Window) bringChildWindowToFront(c *Window) {
 	w.InvalidateClientArea(Rectangle{c.Position(), c.Size()})
 }
 
+func (w *Window) sendChildWindowToBack(c *Window) {
+	if w == nil {
+		return
+	}
+
+	for i, v := range w.children {
+		if v == c {
+			if i == 0 { // Already at the back.
+				return
+			}
+
+			copy(w.children[1:], w.children[:i])
+			w.children[0] = c
+			break
+		}
+	}
+	w.InvalidateClientArea(Rectangle{c.Position(), c.Size()})
+}
+
+// moveChildWindow moves c by delta positions in the z-order.
+func (w *Window) moveChildWindow(c *Window, delta int) {
+	if w == nil {
+		return
+	}
+
+	for i, v := range w.children {
+		if v == c {
+			j := i + delta
+			if j < 0 || j >= len(w.children) {
+				return
+			}
+
+			w.children[i], w.children[j] = w.children[j], c
+			w.InvalidateClientArea(Rectangle{c.Position(), c.Size()})
+			return
+		}
+	}
+}
+
 func (w *Window) removeChild(ch *Window) {
 	for i, v := range w.children {
 		if v == ch {
@@ -1219,9 +1996,129 @@ func (w *Window) borderULCArea() (r Rectangle) {
 
 // ----------------------------------------------------------------------------
 
+// Animated reports whether the window is invalidated on every animation
+// frame.
+func (w *Window) Animated() bool {
+	_, ok := App.animated[w]
+	return ok
+}
+
 // Area returns the area of the window.
 func (w *Window) Area() Rectangle { return Rectangle{Size: w.size} }
 
+// BeginKeyboardMove starts moving w using the keyboard. The arrow keys move
+// the window by one cell, Enter ends the operation and Esc ends it while
+// returning the window to its original position. All other keys are ignored
+// until the operation ends.
+//
+// The operation focuses w and it is implemented by a temporary OnKey handler,
+// which is removed when the operation ends. The method has no effect if w is
+// a root window, if w is not movable or if a keyboard move or resize of w is
+// already in progress.
+func (w *Window) BeginKeyboardMove() {
+	if w.parent == nil || w.noMove || w.keyboardMode {
+		return
+	}
+
+	w.keyboardMode = true
+	w.SetFocus(true)
+	pos0 := w.Position()
+	var node *onKeyHandlerList
+	w.OnKey(func(w *Window, prev OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+		p := w.Position()
+		switch key {
+		case tcell.KeyLeft:
+			p.X--
+		case tcell.KeyRight:
+			p.X++
+		case tcell.KeyUp:
+			p.Y--
+		case tcell.KeyDown:
+			p.Y++
+		case tcell.KeyEnter:
+			removeOnKeyHandlerNode(&w.onKey, node)
+			return true
+		case tcell.KeyEscape:
+			w.SetPosition(pos0)
+			removeOnKeyHandlerNode(&w.onKey, node)
+			return true
+		default:
+			return true
+		}
+
+		w.SetPosition(p)
+		return true
+	}, func() { w.keyboardMode = false })
+	node = w.onKey
+}
+
+// BeginKeyboardResize starts resizing w using the keyboard. The arrow keys
+// move the right or bottom border by one cell, the arrow keys with Shift move
+// the left or top border. Enter ends the operation and Esc ends it while
+// returning the window to its original position and size. All other keys are
+// ignored until the operation ends.
+//
+// Like the mouse drag of the left or top border, moving those borders keeps
+// the opposite border in place when the window size is limited by its minimum
+// or maximum size, or by the window being at least one cell wide and high.
+//
+// The operation focuses w and it is implemented by a temporary OnKey handler,
+// which is removed when the operation ends. The method has no effect if w is
+// a root window, if w is not resizable or if a keyboard move or resize of w is
+// already in progress.
+func (w *Window) BeginKeyboardResize() {
+	if w.parent == nil || w.noResize || w.keyboardMode {
+		return
+	}
+
+	w.keyboardMode = true
+	w.SetFocus(true)
+	pos0 := w.Position()
+	size0 := w.Size()
+	var node *onKeyHandlerList
+	w.OnKey(func(w *Window, prev OnKeyHandler, key tcell.Key, mod tcell.ModMask, r rune) bool {
+		shift := mod&tcell.ModShift != 0
+		switch key {
+		case tcell.KeyLeft:
+			if shift {
+				w.ResizeEdge(EdgeLeft, 1)
+				break
+			}
+
+			w.ResizeEdge(EdgeRight, -1)
+		case tcell.KeyRight:
+			if shift {
+				w.ResizeEdge(EdgeLeft, -1)
+				break
+			}
+
+			w.ResizeEdge(EdgeRight, 1)
+		case tcell.KeyUp:
+			if shift {
+				w.ResizeEdge(EdgeTop, 1)
+				break
+			}
+
+			w.ResizeEdge(EdgeBottom, -1)
+		case tcell.KeyDown:
+			if shift {
+				w.ResizeEdge(EdgeTop, -1)
+				break
+			}
+
+			w.ResizeEdge(EdgeBottom, 1)
+		case tcell.KeyEnter:
+			removeOnKeyHandlerNode(&w.onKey, node)
+		case tcell.KeyEscape:
+			w.SetSize(size0)
+			w.SetPosition(pos0)
+			removeOnKeyHandlerNode(&w.onKey, node)
+		}
+		return true
+	}, func() { w.keyboardMode = false })
+	node = w.onKey
+}
+
 // BorderBottom returns the height of the bottom border.
 func (w *Window) BorderBottom() int { return w.borderBottom }
 
@@ -1243,6 +2140,9 @@ func (w *Window) BorderLeftArea() (r Rectangle) {
 	return r
 }
 
+// BorderLineStyle returns the style of the border lines.
+func (w *Window) BorderLineStyle() BorderLineStyle { return w.style.BorderLine }
+
 // BorderRight returns the width of the right border.
 func (w *Window) BorderRight() int { return w.borderRight }
 
@@ -1267,10 +2167,19 @@ func (w *Window) BorderTopArea() (r Rectangle) {
 	return r
 }
 
+// Borders returns the widths or heights of all four borders.
+func (w *Window) Borders() (top, right, bottom, left int) {
+	return w.borderTop, w.borderRight, w.borderBottom, w.borderLeft
+}
+
 // BringToFront puts a child window on top of all its siblings. The method has
 // no effect if w is a root window.
 func (w *Window) BringToFront() { w.Parent().bringChildWindowToFront(w) }
 
+// Buffered returns whether the client area of w is painted using a back
+// buffer. See SetBuffered.
+func (w *Window) Buffered() bool { return w.buffer != nil }
+
 // Child returns the nth child window or nil if no such exists.
 func (w *Window) Child(n int) (r *Window) {
 	if n < len(w.children) {
@@ -1280,36 +2189,129 @@ func (w *Window) Child(n int) (r *Window) {
 	return nil
 }
 
+// ChildList returns a snapshot copy of the child windows in z-order, from
+// bottom to top. Changes to the children of w, like closing a child window or
+// bringing it to front, made while iterating the result do not affect it.
+func (w *Window) ChildList() []*Window { return append([]*Window(nil), w.children...) }
+
 // Children returns the number of child windows.
 func (w *Window) Children() (r int) {
 	r = len(w.children)
 	return r
 }
 
+// ClickBubbling returns whether clicks not handled by w are passed to its
+// parent.
+func (w *Window) ClickBubbling() bool { return w.clickBubbling }
+
 // ClientArea returns the client area.
 func (w *Window) ClientArea() Rectangle { return w.clientArea }
 
 // ClientPosition returns the position of the client area relative to w.
 func (w *Window) ClientPosition() Position { return w.clientArea.Position }
 
-// ClientSize returns the size of the client area.
-func (w *Window) ClientSize() Size { return w.clientArea.Size }
+// ClientSize returns the size of the client area.
+func (w *Window) ClientSize() Size { return w.clientArea.Size }
+
+// ClientAreaStyle returns the client area style.
+func (w *Window) ClientAreaStyle() Style { return w.style.ClientArea }
+
+// Close closes w, unless an OnCloseQuery handler of w or of any of its
+// descendants aborts the operation. The handlers of the descendants are
+// invoked first, nothing is closed if any of them returns false.
+func (w *Window) Close() {
+	if !w.closeQuery() {
+		return
+	}
+
+	w.ForceClose()
+}
+
+// CloseButton returns whether the window shows a close button.
+func (w *Window) CloseButton() bool { return w.closeButton }
+
+// ConfineFully returns whether a window confined to its parent is kept fully
+// inside the parent's client area.
+func (w *Window) ConfineFully() bool { return w.confineFully }
+
+// ConfineToParent returns whether the window is confined to the client area
+// of its parent.
+func (w *Window) ConfineToParent() bool { return w.confine }
+
+// Desktop returns which Desktop w appears on.
+func (w *Window) Desktop() *Desktop { return w.desktop }
+
+// DoubleClickMaximize returns whether double clicking the title of w toggles
+// between maximized and restored geometry.
+func (w *Window) DoubleClickMaximize() bool { return !w.noTitleMaximize }
+
+// EnsureChildVisible adjusts the origin of w such that the child window c
+// becomes visible in the client area of w. If c does not fit in the client
+// area, its top left corner is made visible. The method has no effect if c is
+// not a child of w.
+func (w *Window) EnsureChildVisible(c *Window) {
+	if c == nil || c.parent != w {
+		return
+	}
+
+	o := w.Origin()
+	sz := w.ClientSize()
+	p := c.Position()
+	cs := c.Size()
+	if x := p.X + cs.Width - sz.Width; x > o.X {
+		o.X = x
+	}
+	if p.X < o.X {
+		o.X = p.X
+	}
+	if y := p.Y + cs.Height - sz.Height; y > o.Y {
+		o.Y = y
+	}
+	if p.Y < o.Y {
+		o.Y = p.Y
+	}
+	o.X = mathutil.Max(0, o.X)
+	o.Y = mathutil.Max(0, o.Y)
+	w.SetOrigin(o)
+}
+
+// Focus returns wheter the window is focused.
+func (w *Window) Focus() bool { return w.focus }
 
-// ClientAreaStyle returns the client area style.
-func (w *Window) ClientAreaStyle() Style { return w.style.ClientArea }
+// FocusOnClose returns whether closing w, while it or any of its descendants
+// is focused, moves the focus to another window. See SetFocusOnClose.
+func (w *Window) FocusOnClose() bool { return !w.noCloseFocus }
 
-// Close closes w.
-func (w *Window) Close() {
+// ForceClose closes w without consulting the OnCloseQuery handlers.
+func (w *Window) ForceClose() {
+	w.closing = true
 	w.onClose.handle(w)
+	focus := !w.noCloseFocus && w.hasFocus()
 	w.SetFocus(false)
+	delete(App.animated, w)
+	if App.cursorWindow == w {
+		App.HideCursor()
+	}
 	for w.Children() != 0 {
 		if c := w.Child(0); c != nil {
-			c.Close()
+			c.ForceClose()
 		}
 	}
+	r := w.Desktop().Root()
+	if r.hoveredWindow == w {
+		r.hoveredWindow = nil
+	}
+	if r.dragSource == w {
+		r.dragSource = nil
+	}
 	if p := w.Parent(); p != nil {
 		p.removeChild(w)
 		p.InvalidateClientArea(p.ClientArea())
+		if focus && w.desktop.FocusedWindow() == nil {
+			if c := p.closeFocusTarget(); c != nil {
+				c.SetFocus(true)
+			}
+		}
 	}
 
 	w.onClearBorders.Clear()
@@ -1317,13 +2319,21 @@ func (w *Window) Close() {
 	w.onClick.Clear()
 	w.onClickBorder.Clear()
 	w.onClose.clear()
+	w.onCloseQuery.clear()
+	w.onContextClick.Clear()
 	w.onDoubleClick.Clear()
 	w.onDoubleClickBorder.Clear()
 	w.onDrag.Clear()
 	w.onDragBorder.Clear()
+	w.onDragEnd.Clear()
+	w.onDragMove.Clear()
+	w.onDragStart.Clear()
 	w.onDrop.Clear()
 	w.onKey.clear()
+	w.onMouseEnter.Clear()
+	w.onMouseLeave.Clear()
 	w.onMouseMove.Clear()
+	w.onMouseMoveBorder.Clear()
 	w.onPaintBorderBottom.Clear()
 	w.onPaintBorderLeft.Clear()
 	w.onPaintBorderRight.Clear()
@@ -1331,7 +2341,7 @@ func (w *Window) Close() {
 	w.onPaintChildren.Clear()
 	w.onPaintClientArea.Clear()
 	w.onPaintTitle.Clear()
-	w.onSetBorderBotom.Clear()
+	w.onSetBorderBottom.Clear()
 	w.onSetBorderLeft.Clear()
 	w.onSetBorderRight.Clear()
 	w.onSetBorderStyle.Clear()
@@ -1340,24 +2350,17 @@ func (w *Window) Close() {
 	w.onSetClientSize.Clear()
 	w.onSetCloseButton.Clear()
 	w.onSetFocus.Clear()
-	w.onSetFocusedWindow.clear()
+	w.onSetFocusedWindow.Clear()
+	w.onSetMinimized.Clear()
 	w.onSetOrigin.Clear()
 	w.onSetPosition.Clear()
-	w.onSetSelection.clear()
+	w.onSetSelection.Clear()
 	w.onSetSize.Clear()
-	w.onSetStyle.clear()
-	w.onSetTitle.clear()
+	w.onSetStyle.Clear()
+	w.onSetTitle.Clear()
+	w.onSetVisible.Clear()
 }
 
-// CloseButton returns whether the window shows a close button.
-func (w *Window) CloseButton() bool { return w.closeButton }
-
-// Desktop returns which Desktop w appears on.
-func (w *Window) Desktop() *Desktop { return w.desktop }
-
-// Focus returns wheter the window is focused.
-func (w *Window) Focus() bool { return w.focus }
-
 // Invalidate marks a window area for repaint.
 func (w *Window) Invalidate(area Rectangle) {
 	if !area.Clip(Rectangle{Size: w.size}) {
@@ -1369,6 +2372,17 @@ func (w *Window) Invalidate(area Rectangle) {
 	w.EndUpdate()
 }
 
+// InputEnabled returns whether w and all of its ancestors receive input
+// events. See SetInputEnabled.
+func (w *Window) InputEnabled() bool {
+	for ; w != nil; w = w.parent {
+		if w.noInput {
+			return false
+		}
+	}
+	return true
+}
+
 // InvalidateClientArea marks an area of the client area for repaint.
 func (w *Window) InvalidateClientArea(area Rectangle) {
 	area.Position = area.Position.add(w.ClientPosition()).sub(w.Origin())
@@ -1381,17 +2395,75 @@ func (w *Window) InvalidateClientArea(area Rectangle) {
 	w.EndUpdate()
 }
 
+// IsAncestorOf returns whether w is a parent, grandparent etc. of other. A
+// window is not its own ancestor.
+func (w *Window) IsAncestorOf(other *Window) bool {
+	if other == nil {
+		return false
+	}
+
+	for p := other.Parent(); p != nil; p = p.Parent() {
+		if p == w {
+			return true
+		}
+	}
+	return false
+}
+
+// Lower moves w one step down in the z-order of its siblings. The method has
+// no effect if w is a root window or if it is already at the back.
+func (w *Window) Lower() { w.Parent().moveChildWindow(w, -1) }
+
+// MaxSize returns the maximum size of w. A zero Width or Height means the
+// respective dimension is not bounded.
+func (w *Window) MaxSize() Size { return w.maxSize }
+
+// Maximize makes w fill the client area of its parent window. The current
+// geometry of w is saved and restored by Restore. While maximized, w follows
+// the size changes of its parent's client area. The method has no effect if w
+// is a root window or if it is already maximized.
+func (w *Window) Maximize() {
+	p := w.Parent()
+	if p == nil || w.maximized {
+		return
+	}
+
+	w.BeginUpdate()
+	w.Restore()
+	w.restoreArea = Rectangle{w.Position(), w.Size()}
+	w.maximized = true
+	w.SetRectangle(Rectangle{p.Origin(), p.ClientSize()})
+	w.EndUpdate()
+}
+
+// Maximized returns whether w is maximized.
+func (w *Window) Maximized() bool { return w.maximized }
+
+// MinSize returns the minimum size of w.
+func (w *Window) MinSize() Size { return w.minSize }
+
+// Minimize shrinks w to its top border, showing only the title and the close
+// button. The current geometry of w is saved and restored by Restore. The
+// method has no effect if w is a root window or if it is already minimized.
+func (w *Window) Minimize() {
+	if w.parent != nil {
+		w.onSetMinimized.Handle(w, &w.minimized, true)
+	}
+}
+
+// Minimized returns whether w is minimized.
+func (w *Window) Minimized() bool { return w.minimized }
+
+// Movable returns whether w can be moved by the user.
+func (w *Window) Movable() bool { return !w.noMove }
+
 // NewChild creates a child window.
 func (w *Window) NewChild(area Rectangle) *Window {
 	w.BeginUpdate()
 	c := newWindow(w.desktop, w, App.ChildWindowStyle())
 	w.children = append(w.children, c)
-	c.SetBorderTop(1)
-	c.SetBorderLeft(1)
-	c.SetBorderRight(1)
-	c.SetBorderBottom(1)
-	c.SetPosition(area.Position)
-	c.SetSize(area.Size)
+	c.SetBorders(1, 1, 1, 1)
+	c.SetRectangle(area)
 	w.EndUpdate()
 	return c
 }
@@ -1414,6 +2486,24 @@ func (w *Window) OnClose(h OnCloseHandler, finalize func()) {
 	addOnCloseHandler(&w.onClose, h, finalize)
 }
 
+// OnCloseQuery sets a handler invoked on Close of the window or of any of its
+// ancestors before anything is closed. If the handler returns false, nothing
+// is closed. When the event
+// handler is removed, finalize is called, if not nil.
+func (w *Window) OnCloseQuery(h OnCloseQueryHandler, finalize func()) {
+	addOnCloseQueryHandler(&w.onCloseQuery, h, finalize)
+}
+
+// OnContextClick sets a handler invoked on a click of the right mouse button,
+// ie. tcell.Button3, with no modifiers, anywhere in the window including its
+// borders. The winPos argument of the handler is in client area coordinates
+// for clicks in the client area and in window coordinates otherwise. If the
+// handler returns false, the click is passed to the OnClick or OnClickBorder
+// handlers. When the event handler is removed, finalize is called, if not nil.
+func (w *Window) OnContextClick(h OnMouseHandler, finalize func()) {
+	AddOnMouseHandler(&w.onContextClick, h, finalize)
+}
+
 // OnDoubleClick sets a mouse double click event handler. When the event
 // handler is removed, finalize is called, if not nil.
 func (w *Window) OnDoubleClick(h OnMouseHandler, finalize func()) {
@@ -1438,6 +2528,37 @@ func (w *Window) OnDragBorder(h OnMouseHandler, finalize func()) {
 	AddOnMouseHandler(&w.onDragBorder, h, finalize)
 }
 
+// OnDragEnd sets a handler invoked when a mouse drag, which w accepted in its
+// OnDragStart handler, ends, regardless of where the mouse button is
+// released. If the handler returns false, the drop is then passed to the
+// window under the mouse as usual. When the event handler is removed, finalize
+// is called, if not nil.
+func (w *Window) OnDragEnd(h OnDragHandler, finalize func()) {
+	AddOnDragHandler(&w.onDragEnd, h, finalize)
+}
+
+// OnDragMove sets a handler invoked on mouse moves during a mouse drag, which
+// w accepted in its OnDragStart handler, regardless of where the mouse is.
+// When the event handler is removed, finalize is called, if not nil.
+func (w *Window) OnDragMove(h OnDragHandler, finalize func()) {
+	AddOnDragHandler(&w.onDragMove, h, finalize)
+}
+
+// OnDragStart sets a handler invoked when a mouse drag starts in the window,
+// including its borders. If the handler returns true, w accepts the drag: the
+// OnDrag and OnDragBorder handlers are not invoked and the OnDragMove and
+// OnDragEnd handlers of w receive the rest of the drag.
+//
+// Like for the OnDragMove and OnDragEnd handlers, the winPos argument of the
+// handler is in window coordinates, even for drags starting in the client
+// area. That differs from OnDrag, which receives client area coordinates
+// adjusted by the view origin.
+//
+// When the event handler is removed, finalize is called, if not nil.
+func (w *Window) OnDragStart(h OnDragHandler, finalize func()) {
+	AddOnDragHandler(&w.onDragStart, h, finalize)
+}
+
 // OnDrop sets a mouse drop event handler. When the event handler is removed,
 // finalize is called, if not nil.
 func (w *Window) OnDrop(h OnMouseHandler, finalize func()) {
@@ -1450,12 +2571,36 @@ func (w *Window) OnKey(h OnKeyHandler, finalize func()) {
 	addOnKeyHandler(&w.onKey, h, finalize)
 }
 
+// OnMouseEnter sets a handler invoked when the mouse moves over w, ie. when w
+// becomes the topmost window under the mouse. winPos is the mouse position in
+// window coordinates. The result of the handler is ignored. When the event
+// handler is removed, finalize is called, if not nil.
+func (w *Window) OnMouseEnter(h OnMouseHandler, finalize func()) {
+	AddOnMouseHandler(&w.onMouseEnter, h, finalize)
+}
+
+// OnMouseLeave sets a handler invoked when the mouse leaves w, ie. when w is
+// no more the topmost window under the mouse. Moving the mouse over a child
+// window of w leaves w. winPos is the mouse position in window coordinates.
+// The result of the handler is ignored. When the event handler is removed,
+// finalize is called, if not nil.
+func (w *Window) OnMouseLeave(h OnMouseHandler, finalize func()) {
+	AddOnMouseHandler(&w.onMouseLeave, h, finalize)
+}
+
 // OnMouseMove sets a mouse move event handler. When the event handler is
 // removed, finalize is called, if not nil.
 func (w *Window) OnMouseMove(h OnMouseHandler, finalize func()) {
 	AddOnMouseHandler(&w.onMouseMove, h, finalize)
 }
 
+// OnMouseMoveBorder sets a mouse move border event handler. Mouse wheel events
+// are reported as mouse moves. When the event handler is removed, finalize is
+// called, if not nil.
+func (w *Window) OnMouseMoveBorder(h OnMouseHandler, finalize func()) {
+	AddOnMouseHandler(&w.onMouseMoveBorder, h, finalize)
+}
+
 // OnPaintClientArea sets a client area paint handler. When the event handler
 // is removed, finalize is called, if not nil. Example:
 //
@@ -1607,10 +2752,31 @@ func (w *Window) OnPaintTitle(h OnPaintHandler, finalize func()) {
 	AddOnPaintHandler(&w.onPaintTitle, h, finalize)
 }
 
+// OnResize sets a function invoked after the size of w changed. It's passed
+// the size of w before and after the change. Any intermediate sizes, like
+// those caused by adjusting the size to the borders of w, are not reported.
+//
+// The function is installed as an OnSetSize handler, use RemoveOnSetSize to
+// remove it. When it's removed, finalize is called, if not nil.
+func (w *Window) OnResize(f func(w *Window, old, new Size), finalize func()) {
+	depth := 0
+	w.OnSetSize(func(w *Window, prev OnSetSizeHandler, dst *Size, src Size) {
+		old := *dst
+		depth++
+		if prev != nil {
+			prev(w, nil, dst, src)
+		}
+		depth--
+		if depth == 0 && *dst != old {
+			f(w, old, *dst)
+		}
+	}, finalize)
+}
+
 // OnSetBorderBottom sets a handler invoked on SetBorderBottom. When the event
 // handler is removed, finalize is called, if not nil.
 func (w *Window) OnSetBorderBottom(h OnSetIntHandler, finalize func()) {
-	AddOnSetIntHandler(&w.onSetBorderBotom, h, finalize)
+	AddOnSetIntHandler(&w.onSetBorderBottom, h, finalize)
 }
 
 // OnSetBorderLeft sets a handler invoked on SetBorderLeft. When the event
@@ -1649,6 +2815,15 @@ func (w *Window) OnSetClientSize(h OnSetSizeHandler, finalize func()) {
 	AddOnSetSizeHandler(&w.onSetClientSize, h, finalize)
 }
 
+// OnSetClientSizeCancelable is like OnSetClientSize but it returns a function
+// which removes the handler, even if other handlers were set after it. Calling
+// the returned function more than once has no effect.
+func (w *Window) OnSetClientSizeCancelable(h OnSetSizeHandler, finalize func()) (cancel func()) {
+	AddOnSetSizeHandler(&w.onSetClientSize, h, finalize)
+	node := w.onSetClientSize
+	return func() { removeOnSetSizeHandlerNode(&w.onSetClientSize, node) }
+}
+
 // OnSetCloseButton sets a handler invoked on SetCloseButton. When the event
 // handler is removed, finalize is called, if not nil.
 func (w *Window) OnSetCloseButton(h OnSetBoolHandler, finalize func()) {
@@ -1661,6 +2836,12 @@ func (w *Window) OnSetFocus(h OnSetBoolHandler, finalize func()) {
 	AddOnSetBoolHandler(&w.onSetFocus, h, finalize)
 }
 
+// OnSetMinimized sets a handler invoked on Minimize and Restore. When the
+// event handler is removed, finalize is called, if not nil.
+func (w *Window) OnSetMinimized(h OnSetBoolHandler, finalize func()) {
+	AddOnSetBoolHandler(&w.onSetMinimized, h, finalize)
+}
+
 // OnSetOrigin sets a handler invoked on SetOrigin. When the event handler
 // is removed, finalize is called, if not nil.
 func (w *Window) OnSetOrigin(h OnSetPositionHandler, finalize func()) {
@@ -1682,18 +2863,35 @@ func (w *Window) OnSetSize(h OnSetSizeHandler, finalize func()) {
 // OnSetStyle sets a handler invoked on SetStyle. When the event handler is
 // removed, finalize is called, if not nil.
 func (w *Window) OnSetStyle(h OnSetWindowStyleHandler, finalize func()) {
-	addOnSetWindowStyleHandler(&w.onSetStyle, h, finalize)
+	AddOnSetWindowStyleHandler(&w.onSetStyle, h, finalize)
 }
 
 // OnSetTitle sets a handler invoked on SetTitle. When the event handler is
 // removed, finalize is called, if not nil.
 func (w *Window) OnSetTitle(h OnSetStringHandler, finalize func()) {
-	addOnSetStringHandler(&w.onSetTitle, h, finalize)
+	AddOnSetStringHandler(&w.onSetTitle, h, finalize)
+}
+
+// OnSetVisible sets a handler invoked on SetVisible. When the event handler
+// is removed, finalize is called, if not nil.
+func (w *Window) OnSetVisible(h OnSetBoolHandler, finalize func()) {
+	AddOnSetBoolHandler(&w.onSetVisible, h, finalize)
 }
 
 // Origin returns the window's origin..
 func (w *Window) Origin() Position { return w.view }
 
+// Print is like Printf but it prints s as is, without formatting. It is the
+// preferred way to print constant or already formatted text in paint
+// handlers, because it does not allocate.
+func (w *Window) Print(x, y int, style Style, s string) (int, int) {
+	if w.ctx.IsZero() { // Zero sized window or not in OnPaint.
+		return x, y
+	}
+
+	return w.print(x, y, style.TCellStyle(), s, -1)
+}
+
 // Printf prints format with arguments at x, y. Calling this method outside of
 // an OnPaint handler is ignored.
 //
@@ -1702,12 +2900,71 @@ func (w *Window) Origin() Position { return w.view }
 //	'\t'	x, y = x + 8 - x%8, y
 //	'\n'	x, y = 0, y+1
 //	'\r'	x, y = 0, y
-func (w *Window) Printf(x, y int, style Style, format string, arg ...interface{}) {
+//
+// Printf returns the position following the last printed cell, which can be
+// used to continue printing, for example in a different style.
+func (w *Window) Printf(x, y int, style Style, format string, arg ...interface{}) (int, int) {
 	if w.ctx.IsZero() { // Zero sized window or not in OnPaint.
-		return
+		return x, y
 	}
 
-	w.print(x, y, style.TCellStyle(), fmt.Sprintf(format, arg...))
+	return w.print(x, y, style.TCellStyle(), sprintf(format, arg), -1)
+}
+
+// PrintfCenter is like Printf but it prints a single line horizontally
+// centered within the width of the area being painted, eg. the client area
+// within an OnPaintClientArea handler.
+func (w *Window) PrintfCenter(y int, style Style, format string, arg ...interface{}) (int, int) {
+	if w.ctx.IsZero() { // Zero sized window or not in OnPaint.
+		return 0, y
+	}
+
+	s := sprintf(format, arg)
+	return w.print(w.ctx.view.X+(w.ctx.size.Width-runewidth.StringWidth(s))/2, y, style.TCellStyle(), s, -1)
+}
+
+// PrintfRight is like Printf but it prints a single line flush right within
+// the width of the area being painted, eg. the client area within an
+// OnPaintClientArea handler.
+func (w *Window) PrintfRight(y int, style Style, format string, arg ...interface{}) (int, int) {
+	if w.ctx.IsZero() { // Zero sized window or not in OnPaint.
+		return 0, y
+	}
+
+	s := sprintf(format, arg)
+	return w.print(w.ctx.view.X+w.ctx.size.Width-runewidth.StringWidth(s), y, style.TCellStyle(), s, -1)
+}
+
+// PrintfWidth is like Printf but it prints at most maxWidth columns starting
+// at x. Printing stops before the first glyph that does not fit, wide glyphs
+// are never split.
+func (w *Window) PrintfWidth(x, y, maxWidth int, style Style, format string, arg ...interface{}) (int, int) {
+	if w.ctx.IsZero() { // Zero sized window or not in OnPaint.
+		return x, y
+	}
+
+	return w.print(x, y, style.TCellStyle(), sprintf(format, arg), x+mathutil.Max(0, maxWidth))
+}
+
+// PrintfWrap prints format with arguments at x, y wrapping the text at word
+// boundaries so that no line is wider than width columns. Words wider than
+// width are broken. '\n' forces a line break, '\t' advances to the next tab
+// stop like in Printf and '\r' returns to column x of the current line.
+// PrintfWrap returns the number of lines printed. Calling this method outside
+// of an OnPaint handler is ignored and it returns zero.
+func (w *Window) PrintfWrap(x, y, width int, style Style, format string, arg ...interface{}) int {
+	if w.ctx.IsZero() { // Zero sized window or not in OnPaint.
+		return 0
+	}
+
+	st := style.TCellStyle()
+	lines := wrapWords(sprintf(format, arg), x, width)
+	for i, v := range lines {
+		for _, v := range strings.Split(v, "\r") {
+			w.print(x, y+i, st, v, x+width)
+		}
+	}
+	return len(lines)
 }
 
 // Parent returns the window's parent. Root windows have nil parent.
@@ -1716,6 +2973,10 @@ func (w *Window) Parent() *Window { return w.parent }
 // Position returns the window position relative to its parent.
 func (w *Window) Position() Position { return w.position }
 
+// Raise moves w one step up in the z-order of its siblings. The method has no
+// effect if w is a root window or if it is already on top.
+func (w *Window) Raise() { w.Parent().moveChildWindow(w, 1) }
+
 // RemoveOnClick undoes the most recent OnClick call. The function will panic if
 // there is no handler set.
 func (w *Window) RemoveOnClick() { RemoveOnMouseHandler(&w.onClick) }
@@ -1728,6 +2989,14 @@ func (w *Window) RemoveOnClickBorder() { RemoveOnMouseHandler(&w.onClickBorder)
 // if there is no handler set.
 func (w *Window) RemoveOnClose() { removeOnCloseHandler(&w.onClose) }
 
+// RemoveOnCloseQuery undoes the most recent OnCloseQuery call. The function
+// will panic if there is no handler set.
+func (w *Window) RemoveOnCloseQuery() { removeOnCloseQueryHandler(&w.onCloseQuery) }
+
+// RemoveOnContextClick undoes the most recent OnContextClick call. The
+// function will panic if there is no handler set.
+func (w *Window) RemoveOnContextClick() { RemoveOnMouseHandler(&w.onContextClick) }
+
 // RemoveOnDoubleClick undoes the most recent OnDoubleClick call. The function
 // will panic if there is no handler set.
 func (w *Window) RemoveOnDoubleClick() { RemoveOnMouseHandler(&w.onDoubleClick) }
@@ -1744,6 +3013,18 @@ func (w *Window) RemoveOnDrag() { RemoveOnMouseHandler(&w.onDrag) }
 // will panic if there is no handler set.
 func (w *Window) RemoveOnDragBorder() { RemoveOnMouseHandler(&w.onDragBorder) }
 
+// RemoveOnDragEnd undoes the most recent OnDragEnd call. The function will
+// panic if there is no handler set.
+func (w *Window) RemoveOnDragEnd() { RemoveOnDragHandler(&w.onDragEnd) }
+
+// RemoveOnDragMove undoes the most recent OnDragMove call. The function will
+// panic if there is no handler set.
+func (w *Window) RemoveOnDragMove() { RemoveOnDragHandler(&w.onDragMove) }
+
+// RemoveOnDragStart undoes the most recent OnDragStart call. The function will
+// panic if there is no handler set.
+func (w *Window) RemoveOnDragStart() { RemoveOnDragHandler(&w.onDragStart) }
+
 // RemoveOnDrop undoes the most recent OnDrop call. The function will panic if
 // there is no handler set.
 func (w *Window) RemoveOnDrop() { RemoveOnMouseHandler(&w.onDrop) }
@@ -1752,10 +3033,22 @@ func (w *Window) RemoveOnDrop() { RemoveOnMouseHandler(&w.onDrop) }
 // there is no handler set.
 func (w *Window) RemoveOnKey() { removeOnKeyHandler(&w.onKey) }
 
+// RemoveOnMouseEnter undoes the most recent OnMouseEnter call. The function
+// will panic if there is no handler set.
+func (w *Window) RemoveOnMouseEnter() { RemoveOnMouseHandler(&w.onMouseEnter) }
+
+// RemoveOnMouseLeave undoes the most recent OnMouseLeave call. The function
+// will panic if there is no handler set.
+func (w *Window) RemoveOnMouseLeave() { RemoveOnMouseHandler(&w.onMouseLeave) }
+
 // RemoveOnMouseMove undoes the most recent OnMouseMove call. The function will
 // panic if there is no handler set.
 func (w *Window) RemoveOnMouseMove() { RemoveOnMouseHandler(&w.onMouseMove) }
 
+// RemoveOnMouseMoveBorder undoes the most recent OnMouseMoveBorder call. The
+// function will panic if there is no handler set.
+func (w *Window) RemoveOnMouseMoveBorder() { RemoveOnMouseHandler(&w.onMouseMoveBorder) }
+
 // RemoveOnPaintClientArea undoes the most recent OnPaintClientArea call. The
 // function will panic if there is no handler set.
 func (w *Window) RemoveOnPaintClientArea() { RemoveOnPaintHandler(&w.onPaintClientArea) }
@@ -1782,7 +3075,7 @@ func (w *Window) RemoveOnPaintTitle() { RemoveOnPaintHandler(&w.onPaintTitle) }
 
 // RemoveOnSetBorderBottom undoes the most recent OnSetBorderBottom call. The
 // function will panic if there is no handler set.
-func (w *Window) RemoveOnSetBorderBottom() { RemoveOnSetIntHandler(&w.onSetBorderBotom) }
+func (w *Window) RemoveOnSetBorderBottom() { RemoveOnSetIntHandler(&w.onSetBorderBottom) }
 
 // RemoveOnSetBorderLeft undoes the most recent OnSetBorderLeft call. The
 // function will panic if there is no handler set.
@@ -1816,6 +3109,10 @@ func (w *Window) RemoveOnSetCloseButton() { RemoveOnSetBoolHandler(&w.onSetClose
 // panic if there is no handler set.
 func (w *Window) RemoveOnSetFocus() { RemoveOnSetBoolHandler(&w.onSetFocus) }
 
+// RemoveOnSetMinimized undoes the most recent OnSetMinimized call. The
+// function will panic if there is no handler set.
+func (w *Window) RemoveOnSetMinimized() { RemoveOnSetBoolHandler(&w.onSetMinimized) }
+
 // RemoveOnSetOrigin undoes the most recent OnSetOrigin call. The function
 // will panic if there is no handler set.
 func (w *Window) RemoveOnSetOrigin() { RemoveOnSetPositionHandler(&w.onSetOrigin) }
@@ -1830,22 +3127,135 @@ func (w *Window) RemoveOnSetSize() { RemoveOnSetSizeHandler(&w.onSetSize) }
 
 // RemoveOnSetStyle undoes the most recent OnSetStyle call. The function will
 // panic if there is no handler set.
-func (w *Window) RemoveOnSetStyle() { removeOnSetWindowStyleHandler(&w.onSetStyle) }
+func (w *Window) RemoveOnSetStyle() { RemoveOnSetWindowStyleHandler(&w.onSetStyle) }
 
 // RemoveOnSetTitle undoes the most recent OnSetTitle call. The function will
 // panic if there is no handler set.
-func (w *Window) RemoveOnSetTitle() { removeOnSetStringHandler(&w.onSetTitle) }
+func (w *Window) RemoveOnSetTitle() { RemoveOnSetStringHandler(&w.onSetTitle) }
+
+// RemoveOnSetVisible undoes the most recent OnSetVisible call. The function
+// will panic if there is no handler set.
+func (w *Window) RemoveOnSetVisible() { RemoveOnSetBoolHandler(&w.onSetVisible) }
+
+// RenderToCells paints w into a newly allocated buffer and returns it. The
+// buffer is indexed as [y][x] in window coordinates and has the size of the
+// window. Cells not painted by any of the paint handlers are zero and the
+// second cell of a double width glyph is left untouched. The screen is not
+// updated.
+func (w *Window) RenderToCells() [][]Cell {
+	sz := w.Size()
+	cells := newCells(sz)
+	if sz.IsZero() {
+		return cells
+	}
+
+	capture, save := App.capture, App.cells
+	App.capture, App.cells = w, cells
+	w.render(Rectangle{Size: sz})
+	App.capture, App.cells = capture, save
+	return cells
+}
 
 // Rendered returns how long the last desktop rendering took. Valid only for
 // desktop's root window.
 func (w *Window) Rendered() time.Duration { return w.rendered }
 
+// RepaintOnFocus reports whether a focus change invalidates the whole window
+// area.
+func (w *Window) RepaintOnFocus() bool { return w.repaintOnFocus }
+
+// RepaintOnResize reports whether a size change invalidates the whole window
+// area.
+func (w *Window) RepaintOnResize() bool { return !w.noRepaintOnResize }
+
+// Resizable returns whether w can be resized by the user.
+func (w *Window) Resizable() bool { return !w.noResize }
+
+// ResizeEdge resizes w by moving the edge or corner e by delta cells. Positive
+// delta moves the edge outwards, negative delta moves it inwards. The opposite
+// edges stay in place, the same as when the border of w is dragged by the
+// mouse. The method has no effect if w is a root window.
+func (w *Window) ResizeEdge(e Edge, delta int) {
+	if w.parent == nil {
+		return
+	}
+
+	var dx, dy int
+	switch {
+	case e&EdgeLeft != 0:
+		dx = -delta
+	case e&EdgeRight != 0:
+		dx = delta
+	}
+	switch {
+	case e&EdgeTop != 0:
+		dy = -delta
+	case e&EdgeBottom != 0:
+		dy = delta
+	}
+	w.resizeEdge(e, w.position, w.size, dx, dy)
+}
+
+// Restore returns a minimized or maximized window to its saved geometry. A
+// window that was maximized before being minimized is restored to the
+// maximized state. The method has no effect if w is neither minimized nor
+// maximized.
+func (w *Window) Restore() {
+	switch {
+	case w.minimized:
+		w.onSetMinimized.Handle(w, &w.minimized, false)
+	case w.maximized:
+		w.BeginUpdate()
+		w.maximized = false
+		w.SetRectangle(w.restoreArea)
+		w.EndUpdate()
+	}
+}
+
+// Root returns the topmost ancestor of w or w itself if it's a root window.
+func (w *Window) Root() *Window {
+	for w.parent != nil {
+		w = w.parent
+	}
+	return w
+}
+
+// SendToBack puts a child window below all its siblings. The method has no
+// effect if w is a root window.
+func (w *Window) SendToBack() { w.Parent().sendChildWindowToBack(w) }
+
+// SetAnimated sets whether the window is invalidated on every animation
+// frame. See Application.SetRefreshRate.
+func (w *Window) SetAnimated(v bool) {
+	switch {
+	case v:
+		App.animated[w] = struct{}{}
+	default:
+		delete(App.animated, w)
+	}
+}
+
 // SetBorderBottom sets the height of the bottom border.
-func (w *Window) SetBorderBottom(v int) { w.onSetBorderBotom.Handle(w, &w.borderBottom, v) }
+func (w *Window) SetBorderBottom(v int) { w.onSetBorderBottom.Handle(w, &w.borderBottom, v) }
 
 // SetBorderLeft sets the width of the left border.
 func (w *Window) SetBorderLeft(v int) { w.onSetBorderLeft.Handle(w, &w.borderLeft, v) }
 
+// SetBorderLineStyle sets the style of the border lines.
+func (w *Window) SetBorderLineStyle(s BorderLineStyle) {
+	if w.style.BorderLine == s {
+		return
+	}
+
+	w.BeginUpdate()
+	w.style.BorderLine = s
+	w.Invalidate(w.BorderTopArea())
+	w.Invalidate(w.BorderLeftArea())
+	w.Invalidate(w.BorderRightArea())
+	w.Invalidate(w.BorderBottomArea())
+	w.EndUpdate()
+}
+
 // SetBorderRight sets the width of the right border.
 func (w *Window) SetBorderRight(v int) { w.onSetBorderRight.Handle(w, &w.borderRight, v) }
 
@@ -1855,18 +3265,72 @@ func (w *Window) SetBorderStyle(s Style) { w.onSetBorderStyle.Handle(w, &w.style
 // SetBorderTop sets the height of the top border.
 func (w *Window) SetBorderTop(v int) { w.onSetBorderTop.Handle(w, &w.borderTop, v) }
 
+// SetBorders sets the widths or heights of all four borders. The OnSetBorder*
+// handlers are invoked for every border as usual, but the client area size is
+// recomputed and the window invalidated only once.
+func (w *Window) SetBorders(top, right, bottom, left int) {
+	if t, r, b, l := w.Borders(); t == top && r == right && b == bottom && l == left {
+		return
+	}
+
+	w.BeginUpdate()
+	w.settingBorders = true
+	w.SetBorderTop(top)
+	w.SetBorderRight(right)
+	w.SetBorderBottom(bottom)
+	w.SetBorderLeft(left)
+	w.settingBorders = false
+	w.SetClientSize(Size{
+		mathutil.Max(0, w.size.Width-(w.borderLeft+w.borderRight)),
+		mathutil.Max(0, w.size.Height-(w.borderTop+w.borderBottom)),
+	})
+	w.Invalidate(w.Area())
+	w.EndUpdate()
+}
+
+// SetBuffered sets whether the client area of w is painted using a back
+// buffer. When set, OnClearClientArea and OnPaintClientArea handlers paint
+// into a buffer of the client area size and only the cells which differ from
+// what the screen already shows are then written to the screen. This helps
+// windows that repaint mostly unchanged content. Resizing the client area
+// reallocates the buffer.
+func (w *Window) SetBuffered(v bool) {
+	switch {
+	case !v:
+		w.buffer = nil
+	case w.buffer == nil:
+		w.buffer = newCells(w.clientArea.Size)
+	}
+}
+
 // SetCell renders a single character cell. Calling this method outside of an
 // OnPaint* handler is ignored.
 func (w *Window) SetCell(x, y int, mainc rune, combc []rune, style tcell.Style) {
+	if w.desktop.updateLevel != 0 { // EndUpdate would be a no-op.
+		w.setCell(Position{x, y}, mainc, combc, style)
+		return
+	}
+
 	w.BeginUpdate()
 	w.setCell(Position{x, y}, mainc, combc, style)
 	w.EndUpdate()
 }
 
+// SetClickBubbling sets whether clicks not handled by w are passed to its
+// parent. When the OnClick handlers of w, or its OnClickBorder handlers for
+// clicks on its borders, return false, the click is passed to the OnClick
+// handlers of the parent window, with the position translated to the
+// parent's client area. If those don't handle the click either, it continues
+// to the grandparent, provided the parent enables click bubbling as well, and
+// so on up to the root window. Click bubbling is disabled by default.
+func (w *Window) SetClickBubbling(v bool) { w.clickBubbling = v }
+
 // SetClientAreaStyle sets the client area style.
 func (w *Window) SetClientAreaStyle(s Style) { w.onSetClientAreaStyle.Handle(w, &w.style.ClientArea, s) }
 
-// SetClientSize sets the size of the client area.
+// SetClientSize sets the size of the client area. The client area of a root
+// window always fills the application screen, less the borders, and the
+// method does not change it.
 func (w *Window) SetClientSize(s Size) { w.onSetClientSize.Handle(w, &w.clientArea.Size, s) }
 
 // SetCloseButton sets whether the window shows a close button.
@@ -1876,9 +3340,97 @@ func (w *Window) SetCloseButton(v bool) {
 	}
 }
 
+// SetConfineFully sets whether a window confined to its parent is kept fully
+// inside the parent's client area. Otherwise only its top border row, where
+// the title is, is kept visible.
+func (w *Window) SetConfineFully(v bool) {
+	w.confineFully = v
+	if w.confine {
+		w.SetPosition(w.confinePosition(w.position))
+	}
+}
+
+// SetConfineToParent sets whether the window is kept within the client area
+// of its parent when it is moved or when the parent is resized. See also
+// SetConfineFully. The method has no effect for root windows.
+func (w *Window) SetConfineToParent(v bool) {
+	if w.parent == nil {
+		return
+	}
+
+	w.confine = v
+	if v {
+		w.SetPosition(w.confinePosition(w.position))
+	}
+}
+
+// SetCursor shows the cursor at x, y of the client area of w, using the same
+// coordinates as Printf in an OnPaintClientArea handler. The cursor follows
+// the window when it is moved or scrolled and it is hidden while the position
+// is not visible. Closing the window hides the cursor. Use
+// Application.HideCursor to hide the cursor explicitly.
+func (w *Window) SetCursor(x, y int) {
+	App.cursor = Position{x, y}
+	App.cursorShown = true
+	App.cursorWindow = w
+	App.placeCursor()
+}
+
+// SetDoubleClickMaximize sets whether double clicking the title of w
+// maximizes it or, if it is maximized or minimized, restores it. The
+// default is enabled. Disable it when the application uses double clicks on
+// the title for a different purpose.
+func (w *Window) SetDoubleClickMaximize(v bool) { w.noTitleMaximize = !v }
+
+// SetFocusOnClose sets whether closing w, while it or any of its descendants
+// is focused, moves the focus to the parent window or, for top level windows,
+// to the top-most visible remaining sibling. The default is true. Applications
+// managing the focus themselves can turn this off.
+func (w *Window) SetFocusOnClose(v bool) { w.noCloseFocus = !v }
+
 // SetFocus sets whether the window is focused.
 func (w *Window) SetFocus(v bool) { w.onSetFocus.Handle(w, &w.focus, v) }
 
+// SetInputEnabled sets whether w receives input events. A window with input
+// disabled is still painted, but it and its descendants receive no mouse
+// events, which go to the parent window instead, and they cannot be focused.
+// Disabling input of the focused window, or of its ancestor, removes the
+// focus. The method has no effect if w is a root window.
+func (w *Window) SetInputEnabled(v bool) {
+	if w.parent == nil {
+		return
+	}
+
+	w.noInput = !v
+	if !v && w.hasFocus() {
+		w.desktop.SetFocusedWindow(nil)
+	}
+}
+
+// SetMaxSize sets the maximum size of w. A zero Width or Height means the
+// respective dimension is not bounded. The constraint is not enforced for
+// root windows.
+func (w *Window) SetMaxSize(s Size) {
+	w.maxSize = s
+	if !w.minimized {
+		w.SetSize(w.clampSize(w.Size()))
+	}
+}
+
+// SetMinSize sets the minimum size of w. The constraint is not enforced for
+// root windows.
+func (w *Window) SetMinSize(s Size) {
+	w.minSize = s
+	if !w.minimized {
+		w.SetSize(w.clampSize(w.Size()))
+	}
+}
+
+// SetMovable sets whether w can be moved by the user, either by dragging its
+// title or using BeginKeyboardMove. SetPosition is not affected. Windows are
+// movable by default.
+func (w *Window) SetMovable(v bool) { w.noMove = !v }
+
 // SetOrigin sets the origin of the window. By default the origin of a window
 // is (0, 0).  When a paint handler is invoked the window's origin is
 // subtracted from the coordinates the handler paints to. Also, the
@@ -1900,6 +3452,47 @@ func (w *Window) SetPosition(p Position) {
 	}
 }
 
+// SetRectangle sets the window position relative to its parent and the window
+// size. Unlike calling SetPosition and SetSize, it invalidates the old and the
+// new window area only once, as their union. The method has no effect if w is
+// a root window.
+func (w *Window) SetRectangle(r Rectangle) {
+	if w.parent == nil {
+		return
+	}
+
+	area := Rectangle{w.position, w.size}
+	w.BeginUpdate()
+	w.settingRectangle = true
+	w.setSize(r.Size)
+	w.onSetPosition.Handle(w, &w.position, r.Position)
+	w.settingRectangle = false
+	if r = (Rectangle{w.position, w.size}); r != area {
+		area.join(r)
+		w.parent.InvalidateClientArea(area)
+	}
+	w.EndUpdate()
+}
+
+// SetRepaintOnFocus sets whether a focus change invalidates the whole window
+// area. By default only the borders, including the title, are invalidated.
+// Windows whose client area painting depends on the focus state should set
+// this to true.
+func (w *Window) SetRepaintOnFocus(v bool) { w.repaintOnFocus = v }
+
+// SetRepaintOnResize sets whether a size change invalidates the whole window
+// area, which is the default. Setting it to false makes a size change
+// invalidate only the borders and the area exposed or vacated by the resize,
+// which is faster, but it's correct only for windows whose client area
+// painting does not depend on the window size. Root windows are always
+// invalidated completely.
+func (w *Window) SetRepaintOnResize(v bool) { w.noRepaintOnResize = !v }
+
+// SetResizable sets whether w can be resized by the user, either by dragging
+// its borders or using BeginKeyboardResize. SetSize is not affected. Windows
+// are resizable by default.
+func (w *Window) SetResizable(v bool) { w.noResize = !v }
+
 // SetSize sets the window size.
 func (w *Window) SetSize(s Size) {
 	if w.parent != nil {
@@ -1908,10 +3501,50 @@ func (w *Window) SetSize(s Size) {
 }
 
 // SetStyle sets the window style.
-func (w *Window) SetStyle(s WindowStyle) { w.onSetStyle.handle(w, &w.style, s) }
+func (w *Window) SetStyle(s WindowStyle) { w.onSetStyle.Handle(w, &w.style, s) }
+
+// SetTabIndex sets the keyboard traversal order of w. Desktop.FocusNext and
+// Desktop.FocusPrev visit the child windows of the root window in ascending
+// order of their tab indices, windows having equal tab indices are visited in
+// z-order. Windows with a negative tab index are skipped by keyboard
+// traversal, but they can still be focused by clicking them. The default tab
+// index is zero.
+func (w *Window) SetTabIndex(n int) { w.tabIndex = n }
 
 // SetTitle sets the window title.
-func (w *Window) SetTitle(s string) { w.onSetTitle.handle(w, &w.title, s) }
+func (w *Window) SetTitle(s string) { w.onSetTitle.Handle(w, &w.title, s) }
+
+// SetTitleAlignment sets the horizontal placement of the window title.
+func (w *Window) SetTitleAlignment(a TitleAlignment) {
+	if w.titleAlignment == a {
+		return
+	}
+
+	w.titleAlignment = a
+	w.Invalidate(w.BorderTopArea())
+}
+
+// SetTitleEllipsis sets whether a title not fitting the top border is
+// truncated and ended with '…'. Otherwise the title is just clipped. The
+// default is true.
+func (w *Window) SetTitleEllipsis(v bool) {
+	if w.titleEllipsis == v {
+		return
+	}
+
+	w.titleEllipsis = v
+	w.Invalidate(w.BorderTopArea())
+}
+
+// SetVisible sets whether w is shown. A hidden window keeps its handlers and
+// children, but it is not painted and it receives no mouse events. Hiding the
+// focused window removes its focus. The method has no effect if w is a root
+// window.
+func (w *Window) SetVisible(v bool) {
+	if w.parent != nil {
+		w.onSetVisible.Handle(w, &w.visible, v)
+	}
+}
 
 // Size returns the window size.
 func (w *Window) Size() Size { return w.size }
@@ -1919,5 +3552,18 @@ func (w *Window) Size() Size { return w.size }
 // Style returns the window style.
 func (w *Window) Style() WindowStyle { return w.style }
 
+// TabIndex returns the keyboard traversal order of w, see SetTabIndex.
+func (w *Window) TabIndex() int { return w.tabIndex }
+
 // Title returns the window title.
 func (w *Window) Title() string { return w.title }
+
+// TitleAlignment returns the horizontal placement of the window title.
+func (w *Window) TitleAlignment() TitleAlignment { return w.titleAlignment }
+
+// TitleEllipsis returns whether a title not fitting the top border is
+// truncated and ended with '…'.
+func (w *Window) TitleEllipsis() bool { return w.titleEllipsis }
+
+// Visible returns whether w is shown.
+func (w *Window) Visible() bool { return w.visible }